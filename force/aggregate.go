@@ -0,0 +1,209 @@
+package force
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/nimajalali/go-force/forcejson"
+)
+
+// Layouts of the date and dateTime values returned by force.com.
+var aggregateDateLayouts = []string{
+	soqlDateFormat,
+	"2006-01-02T15:04:05.000-0700",
+	time.RFC3339Nano,
+}
+
+// AggregateResult is a record returned by a query using GROUP BY or aggregate
+// functions, such as
+//
+//	SELECT StageName, COUNT(Id) FROM Opportunity GROUP BY StageName
+//
+// It maps each selected field to its value. Grouped fields keep their name
+// and aggregates their alias, which defaults to expr0, expr1 and so on in
+// the order of the aggregates in the SELECT list.
+//
+// The typed accessors return an error if the result has no such alias or
+// its value has another type, and the zero value for null, e.g. the SUM of
+// no records.
+type AggregateResult map[string]interface{}
+
+func (r AggregateResult) value(alias string) (interface{}, error) {
+	value, ok := r[alias]
+	if !ok {
+		return nil, fmt.Errorf("force: aggregate result has no %v", alias)
+	}
+
+	return value, nil
+}
+
+// Int returns the value of alias as an integer, e.g. of COUNT(Id).
+func (r AggregateResult) Int(alias string) (int64, error) {
+	value, err := r.value(alias)
+	if err != nil || value == nil {
+		return 0, err
+	}
+
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("force: aggregate %v is a %T, not a number", alias, value)
+	}
+	if i, err := number.Int64(); err == nil {
+		return i, nil
+	}
+
+	// force.com may write integral results of SUM or AVG as decimals.
+	f, err := number.Float64()
+	if err != nil || f != float64(int64(f)) {
+		return 0, fmt.Errorf("force: aggregate %v is %v, not an integer", alias, number)
+	}
+
+	return int64(f), nil
+}
+
+// Float returns the value of alias as a float, e.g. of AVG(Amount).
+func (r AggregateResult) Float(alias string) (float64, error) {
+	value, err := r.value(alias)
+	if err != nil || value == nil {
+		return 0, err
+	}
+
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("force: aggregate %v is a %T, not a number", alias, value)
+	}
+
+	return number.Float64()
+}
+
+// String returns the value of alias as a string, e.g. of a grouped picklist
+// field. Numbers and booleans are formatted.
+func (r AggregateResult) String(alias string) (string, error) {
+	value, err := r.value(alias)
+	if err != nil || value == nil {
+		return "", err
+	}
+
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	}
+
+	return "", fmt.Errorf("force: aggregate %v is a %T, not a string", alias, value)
+}
+
+// Date returns the value of alias, e.g. of MAX(CloseDate) or
+// MIN(CreatedDate), as a time. Dates are returned as midnight UTC.
+func (r AggregateResult) Date(alias string) (time.Time, error) {
+	value, err := r.value(alias)
+	if err != nil || value == nil {
+		return time.Time{}, err
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("force: aggregate %v is a %T, not a date", alias, value)
+	}
+	for _, layout := range aggregateDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("force: aggregate %v is %q, not a date", alias, s)
+}
+
+// Decode copies the result into the struct out points to, mapping aliases
+// onto fields by their force tags like records are decoded:
+//
+//	type StageCount struct {
+//		StageName string
+//		Count     int `force:"expr0"`
+//	}
+func (r AggregateResult) Decode(out interface{}) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if err := forcejson.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("Unable to unmarshal aggregate result: %v", err)
+	}
+
+	return nil
+}
+
+func decodeAggregateResult(record []byte) (AggregateResult, error) {
+	decoder := json.NewDecoder(bytes.NewReader(record))
+	decoder.UseNumber()
+
+	result := AggregateResult{}
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal aggregate result: %v", err)
+	}
+	delete(result, "attributes")
+
+	return result, nil
+}
+
+// QueryAggregate runs an aggregate query and returns its results.
+func (forceApi *ForceApi) QueryAggregate(query string) ([]AggregateResult, error) {
+	return forceApi.QueryAggregateContext(context.Background(), query)
+}
+
+// QueryAggregateContext is like QueryAggregate but the request is bound to
+// ctx.
+func (forceApi *ForceApi) QueryAggregateContext(ctx context.Context, query string) ([]AggregateResult, error) {
+	it := forceApi.QueryIteratorContext(ctx, query)
+
+	var results []AggregateResult
+	for it.Next() {
+		result, err := decodeAggregateResult(it.records[it.index])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Count runs a SELECT COUNT() query, such as
+//
+//	SELECT COUNT() FROM Contact WHERE AccountId = '001D000000IRFmaIAH'
+//
+// and returns the number of matched records. Any other query also works, but
+// its first page of records is transferred and discarded.
+func (forceApi *ForceApi) Count(query string) (int, error) {
+	return forceApi.CountContext(context.Background(), query)
+}
+
+// CountContext is like Count but the request is bound to ctx.
+func (forceApi *ForceApi) CountContext(ctx context.Context, query string) (int, error) {
+	uri := forceApi.resource(queryKey)
+
+	params := url.Values{
+		"q": {query},
+	}
+
+	result := &struct {
+		TotalSize int `json:"totalSize"`
+	}{}
+	if err := forceApi.GetContext(ctx, uri, params, result); err != nil {
+		return 0, err
+	}
+
+	return result.TotalSize, nil
+}
@@ -0,0 +1,84 @@
+package force
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestQueryAggregate(t *testing.T) {
+	org := newFakeOrg(t)
+	org.HandleQuery("/services/data/v36.0/query/", []interface{}{
+		map[string]interface{}{
+			"attributes": map[string]string{"type": "AggregateResult"},
+			"StageName":  "Closed Won",
+			"expr0":      12,
+			"expr1":      2500.5,
+			"expr2":      "2024-03-01",
+			"expr3":      nil,
+		},
+	})
+	forceApi := org.New(t)
+
+	results, err := forceApi.QueryAggregate("SELECT StageName, COUNT(Id), AVG(Amount), MAX(CloseDate), SUM(Discount__c) FROM Opportunity GROUP BY StageName")
+	if err != nil {
+		t.Fatalf("QueryAggregate failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected one result, got %v", len(results))
+	}
+	result := results[0]
+
+	if count, err := result.Int("expr0"); err != nil || count != 12 {
+		t.Fatalf("Expected count 12, got %v, %v", count, err)
+	}
+	if average, err := result.Float("expr1"); err != nil || average != 2500.5 {
+		t.Fatalf("Expected average 2500.5, got %v, %v", average, err)
+	}
+	if stage, err := result.String("StageName"); err != nil || stage != "Closed Won" {
+		t.Fatalf("Expected stage Closed Won, got %v, %v", stage, err)
+	}
+	if date, err := result.Date("expr2"); err != nil || !date.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expected 2024-03-01, got %v, %v", date, err)
+	}
+	if sum, err := result.Float("expr3"); err != nil || sum != 0 {
+		t.Fatalf("Expected 0 for null, got %v, %v", sum, err)
+	}
+	if _, err := result.Int("expr9"); err == nil {
+		t.Fatal("Expected an error for a missing alias")
+	}
+	if _, err := result.Int("expr1"); err == nil {
+		t.Fatal("Expected an error for a fractional integer")
+	}
+	if _, ok := result["attributes"]; ok {
+		t.Fatal("Expected attributes to be dropped")
+	}
+
+	stage := &struct {
+		StageName string
+		Count     int     `force:"expr0"`
+		Average   float64 `force:"expr1"`
+	}{}
+	if err := result.Decode(stage); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if stage.StageName != "Closed Won" || stage.Count != 12 || stage.Average != 2500.5 {
+		t.Fatalf("Unexpected decoded result %+v", stage)
+	}
+}
+
+func TestCount(t *testing.T) {
+	org := newFakeOrg(t)
+	org.HandleJSON("/services/data/v36.0/query/", func(r *http.Request) interface{} {
+		if r.URL.Query().Get("q") != "SELECT COUNT() FROM Contact" {
+			return ApiErrors{{ErrorCode: "MALFORMED_QUERY"}}
+		}
+		return map[string]interface{}{"totalSize": 42, "done": true, "records": []interface{}{}}
+	})
+	forceApi := org.New(t)
+
+	count, err := forceApi.Count("SELECT COUNT() FROM Contact")
+	if err != nil || count != 42 {
+		t.Fatalf("Expected 42, got %v, %v", count, err)
+	}
+}
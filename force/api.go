@@ -1,64 +1,172 @@
 package force
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"sync"
+	"time"
 )
 
 const (
+	compositeKey       = "composite"
 	limitsKey          = "limits"
 	queryKey           = "query"
 	queryAllKey        = "queryAll"
+	searchKey          = "search"
 	sObjectsKey        = "sobjects"
 	sObjectKey         = "sobject"
 	sObjectDescribeKey = "describe"
 
+	parameterizedSearchKey = "parameterizedSearch"
+
 	rowTemplateKey = "rowTemplate"
 	idKey          = "{ID}"
 
 	resourcesUri = "/services/data/%v/"
+	asyncUri     = "/services/async/%v/"
 	versionsUri  = "/services/data"
 )
 
 type ForceApiInterface interface {
-	BulkInsertSObjects(table string, in []SObject) ([]*SObjectResponse, error)
-	BulkUpdateSObjects(table string, in []SObject) ([]*SObjectResponse, error)
+	ApiUsage() (usage ApiUsage, ok bool)
+	ApiVersion() string
+	BulkDeleteSObjects(table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error)
+	BulkDeleteSObjectsContext(ctx context.Context, table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error)
+	BulkFailedResults(jobId string) ([]*BulkRecordResult, error)
+	BulkFailedResultsContext(ctx context.Context, jobId string) ([]*BulkRecordResult, error)
+	BulkHardDeleteSObjects(table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error)
+	BulkHardDeleteSObjectsContext(ctx context.Context, table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error)
+	BulkIngest(mode bulkMode, table string, in []SObject, opts ...BulkOption) (*BulkIngestResult, error)
+	BulkIngestContext(ctx context.Context, mode bulkMode, table string, in []SObject, opts ...BulkOption) (*BulkIngestResult, error)
+	BulkIngestCSV(mode bulkMode, table string, r io.Reader, opts ...BulkOption) (*BulkIngestResult, error)
+	BulkIngestCSVContext(ctx context.Context, mode bulkMode, table string, r io.Reader, opts ...BulkOption) (*BulkIngestResult, error)
+	BulkInsertSObjects(table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error)
+	BulkInsertSObjectsContext(ctx context.Context, table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error)
+	BulkQuery(query string, opts ...BulkOption) *BulkQueryIterator
+	BulkQueryContext(ctx context.Context, query string, opts ...BulkOption) *BulkQueryIterator
+	BulkQuerySObjects(table string, query string, opts ...BulkOption) ([]*SObjectResponse, error)
+	BulkQuerySObjectsContext(ctx context.Context, table string, query string, opts ...BulkOption) ([]*SObjectResponse, error)
+	BulkQuerySObjectsInto(table string, query string, out interface{}, opts ...BulkOption) error
+	BulkQuerySObjectsIntoContext(ctx context.Context, table string, query string, out interface{}, opts ...BulkOption) error
+	BulkSuccessfulResults(jobId string) ([]*BulkRecordResult, error)
+	BulkSuccessfulResultsContext(ctx context.Context, jobId string) ([]*BulkRecordResult, error)
+	BulkUnprocessedRecords(jobId string) ([]*BulkRecordResult, error)
+	BulkUnprocessedRecordsContext(ctx context.Context, jobId string) ([]*BulkRecordResult, error)
+	BulkUpdateSObjects(table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error)
+	BulkUpdateSObjectsContext(ctx context.Context, table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error)
+	BulkUpsertSObjects(table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error)
+	BulkUpsertSObjectsContext(ctx context.Context, table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error)
+	Composite(req *CompositeRequest) (*CompositeResponse, error)
+	CompositeContext(ctx context.Context, req *CompositeRequest) (*CompositeResponse, error)
+	CompositeBatch(req *CompositeBatchRequest) (*CompositeBatchResponse, error)
+	CompositeBatchContext(ctx context.Context, req *CompositeBatchRequest) (*CompositeBatchResponse, error)
+	CompositeGraph(graphs ...*CompositeGraph) (*CompositeGraphResponse, error)
+	CompositeGraphContext(ctx context.Context, graphs ...*CompositeGraph) (*CompositeGraphResponse, error)
+	Count(query string) (int, error)
+	CountContext(ctx context.Context, query string) (int, error)
+	CreateBulkJob(mode bulkMode, table string, opts ...BulkOption) (*BulkJob, error)
+	CreateBulkJobContext(ctx context.Context, mode bulkMode, table string, opts ...BulkOption) (*BulkJob, error)
+	CreateSObjects(in []SObject, allOrNone bool) ([]*SObjectResponse, error)
+	CreateSObjectsContext(ctx context.Context, in []SObject, allOrNone bool) ([]*SObjectResponse, error)
 	Delete(path string, params url.Values) error
+	DeleteContext(ctx context.Context, path string, params url.Values) error
 	DeleteSObject(id string, in SObject) (err error)
+	DeleteSObjectContext(ctx context.Context, id string, in SObject) (err error)
 	DeleteSObjectByExternalId(id string, in SObject) (err error)
+	DeleteSObjectByExternalIdContext(ctx context.Context, id string, in SObject) (err error)
+	DeleteSObjects(ids []string, allOrNone bool) ([]*SObjectResponse, error)
+	DeleteSObjectsContext(ctx context.Context, ids []string, allOrNone bool) ([]*SObjectResponse, error)
 	DescribeSObject(in SObject) (resp *SObjectDescription, err error)
+	DescribeSObjectContext(ctx context.Context, in SObject) (resp *SObjectDescription, err error)
 	DescribeSObjects() (map[string]*SObjectMetaData, error)
+	DescribeSObjectsContext(ctx context.Context) (map[string]*SObjectMetaData, error)
+	ExplainQuery(query string) (*QueryExplanation, error)
+	ExplainQueryContext(ctx context.Context, query string) (*QueryExplanation, error)
 	Get(path string, params url.Values, out interface{}) error
+	GetContext(ctx context.Context, path string, params url.Values, out interface{}) error
 	GetAccessToken() string
+	GetDeleted(table string, start, end time.Time) (*DeletedRecords, error)
+	GetDeletedContext(ctx context.Context, table string, start, end time.Time) (*DeletedRecords, error)
 	GetInstanceURL() string
 	GetLimits() (limits *Limits, err error)
+	GetLimitsContext(ctx context.Context) (limits *Limits, err error)
 	GetSObject(id string, fields []string, out SObject) (err error)
+	GetSObjectContext(ctx context.Context, id string, fields []string, out SObject) (err error)
 	GetSObjectByExternalId(id string, fields []string, out SObject) (err error)
+	GetSObjectByExternalIdContext(ctx context.Context, id string, fields []string, out SObject) (err error)
+	GetUpdated(table string, start, end time.Time) (*UpdatedRecords, error)
+	GetUpdatedContext(ctx context.Context, table string, start, end time.Time) (*UpdatedRecords, error)
 	InsertSObject(in SObject) (resp *SObjectResponse, err error)
+	InsertSObjectContext(ctx context.Context, in SObject) (resp *SObjectResponse, err error)
+	InsertSObjectTree(in []SObject) (*SObjectTreeResponse, error)
+	InsertSObjectTreeContext(ctx context.Context, in []SObject) (*SObjectTreeResponse, error)
+	ListBulkJobs() ([]*BulkJobInfo, error)
+	ListBulkJobsContext(ctx context.Context) ([]*BulkJobInfo, error)
+	NewReplicator(store CheckpointStore, opts ...ReplicatorOption) (*Replicator, error)
+	ParameterizedSearch(req *ParameterizedSearchRequest) (*SearchResult, error)
+	ParameterizedSearchContext(ctx context.Context, req *ParameterizedSearchRequest) (*SearchResult, error)
 	Patch(path string, params url.Values, payload, out interface{}) error
+	PatchContext(ctx context.Context, path string, params url.Values, payload, out interface{}) error
 	Post(path string, params url.Values, payload, out interface{}) error
+	PostContext(ctx context.Context, path string, params url.Values, payload, out interface{}) error
 	Put(path string, params url.Values, payload, out interface{}) error
+	PutContext(ctx context.Context, path string, params url.Values, payload, out interface{}) error
 	Query(query string, out interface{}) (err error)
+	QueryContext(ctx context.Context, query string, out interface{}) (err error)
 	QueryAll(query string, out interface{}) (err error)
+	QueryAllContext(ctx context.Context, query string, out interface{}) (err error)
+	QueryAggregate(query string) ([]AggregateResult, error)
+	QueryAggregateContext(ctx context.Context, query string) ([]AggregateResult, error)
+	QueryIterator(query string, opts ...QueryOption) *QueryIterator
+	QueryIteratorContext(ctx context.Context, query string, opts ...QueryOption) *QueryIterator
 	QueryNext(uri string, out interface{}) (err error)
+	QueryNextContext(ctx context.Context, uri string, out interface{}) (err error)
 	RefreshToken() error
+	RefreshTokenContext(ctx context.Context) error
+	ResumeBulkJob(jobId string, opts ...BulkOption) (*BulkJob, error)
+	ResumeBulkJobContext(ctx context.Context, jobId string, opts ...BulkOption) (*BulkJob, error)
+	RetrieveSObjects(table string, ids []string, fields []string, out interface{}) error
+	RetrieveSObjectsContext(ctx context.Context, table string, ids []string, fields []string, out interface{}) error
+	Search(sosl string) (*SearchResult, error)
+	SearchContext(ctx context.Context, sosl string) (*SearchResult, error)
+	SelectStruct(v SObject) (*QueryBuilder, error)
+	SelectStructContext(ctx context.Context, v SObject) (*QueryBuilder, error)
 	TraceOff()
 	TraceOn(prefix string, logger ForceApiLogger)
 	UpdateSObject(id string, in SObject) (err error)
+	UpdateSObjectContext(ctx context.Context, id string, in SObject) (err error)
+	UpdateSObjects(in []SObject, allOrNone bool) ([]*SObjectResponse, error)
+	UpdateSObjectsContext(ctx context.Context, in []SObject, allOrNone bool) ([]*SObjectResponse, error)
 	UpsertSObjectByExternalId(id string, in SObject) (resp *SObjectResponse, err error)
+	UpsertSObjectByExternalIdContext(ctx context.Context, id string, in SObject) (resp *SObjectResponse, err error)
+	UpsertSObjectsByExternalId(in []SObject, allOrNone bool) ([]*SObjectResponse, error)
+	UpsertSObjectsByExternalIdContext(ctx context.Context, in []SObject, allOrNone bool) ([]*SObjectResponse, error)
 }
 
+// ForceApi is safe for concurrent use by multiple goroutines once created,
+// except for TraceOn and TraceOff.
 type ForceApi struct {
 	OAuth                  *ForceOauth
 	apiVersion             string
 	apiVersions            []*Version
+	mu                     sync.RWMutex // guards the maps and apiMaxBatchSize below
 	apiResources           map[string]string
 	apiSObjects            map[string]*SObjectMetaData
 	apiSObjectDescriptions map[string]*SObjectDescription
 	apiMaxBatchSize        int64
 	logger                 ForceApiLogger
 	logPrefix              string
+	httpClient             *http.Client
+	userAgent              string
+	authCode               string
+	authRedirectURI        string
+	authCodeVerifier       string
+	retryPolicy            *RetryPolicy
+	usage                  apiUsageTracker
 }
 
 type Version struct {
@@ -209,10 +317,10 @@ type ChildRelationship struct {
 }
 
 func (forceApi *ForceApi) GetApiSObjectDescription(name string) (*SObjectDescription, error) {
-	if desc, ok := forceApi.apiSObjectDescriptions[name]; ok {
+	if desc, ok := forceApi.cachedSObjectDescription(name); ok {
 		return desc, nil
 	} else {
-		if sObject, ok := forceApi.apiSObjects[name]; ok {
+		if sObject, ok := forceApi.sObjectMetaData(name); ok {
 			uri := sObject.URLs[sObjectDescribeKey]
 
 			desc := &SObjectDescription{}
@@ -221,7 +329,7 @@ func (forceApi *ForceApi) GetApiSObjectDescription(name string) (*SObjectDescrip
 				return nil, err
 			}
 
-			forceApi.apiSObjectDescriptions[name] = desc
+			forceApi.cacheSObjectDescription(name, desc)
 			return desc, nil
 		} else {
 			return nil, errors.New("Not found")
@@ -229,24 +337,100 @@ func (forceApi *ForceApi) GetApiSObjectDescription(name string) (*SObjectDescrip
 	}
 }
 
-func (forceApi *ForceApi) getApiVersions() error {
-	return forceApi.Get(versionsUri, nil, &forceApi.apiVersions)
+// resource returns the uri of the named api resource, e.g. queryKey.
+func (forceApi *ForceApi) resource(key string) string {
+	forceApi.mu.RLock()
+	defer forceApi.mu.RUnlock()
+
+	return forceApi.apiResources[key]
+}
+
+func (forceApi *ForceApi) sObjectMetaData(name string) (*SObjectMetaData, bool) {
+	forceApi.mu.RLock()
+	defer forceApi.mu.RUnlock()
+
+	metaData, ok := forceApi.apiSObjects[name]
+	return metaData, ok
+}
+
+// sObjectUrl returns the url of the given kind (e.g. rowTemplateKey) for the
+// named sobject.
+func (forceApi *ForceApi) sObjectUrl(name, key string) (string, error) {
+	metaData, ok := forceApi.sObjectMetaData(name)
+	if !ok {
+		return "", fmt.Errorf("Unable to find metadata for object: %v", name)
+	}
+
+	return metaData.URLs[key], nil
+}
+
+// sObjectsCopy returns a copy of the sobject metadata map so callers can
+// range over it while it is being refreshed.
+func (forceApi *ForceApi) sObjectsCopy() map[string]*SObjectMetaData {
+	forceApi.mu.RLock()
+	defer forceApi.mu.RUnlock()
+
+	sObjects := make(map[string]*SObjectMetaData, len(forceApi.apiSObjects))
+	for name, metaData := range forceApi.apiSObjects {
+		sObjects[name] = metaData
+	}
+
+	return sObjects
+}
+
+func (forceApi *ForceApi) maxBatchSize() int64 {
+	forceApi.mu.RLock()
+	defer forceApi.mu.RUnlock()
+
+	return forceApi.apiMaxBatchSize
+}
+
+func (forceApi *ForceApi) cachedSObjectDescription(name string) (*SObjectDescription, bool) {
+	forceApi.mu.RLock()
+	defer forceApi.mu.RUnlock()
+
+	desc, ok := forceApi.apiSObjectDescriptions[name]
+	return desc, ok
+}
+
+func (forceApi *ForceApi) cacheSObjectDescription(name string, desc *SObjectDescription) {
+	forceApi.mu.Lock()
+	defer forceApi.mu.Unlock()
+
+	forceApi.apiSObjectDescriptions[name] = desc
+}
+
+func (forceApi *ForceApi) getApiVersions(ctx context.Context) error {
+	return forceApi.GetContext(ctx, versionsUri, nil, &forceApi.apiVersions)
 }
 
-func (forceApi *ForceApi) getApiResources() error {
+func (forceApi *ForceApi) getApiResources(ctx context.Context) error {
 	uri := fmt.Sprintf(resourcesUri, forceApi.apiVersion)
-	return forceApi.Get(uri, nil, &forceApi.apiResources)
+
+	resources := make(map[string]string)
+	if err := forceApi.GetContext(ctx, uri, nil, &resources); err != nil {
+		return err
+	}
+
+	forceApi.mu.Lock()
+	defer forceApi.mu.Unlock()
+
+	forceApi.apiResources = resources
+	return nil
 }
 
-func (forceApi *ForceApi) getApiSObjects() error {
-	uri := forceApi.apiResources[sObjectsKey]
+func (forceApi *ForceApi) getApiSObjects(ctx context.Context) error {
+	uri := forceApi.resource(sObjectsKey)
 
 	list := &SObjectApiResponse{}
-	err := forceApi.Get(uri, nil, list)
+	err := forceApi.GetContext(ctx, uri, nil, list)
 	if err != nil {
 		return err
 	}
 
+	forceApi.mu.Lock()
+	defer forceApi.mu.Unlock()
+
 	forceApi.apiMaxBatchSize = list.MaxBatchSize
 
 	// The API doesn't return the list of sobjects in a map. Convert it.
@@ -258,7 +442,7 @@ func (forceApi *ForceApi) getApiSObjects() error {
 }
 
 func (forceApi *ForceApi) getApiSObjectDescriptions() error {
-	for name, metaData := range forceApi.apiSObjects {
+	for name, metaData := range forceApi.sObjectsCopy() {
 		uri := metaData.URLs[sObjectDescribeKey]
 
 		desc := &SObjectDescription{}
@@ -267,21 +451,33 @@ func (forceApi *ForceApi) getApiSObjectDescriptions() error {
 			return err
 		}
 
-		forceApi.apiSObjectDescriptions[name] = desc
+		forceApi.cacheSObjectDescription(name, desc)
 	}
 
 	return nil
 }
 
+// ApiVersion returns the API version every request is made with, e.g.
+// "v36.0": the one pinned with WithApiVersion or else the latest the org
+// supports.
+func (forceApi *ForceApi) ApiVersion() string {
+	return forceApi.apiVersion
+}
+
 func (forceApi *ForceApi) GetInstanceURL() string {
-	return forceApi.OAuth.InstanceUrl
+	return forceApi.OAuth.instanceUrl()
 }
 
 func (forceApi *ForceApi) GetAccessToken() string {
-	return forceApi.OAuth.AccessToken
+	return forceApi.OAuth.accessToken()
 }
 
 func (forceApi *ForceApi) RefreshToken() error {
+	return forceApi.RefreshTokenContext(context.Background())
+}
+
+// RefreshTokenContext is like RefreshToken but the request is bound to ctx.
+func (forceApi *ForceApi) RefreshTokenContext(ctx context.Context) error {
 	res := &RefreshTokenResponse{}
 	payload := map[string]string{
 		"grant_type":    "refresh_token",
@@ -290,11 +486,15 @@ func (forceApi *ForceApi) RefreshToken() error {
 		"client_secret": forceApi.OAuth.clientSecret,
 	}
 
-	err := forceApi.Post("/services/oauth2/token", nil, payload, res)
+	err := forceApi.PostContext(ctx, tokenUri, nil, payload, res)
 	if err != nil {
 		return err
 	}
 
+	forceApi.OAuth.mu.Lock()
 	forceApi.OAuth.AccessToken = res.AccessToken
-	return nil
+	forceApi.OAuth.IssuedAt = res.IssuedAt
+	forceApi.OAuth.mu.Unlock()
+
+	return forceApi.OAuth.tokenIssued()
 }
@@ -0,0 +1,153 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Limits of a Bulk API 1.0 batch.
+	maxBulkBatchRecords = 10000
+	maxBulkBatchBytes   = 10000000
+
+	// Largest chunk PK chunking splits a query job into.
+	maxPKChunkSize = 250000
+
+	pkChunkingHeader = "Sforce-Enable-PKChunking"
+)
+
+// States of a Bulk API 1.0 batch.
+const (
+	bulkBatchQueued       = "Queued"
+	bulkBatchInProgress   = "InProgress"
+	bulkBatchCompleted    = "Completed"
+	bulkBatchFailed       = "Failed"
+	bulkBatchNotProcessed = "NotProcessed"
+)
+
+// BulkConcurrencyMode sets whether the batches of a Bulk API 1.0 job are
+// processed in "Parallel", the default, or one at a time in "Serial", which
+// avoids lock contention between batches updating related records.
+func BulkConcurrencyMode(mode string) BulkOption {
+	return func(options *bulkOptions) error {
+		if mode != "Parallel" && mode != "Serial" {
+			return fmt.Errorf("force: invalid concurrency mode %q", mode)
+		}
+		options.concurrencyMode = mode
+		return nil
+	}
+}
+
+// BulkPKChunking splits a Bulk API 1.0 query job into batches each covering
+// chunkSize records by record id, at most 250,000, which lets queries of large
+// tables complete. The results of all the batches are merged.
+func BulkPKChunking(chunkSize int) BulkOption {
+	return func(options *bulkOptions) error {
+		if chunkSize <= 0 || chunkSize > maxPKChunkSize {
+			return fmt.Errorf("force: invalid PK chunk size %v", chunkSize)
+		}
+		options.pkChunkSize = chunkSize
+		return nil
+	}
+}
+
+// batchInfoList is the list of the batches of a Bulk API 1.0 job.
+type batchInfoList struct {
+	BatchInfo []*CreateBatchResponse `json:"batchInfo"`
+}
+
+// splitBulkBatches encodes the records in as JSON batches of at most
+// maxRecords records and maxBulkBatchBytes bytes. If idOnly is set only the Id
+// of the records is sent, as deletes require.
+func splitBulkBatches(in []SObject, maxRecords int, idOnly bool) ([][]json.RawMessage, error) {
+	if maxRecords <= 0 || maxRecords > maxBulkBatchRecords {
+		maxRecords = maxBulkBatchRecords
+	}
+
+	var batches [][]json.RawMessage
+	var batch []json.RawMessage
+	size := 1
+	for i, record := range in {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("Error marshaling encoded payload: %v", err)
+		}
+		if idOnly {
+			fields := map[string]json.RawMessage{}
+			if err := json.Unmarshal(data, &fields); err != nil {
+				return nil, fmt.Errorf("force: %v record is not a JSON object: %v", record.ApiName(), err)
+			}
+			if id := fields["Id"]; len(id) == 0 || string(id) == "null" || string(id) == `""` {
+				return nil, fmt.Errorf("force: %v record %v has no Id", record.ApiName(), i)
+			}
+			if data, err = json.Marshal(map[string]json.RawMessage{"Id": fields["Id"]}); err != nil {
+				return nil, err
+			}
+		}
+
+		// A batch is a JSON array: its brackets and a comma between records.
+		if 1+len(data)+1 > maxBulkBatchBytes {
+			return nil, fmt.Errorf("force: %v record is larger than a bulk batch", record.ApiName())
+		}
+		if len(batch) == maxRecords || size+len(data)+1 > maxBulkBatchBytes {
+			batches = append(batches, batch)
+			batch, size = nil, 1
+		}
+		batch = append(batch, data)
+		size += len(data) + 1
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	return batches, nil
+}
+
+// asyncJobUri returns the uri of the Bulk API 1.0 job jobID, or of jobs if
+// jobID is empty. Bulk API 1.0 versions have no "v" prefix.
+func (forceApi *ForceApi) asyncJobUri(jobID string) string {
+	uri := fmt.Sprintf(asyncUri, strings.TrimPrefix(forceApi.apiVersion, "v")) + "job"
+	if jobID != "" {
+		uri += "/" + jobID
+	}
+
+	return uri
+}
+
+// waitForBatches polls the batches of a Bulk API 1.0 job until every one has
+// been processed. A query batch split by PK chunking is only marked
+// NotProcessed once the batches of its chunks have been added.
+func (forceApi *ForceApi) waitForBatches(ctx context.Context, jobID string, options *bulkOptions) ([]*CreateBatchResponse, error) {
+	interval := options.minPollInterval
+	for {
+		if err := sleepContext(ctx, interval); err != nil {
+			return nil, err
+		}
+
+		list := &batchInfoList{}
+		if err := forceApi.GetContext(ctx, forceApi.asyncJobUri(jobID)+"/batch", nil, list); err != nil {
+			return nil, err
+		}
+
+		done := len(list.BatchInfo) > 0
+		for _, batch := range list.BatchInfo {
+			if batch.State == bulkBatchQueued || batch.State == bulkBatchInProgress {
+				done = false
+				break
+			}
+		}
+		if done {
+			return list.BatchInfo, nil
+		}
+
+		if interval *= 2; interval > options.maxPollInterval {
+			interval = options.maxPollInterval
+		}
+	}
+}
+
+func batchError(batch *CreateBatchResponse) error {
+	return fmt.Errorf("force: bulk batch %v failed: %v", batch.ID, batch.StateMessage)
+}
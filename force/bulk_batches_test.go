@@ -0,0 +1,302 @@
+package force
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAsyncJobs serves a Bulk API 1.0 job whose batches are processed by the
+// time they are first listed.
+type fakeAsyncJobs struct {
+	mu      sync.Mutex
+	created map[string]string
+	header  http.Header
+	batches []string
+	states  []string
+
+	// chunks, if set, replace a query batch, which is left NotProcessed.
+	chunks [][]string
+	failed bool
+
+	// failUpload fails the creation of the batch with that number, if set.
+	failUpload int
+	// failClose fails the closing of the job.
+	failClose bool
+}
+
+func newFakeAsyncJobs(t *testing.T, org *fakeOrg) *fakeAsyncJobs {
+	jobs := &fakeAsyncJobs{}
+	org.HandleJSON("/services/async/36.0/job", func(r *http.Request) interface{} {
+		jobs.mu.Lock()
+		defer jobs.mu.Unlock()
+		if err := json.NewDecoder(r.Body).Decode(&jobs.created); err != nil {
+			t.Errorf("Unable to decode job: %v", err)
+		}
+		jobs.header = r.Header
+		return &CreateJobResponse{ID: "750J", State: "Open"}
+	})
+	org.mux.HandleFunc("/services/async/36.0/job/750J", func(w http.ResponseWriter, r *http.Request) {
+		jobs.mu.Lock()
+		defer jobs.mu.Unlock()
+
+		w.Header().Set("Content-Type", jsonContentType)
+		state := &CloseJobRequest{}
+		json.NewDecoder(r.Body).Decode(state)
+		jobs.states = append(jobs.states, state.State)
+		if jobs.failClose && state.State == "Closed" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `[{"errorCode":"InvalidJobState","message":"Job not closed"}]`)
+			return
+		}
+		json.NewEncoder(w).Encode(&CreateJobResponse{ID: "750J", State: state.State})
+	})
+	org.mux.HandleFunc("/services/async/36.0/job/750J/batch", func(w http.ResponseWriter, r *http.Request) {
+		jobs.mu.Lock()
+		defer jobs.mu.Unlock()
+
+		w.Header().Set("Content-Type", jsonContentType)
+		if r.Method == "POST" {
+			data, _ := io.ReadAll(r.Body)
+			jobs.batches = append(jobs.batches, string(data))
+			if len(jobs.batches) == jobs.failUpload {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `[{"errorCode":"InvalidBatch","message":"Records not processed"}]`)
+				return
+			}
+			json.NewEncoder(w).Encode(&CreateBatchResponse{ID: fmt.Sprintf("751B%d", len(jobs.batches)-1), State: bulkBatchQueued})
+			return
+		}
+
+		list := &batchInfoList{}
+		for i := range jobs.batches {
+			batch := &CreateBatchResponse{ID: fmt.Sprintf("751B%d", i), State: bulkBatchCompleted}
+			if jobs.chunks != nil {
+				batch.State = bulkBatchNotProcessed
+			}
+			if jobs.failed && i == 1 {
+				batch.State, batch.StateMessage = bulkBatchFailed, "InvalidBatch"
+			}
+			list.BatchInfo = append(list.BatchInfo, batch)
+		}
+		for i := range jobs.chunks {
+			list.BatchInfo = append(list.BatchInfo, &CreateBatchResponse{ID: fmt.Sprintf("751C%d", i), State: bulkBatchCompleted})
+		}
+		json.NewEncoder(w).Encode(list)
+	})
+	org.HandleJSON("/services/async/36.0/job/750J/batch/", func(r *http.Request) interface{} {
+		jobs.mu.Lock()
+		defer jobs.mu.Unlock()
+
+		path := strings.Split(strings.TrimPrefix(r.URL.Path, "/services/async/36.0/job/750J/batch/"), "/")
+		batchID := path[0]
+		if strings.HasPrefix(batchID, "751C") {
+			var chunk int
+			fmt.Sscanf(batchID, "751C%d", &chunk)
+			if len(path) == 2 {
+				return []string{"752R0", "752R1"}
+			}
+			// Every result of a chunk holds one of its records.
+			var result int
+			fmt.Sscanf(path[2], "752R%d", &result)
+			return []map[string]interface{}{{
+				"attributes":  map[string]string{"type": "Account"},
+				"Id":          jobs.chunks[chunk][result],
+				"BillingCity": "Oslo",
+			}}
+		}
+
+		var batch int
+		fmt.Sscanf(batchID, "751B%d", &batch)
+		records := []map[string]interface{}{}
+		json.Unmarshal([]byte(jobs.batches[batch]), &records)
+		results := []*SObjectResponse{}
+		for i := range records {
+			results = append(results, &SObjectResponse{Id: fmt.Sprintf("001-%d-%d", batch, i), Success: true})
+		}
+		return results
+	})
+
+	return jobs
+}
+
+func TestBulkUpsertSObjectsBatches(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeAsyncJobs(t, org)
+	forceApi := org.New(t)
+
+	var in []SObject
+	for i := 0; i < 450; i++ {
+		in = append(in, &testExternalAccount{testCompositeAccount{BillingCity: "Oslo"}, fmt.Sprint("A-", i)})
+	}
+	resp, err := forceApi.BulkUpsertSObjects("Account", in, BulkConcurrencyMode("Serial"), BulkPollInterval(time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatalf("BulkUpsertSObjects failed: %v", err)
+	}
+
+	if jobs.created["operation"] != "upsert" || jobs.created["externalIdFieldName"] != "AccountNumber" ||
+		jobs.created["concurrencyMode"] != "Serial" || jobs.created["contentType"] != "JSON" {
+		t.Fatalf("Unexpected job %v", jobs.created)
+	}
+	if len(jobs.batches) != 3 {
+		t.Fatalf("Expected batches of the org's batch size, got %v batches", len(jobs.batches))
+	}
+	last := []map[string]interface{}{}
+	if err := json.Unmarshal([]byte(jobs.batches[2]), &last); err != nil || len(last) != 50 || last[49]["AccountNumber"] != "A-449" {
+		t.Fatalf("Unexpected last batch %v", err)
+	}
+	if len(resp) != 450 || resp[0].Id != "001-0-0" || resp[449].Id != "001-2-49" {
+		t.Fatalf("Expected results in record order, got %v", len(resp))
+	}
+	if strings.Join(jobs.states, ",") != "Closed" {
+		t.Fatalf("Expected the job to be closed, got %v", jobs.states)
+	}
+}
+
+func TestBulkHardDeleteSObjectsSendsIds(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeAsyncJobs(t, org)
+	forceApi := org.New(t)
+
+	account := &testCompositeAccount{BillingCity: "Oslo"}
+	account.Id = "001A"
+	if _, err := forceApi.BulkHardDeleteSObjects("Account", []SObject{account}, BulkPollInterval(time.Millisecond, time.Millisecond)); err != nil {
+		t.Fatalf("BulkHardDeleteSObjects failed: %v", err)
+	}
+	if jobs.created["operation"] != "hardDelete" || jobs.created["concurrencyMode"] != "" || jobs.batches[0] != `[{"Id":"001A"}]` {
+		t.Fatalf("Unexpected job %v with batches %v", jobs.created, jobs.batches)
+	}
+}
+
+func TestBulkModifySObjectsFailedBatch(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeAsyncJobs(t, org)
+	jobs.failed = true
+	forceApi := org.New(t)
+
+	var in []SObject
+	for i := 0; i < 201; i++ {
+		in = append(in, &testCompositeAccount{BillingCity: "Oslo"})
+	}
+	_, err := forceApi.BulkInsertSObjects("Account", in, BulkPollInterval(time.Millisecond, time.Millisecond))
+	if err == nil || !strings.Contains(err.Error(), "InvalidBatch") {
+		t.Fatalf("Expected the batch failure, got %v", err)
+	}
+}
+
+func TestBulkModifySObjectsAbortsFailedUpload(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeAsyncJobs(t, org)
+	jobs.failUpload = 2
+	forceApi := org.New(t)
+
+	var in []SObject
+	for i := 0; i < 201; i++ {
+		in = append(in, &testCompositeAccount{BillingCity: "Oslo"})
+	}
+	_, err := forceApi.BulkInsertSObjects("Account", in, BulkPollInterval(time.Millisecond, time.Millisecond))
+	if apiErrors, ok := err.(ApiErrors); !ok || apiErrors[0].ErrorCode != "InvalidBatch" {
+		t.Fatalf("Expected the batch error, got %v", err)
+	}
+	if strings.Join(jobs.states, ",") != "Aborted" {
+		t.Fatalf("Expected the job to be aborted and not closed, got %v", jobs.states)
+	}
+}
+
+func TestBulkModifySObjectsAbortsUnclosedJob(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeAsyncJobs(t, org)
+	jobs.failClose = true
+	forceApi := org.New(t)
+
+	_, err := forceApi.BulkInsertSObjects("Account", []SObject{&testCompositeAccount{BillingCity: "Oslo"}})
+	if apiErrors, ok := err.(ApiErrors); !ok || apiErrors[0].ErrorCode != "InvalidJobState" {
+		t.Fatalf("Expected the close error, got %v", err)
+	}
+	if strings.Join(jobs.states, ",") != "Closed,Aborted" {
+		t.Fatalf("Expected the job to be aborted, got %v", jobs.states)
+	}
+}
+
+func TestBulkDeleteSObjectsWithoutId(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeAsyncJobs(t, org)
+	forceApi := org.New(t)
+
+	account := &testCompositeAccount{}
+	account.Id = "001A"
+	_, err := forceApi.BulkDeleteSObjects("Account", []SObject{account, &testCompositeAccount{}})
+	if err == nil || !strings.Contains(err.Error(), "Account record 1 has no Id") {
+		t.Fatalf("Expected the record without an Id to be named, got %v", err)
+	}
+	if len(jobs.batches) != 0 {
+		t.Fatalf("Expected no batches to be sent, got %v", jobs.batches)
+	}
+}
+
+func TestBulkQuerySObjectsPKChunking(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeAsyncJobs(t, org)
+	jobs.chunks = [][]string{{"001A", "001B"}, {"001C", "001D"}}
+	forceApi := org.New(t)
+
+	var accounts []*testCompositeAccount
+	err := forceApi.BulkQuerySObjectsInto("Account", "SELECT Id, BillingCity FROM Account", &accounts, BulkPKChunking(1000), BulkPollInterval(time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatalf("BulkQuerySObjects failed: %v", err)
+	}
+
+	if jobs.created["operation"] != "query" || jobs.header.Get(pkChunkingHeader) != "chunkSize=1000" {
+		t.Fatalf("Unexpected job %v with header %v", jobs.created, jobs.header)
+	}
+	if len(jobs.batches) != 1 || jobs.batches[0] != "SELECT Id, BillingCity FROM Account" {
+		t.Fatalf("Unexpected batches %v", jobs.batches)
+	}
+	var ids []string
+	for _, account := range accounts {
+		if account.BillingCity != "Oslo" {
+			t.Fatalf("Expected the fields of the records, got %+v", account)
+		}
+		ids = append(ids, account.Id)
+	}
+	if strings.Join(ids, ",") != "001A,001B,001C,001D" {
+		t.Fatalf("Expected the results of every chunk, got %v", ids)
+	}
+
+	resp, err := forceApi.BulkQuerySObjects("Account", "SELECT Id FROM Account", BulkPollInterval(time.Millisecond, time.Millisecond))
+	if err != nil || len(resp) != 4 || resp[0].Id != "001A" {
+		t.Fatalf("Expected the ids of the records, got %v: %v", len(resp), err)
+	}
+
+	if _, err := forceApi.BulkQuerySObjects("Account", "SELECT Id FROM Account", BulkPKChunking(300000)); err == nil {
+		t.Fatal("Expected an error for an invalid chunk size")
+	}
+	if err := forceApi.BulkQuerySObjectsInto("Account", "SELECT Id FROM Account", accounts); err == nil {
+		t.Fatal("Expected an error for records not passed by pointer")
+	}
+}
+
+func TestSplitBulkBatches(t *testing.T) {
+	large := strings.Repeat("x", 3000000)
+	var in []SObject
+	for i := 0; i < 4; i++ {
+		in = append(in, &testCompositeAccount{BillingCity: large})
+	}
+
+	batches, err := splitBulkBatches(in, 200, false)
+	if err != nil {
+		t.Fatalf("splitBulkBatches failed: %v", err)
+	}
+	if len(batches) != 2 || len(batches[0]) != 3 || len(batches[1]) != 1 {
+		t.Fatalf("Expected batches within 10MB, got %v batches", len(batches))
+	}
+
+	if _, err := splitBulkBatches([]SObject{&testCompositeAccount{BillingCity: large + large + large + large}}, 200, false); err == nil {
+		t.Fatal("Expected an error for a record larger than a batch")
+	}
+}
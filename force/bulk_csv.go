@@ -0,0 +1,440 @@
+package force
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimajalali/go-force/forcejson"
+)
+
+const (
+	csvContentType = "text/csv"
+
+	// bulkNull is the CSV value Bulk API 2.0 reads as null, to clear a field.
+	bulkNull = "#N/A"
+)
+
+// encodeSObjectsCSV writes the records in as CSV for a Bulk API 2.0 ingest
+// job. The columns are the fields the records are marshaled with, in order of
+// first appearance; parent relationships, e.g. an Owner holding the external
+// id of a user, become columns such as Owner.ExternalId__c. Fields a record
+// does not have are left empty, which leaves them unchanged.
+//
+// If idOnly is set only the Id column is written, as deletes require, and
+// every record must have an Id.
+func encodeSObjectsCSV(in []SObject, idOnly bool) ([]byte, error) {
+	var columns []string
+	seen := make(map[string]bool)
+	rows := make([]*flatRecord, len(in))
+	for i, record := range in {
+		row, err := flattenSObject(record)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = row
+
+		if idOnly {
+			if id := row.values["Id"]; id == "" || id == bulkNull {
+				return nil, fmt.Errorf("force: %v record %v has no Id", record.ApiName(), i)
+			}
+			continue
+		}
+		for _, column := range row.columns {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	if idOnly {
+		columns = []string{"Id"}
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("force: bulk records have no fields")
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(columns); err != nil {
+		return nil, err
+	}
+	line := make([]string, len(columns))
+	for _, row := range rows {
+		for i, column := range columns {
+			line[i] = row.values[column]
+		}
+		if err := w.Write(line); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type flatRecord struct {
+	columns []string
+	values  map[string]string
+}
+
+// flattenSObject returns the CSV columns and values of record.
+func flattenSObject(record SObject) (*flatRecord, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshaling encoded payload: %v", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	fields := orderedObject{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("force: %v record is not a JSON object: %v", record.ApiName(), err)
+	}
+
+	flat := &flatRecord{values: make(map[string]string)}
+	if err := flat.add("", fields); err != nil {
+		return nil, err
+	}
+
+	return flat, nil
+}
+
+func (flat *flatRecord) add(prefix string, fields orderedObject) error {
+	for _, field := range fields {
+		if field.name == "attributes" {
+			continue
+		}
+		name := prefix + field.name
+
+		var value string
+		switch v := field.value.(type) {
+		case nil:
+			value = bulkNull
+		case string:
+			value = v
+		case json.Number:
+			value = v.String()
+		case bool:
+			value = fmt.Sprint(v)
+		case orderedObject:
+			if err := flat.add(name+".", v); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("force: field %v of type %T cannot be written as CSV", name, v)
+		}
+
+		flat.columns = append(flat.columns, name)
+		flat.values[name] = value
+	}
+
+	return nil
+}
+
+// orderedObject is a JSON object decoded with its keys in order.
+type orderedObject []orderedField
+
+type orderedField struct {
+	name  string
+	value interface{}
+}
+
+func (o *orderedObject) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return err
+		}
+
+		var value interface{}
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+			nested := orderedObject{}
+			if err := json.Unmarshal(trimmed, &nested); err != nil {
+				return err
+			}
+			value = nested
+		} else {
+			valueDecoder := json.NewDecoder(bytes.NewReader(raw))
+			valueDecoder.UseNumber()
+			if err := valueDecoder.Decode(&value); err != nil {
+				return err
+			}
+		}
+
+		*o = append(*o, orderedField{key.(string), value})
+	}
+
+	return nil
+}
+
+// csvRows reads CSV with a header row and yields each row as a map from
+// column to value.
+type csvRows struct {
+	r      *csv.Reader
+	header []string
+}
+
+func newCSVRows(r io.Reader) (*csvRows, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return &csvRows{r: reader}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read CSV header: %v", err)
+	}
+
+	// Strip the byte order mark some results start with.
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	return &csvRows{r: reader, header: append([]string(nil), header...)}, nil
+}
+
+// next returns the next row, or io.EOF.
+func (rows *csvRows) next() (map[string]string, error) {
+	if rows.header == nil {
+		return nil, io.EOF
+	}
+
+	record, err := rows.r.Read()
+	if err != nil {
+		if err != io.EOF {
+			err = fmt.Errorf("Unable to read CSV: %v", err)
+		}
+		return nil, err
+	}
+
+	row := make(map[string]string, len(rows.header))
+	for i, column := range rows.header {
+		if i < len(record) {
+			row[column] = record[i]
+		}
+	}
+
+	return row, nil
+}
+
+// Layouts of the date and dateTime values in Bulk API results.
+var csvTimeLayouts = []string{
+	"2006-01-02T15:04:05.000Z",
+	"2006-01-02T15:04:05.000-0700",
+	time.RFC3339Nano,
+	soqlDateFormat,
+}
+
+// decodeCSVRow copies a row of query results into out, a pointer to a
+// map[string]string or to a struct whose fields are named by force tags as
+// for StructFields: a column such as Owner.Name is decoded into the Name of
+// the struct held by the Owner field. Empty values, which are how Bulk API
+// writes nulls, leave fields unset.
+func decodeCSVRow(row map[string]string, out interface{}) error {
+	if m, ok := out.(*map[string]string); ok {
+		*m = make(map[string]string, len(row))
+		for column, value := range row {
+			(*m)[column] = value
+		}
+		return nil
+	}
+
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("force: CSV rows decode into a pointer to a struct or map[string]string")
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return errors.New("force: CSV rows decode into a pointer to a struct or map[string]string")
+	}
+
+	values := make(map[string]string, len(row))
+	for column, value := range row {
+		if value != "" {
+			values[strings.ToLower(column)] = value
+		}
+	}
+
+	_, err := decodeCSVStruct(values, v, "")
+	return err
+}
+
+// decodeCSVStruct sets the fields of v from values, keyed by lower case
+// column, and reports whether any was set.
+func decodeCSVStruct(values map[string]string, v reflect.Value, prefix string) (bool, error) {
+	set := false
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("force")
+		if tag == "-" || (sf.PkgPath != "" && !sf.Anonymous) {
+			continue
+		}
+		name := strings.SplitN(tag, ",", 2)[0]
+		if name == "attributes" {
+			continue
+		}
+
+		fv := v.Field(i)
+		ft := indirectType(sf.Type)
+		if sf.Anonymous && name == "" && isRelationshipType(ft) {
+			if fv.Kind() == reflect.Ptr && (fv.IsNil() || !fv.CanSet()) {
+				continue
+			}
+			ok, err := decodeCSVStruct(values, reflect.Indirect(fv), prefix)
+			if err != nil {
+				return false, err
+			}
+			set = set || ok
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		column := prefix + strings.ToLower(name)
+
+		if isRelationshipType(ft) {
+			if _, ok := childRecordsType(ft); ok {
+				continue
+			}
+			target := reflect.New(ft).Elem()
+			ok, err := decodeCSVStruct(values, target, column+".")
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				setValue(fv, target)
+				set = true
+			}
+			continue
+		}
+
+		value, ok := values[column]
+		if !ok {
+			continue
+		}
+		target := reflect.New(ft)
+		if err := decodeCSVValue(value, target); err != nil {
+			return false, fmt.Errorf("force: unable to decode %v %q: %v", column, value, err)
+		}
+		setValue(fv, target.Elem())
+		set = true
+	}
+
+	return set, nil
+}
+
+// setValue sets field, which may be a pointer, to value.
+func setValue(field, value reflect.Value) {
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		ptr.Elem().Set(value)
+		field.Set(ptr)
+		return
+	}
+
+	field.Set(value)
+}
+
+// decodeCSVValue decodes a CSV value into the value ptr points to.
+func decodeCSVValue(value string, ptr reflect.Value) error {
+	target := ptr.Interface()
+	switch target := target.(type) {
+	case *string:
+		*target = value
+		return nil
+	case *time.Time:
+		for _, layout := range csvTimeLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				*target = t
+				return nil
+			}
+		}
+		return errors.New("not a date")
+	case encoding.TextUnmarshaler:
+		return target.UnmarshalText([]byte(value))
+	case json.Unmarshaler:
+		return target.UnmarshalJSON(csvValueJSON(value))
+	}
+
+	elem := ptr.Elem()
+	switch elem.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		elem.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, elem.Type().Bits())
+		if err != nil {
+			return err
+		}
+		elem.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, elem.Type().Bits())
+		if err != nil {
+			return err
+		}
+		elem.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, elem.Type().Bits())
+		if err != nil {
+			return err
+		}
+		elem.SetFloat(f)
+	case reflect.String:
+		elem.SetString(value)
+	default:
+		return forcejson.Unmarshal(csvValueJSON(value), target)
+	}
+
+	return nil
+}
+
+// csvValueJSON returns value as a JSON literal: as is if it is a number or
+// boolean, quoted otherwise.
+func csvValueJSON(value string) []byte {
+	if value == "true" || value == "false" {
+		return []byte(value)
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil && json.Valid([]byte(value)) {
+		return []byte(value)
+	}
+
+	quoted, _ := json.Marshal(value)
+	return quoted
+}
@@ -0,0 +1,453 @@
+package force
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	ingestJobsUri = "jobs/ingest"
+
+	// maxIngestUploadSize is the size of the largest CSV upload Bulk API 2.0
+	// accepts for a job.
+	maxIngestUploadSize = 100 << 20
+
+	defaultBulkPollInterval    = time.Second
+	defaultBulkMaxPollInterval = 30 * time.Second
+)
+
+// States of a Bulk API 2.0 job.
+const (
+	BulkJobOpen           = "Open"
+	BulkJobUploadComplete = "UploadComplete"
+	BulkJobInProgress     = "InProgress"
+	BulkJobComplete       = "JobComplete"
+	BulkJobFailed         = "Failed"
+	BulkJobAborted        = "Aborted"
+)
+
+// BulkJobInfo describes a Bulk API 2.0 job.
+type BulkJobInfo struct {
+	Id                      string  `json:"id"`
+	Object                  string  `json:"object"`
+	Operation               string  `json:"operation"`
+	State                   string  `json:"state"`
+	ExternalIdFieldName     string  `json:"externalIdFieldName,omitempty"`
+	ContentType             string  `json:"contentType"`
+	LineEnding              string  `json:"lineEnding"`
+	ColumnDelimiter         string  `json:"columnDelimiter"`
+	ConcurrencyMode         string  `json:"concurrencyMode"`
+	JobType                 string  `json:"jobType"`
+	ApiVersion              float64 `json:"apiVersion"`
+	CreatedById             string  `json:"createdById"`
+	CreatedDate             string  `json:"createdDate"`
+	SystemModstamp          string  `json:"systemModstamp"`
+	ErrorMessage            string  `json:"errorMessage,omitempty"`
+	NumberRecordsProcessed  int64   `json:"numberRecordsProcessed"`
+	NumberRecordsFailed     int64   `json:"numberRecordsFailed"`
+	Retries                 int64   `json:"retries"`
+	TotalProcessingTime     int64   `json:"totalProcessingTime"`
+	ApiActiveProcessingTime int64   `json:"apiActiveProcessingTime"`
+	ApexProcessingTime      int64   `json:"apexProcessingTime"`
+}
+
+// Done reports whether the job has stopped, successfully or not.
+func (job *BulkJobInfo) Done() bool {
+	return job.State == BulkJobComplete || job.State == BulkJobFailed || job.State == BulkJobAborted
+}
+
+// BulkOption configures a Bulk API 2.0 job.
+type BulkOption func(*bulkOptions) error
+
+type bulkOptions struct {
+	externalIdFieldName string
+	lineEnding          string
+	assignmentRuleId    string
+	queryAll            bool
+	maxRecords          int
+	concurrencyMode     string
+	pkChunkSize         int
+	minPollInterval     time.Duration
+	maxPollInterval     time.Duration
+}
+
+func newBulkOptions(opts []BulkOption) (*bulkOptions, error) {
+	options := &bulkOptions{
+		lineEnding:      "LF",
+		minPollInterval: defaultBulkPollInterval,
+		maxPollInterval: defaultBulkMaxPollInterval,
+	}
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+// BulkExternalIdField names the external id field upserts match records by.
+// It defaults to the ExternalIdApiName of the upserted records.
+func BulkExternalIdField(name string) BulkOption {
+	return func(options *bulkOptions) error {
+		options.externalIdFieldName = name
+		return nil
+	}
+}
+
+// BulkLineEnding sets the line ending of uploaded CSV, "LF" or "CRLF". It
+// defaults to LF, as written for SObject records.
+func BulkLineEnding(lineEnding string) BulkOption {
+	return func(options *bulkOptions) error {
+		if lineEnding != "LF" && lineEnding != "CRLF" {
+			return fmt.Errorf("force: invalid line ending %q", lineEnding)
+		}
+		options.lineEnding = lineEnding
+		return nil
+	}
+}
+
+// BulkAssignmentRuleId applies the case or lead assignment rule id to the
+// records.
+func BulkAssignmentRuleId(id string) BulkOption {
+	return func(options *bulkOptions) error {
+		options.assignmentRuleId = id
+		return nil
+	}
+}
+
+// BulkPollInterval sets how often the state of a job is polled while waiting
+// for it: first after min, then at doubling intervals of at most max. The
+// defaults are 1s and 30s.
+func BulkPollInterval(min, max time.Duration) BulkOption {
+	return func(options *bulkOptions) error {
+		if min <= 0 || max < min {
+			return fmt.Errorf("force: invalid bulk poll interval %v to %v", min, max)
+		}
+		options.minPollInterval, options.maxPollInterval = min, max
+		return nil
+	}
+}
+
+// BulkRecordResult is a row of the results of an ingest job: the record as
+// uploaded in Fields, along with its id and whether it was created for
+// successful records, or its error for failed ones.
+type BulkRecordResult struct {
+	Id      string
+	Created bool
+	Error   string
+	Fields  map[string]string
+}
+
+// BulkIngestResult is the outcome of an ingest job.
+type BulkIngestResult struct {
+	Job         *BulkJobInfo
+	Successful  []*BulkRecordResult
+	Failed      []*BulkRecordResult
+	Unprocessed []*BulkRecordResult
+}
+
+// BulkIngest runs a Bulk API 2.0 ingest job applying mode (BULK_INSERT,
+// BULK_UPDATE, BULK_UPSERT, BULK_DELETE or BULK_HARD_DELETE) to the records
+// in of the sobject table, and returns its results once it is done. The
+// records are uploaded as CSV; deletes only send their Id.
+//
+// The job is aborted if the upload fails. If the job itself fails the error
+// says why and the result still holds the job and any record results.
+func (forceApi *ForceApi) BulkIngest(mode bulkMode, table string, in []SObject, opts ...BulkOption) (*BulkIngestResult, error) {
+	return forceApi.BulkIngestContext(context.Background(), mode, table, in, opts...)
+}
+
+// BulkIngestContext is like BulkIngest but every request and the wait
+// between polls are bound to ctx.
+func (forceApi *ForceApi) BulkIngestContext(ctx context.Context, mode bulkMode, table string, in []SObject, opts ...BulkOption) (*BulkIngestResult, error) {
+	if len(in) == 0 {
+		return nil, errors.New("force: bulk ingest without records")
+	}
+
+	if mode == BULK_UPSERT {
+		opts = append([]BulkOption{BulkExternalIdField(in[0].ExternalIdApiName())}, opts...)
+	}
+
+	data, err := encodeSObjectsCSV(in, mode == BULK_DELETE || mode == BULK_HARD_DELETE)
+	if err != nil {
+		return nil, err
+	}
+
+	return forceApi.BulkIngestCSVContext(ctx, mode, table, bytes.NewReader(data), opts...)
+}
+
+// BulkIngestCSV is like BulkIngest but uploads the CSV read from r, whose
+// header row names the fields of the sobject table.
+func (forceApi *ForceApi) BulkIngestCSV(mode bulkMode, table string, r io.Reader, opts ...BulkOption) (*BulkIngestResult, error) {
+	return forceApi.BulkIngestCSVContext(context.Background(), mode, table, r, opts...)
+}
+
+// BulkIngestCSVContext is like BulkIngestCSV but every request and the wait
+// between polls are bound to ctx.
+func (forceApi *ForceApi) BulkIngestCSVContext(ctx context.Context, mode bulkMode, table string, r io.Reader, opts ...BulkOption) (*BulkIngestResult, error) {
+	options, err := newBulkOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The upload is read before creating the job, so that a failed read does
+	// not leave one behind.
+	data, err := readBulkUpload(r)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := forceApi.createIngestJob(ctx, mode, table, options)
+	if err != nil {
+		return nil, err
+	}
+	job := &BulkJob{forceApi: forceApi, options: options, info: info}
+
+	if err := job.UploadContext(ctx, bytes.NewReader(data)); err != nil {
+		job.AbortContext(context.WithoutCancel(ctx))
+		return nil, err
+	}
+	if err := job.CloseContext(ctx); err != nil {
+		job.AbortContext(context.WithoutCancel(ctx))
+		return nil, err
+	}
+
+	if _, err := job.WaitContext(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	return job.ResultsContext(ctx)
+}
+
+// readBulkUpload reads an upload whole, so that it can be sent again on
+// retries.
+func readBulkUpload(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxIngestUploadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read bulk upload: %v", err)
+	}
+	if len(data) > maxIngestUploadSize {
+		return nil, fmt.Errorf("force: bulk upload is larger than %v bytes", maxIngestUploadSize)
+	}
+
+	return data, nil
+}
+
+// bulkJobUri returns the uri of the Bulk API 2.0 job jobId of the given kind,
+// ingestJobsUri or queryJobsUri, or of all jobs of the kind if jobId is empty.
+func (forceApi *ForceApi) bulkJobUri(kind, jobId string) string {
+	uri := fmt.Sprintf(resourcesUri, forceApi.apiVersion) + kind
+	if jobId != "" {
+		uri += "/" + jobId
+	}
+
+	return uri
+}
+
+func (forceApi *ForceApi) createIngestJob(ctx context.Context, mode bulkMode, table string, options *bulkOptions) (*BulkJobInfo, error) {
+	if mode.String() == "" {
+		return nil, fmt.Errorf("force: invalid bulk mode %v", int(mode))
+	}
+	if _, ok := forceApi.sObjectMetaData(table); !ok {
+		return nil, fmt.Errorf("Unable to find metadata for object: %v", table)
+	}
+	if mode == BULK_UPSERT && options.externalIdFieldName == "" {
+		return nil, fmt.Errorf("force: bulk upsert of %v without an external id field", table)
+	}
+
+	req := map[string]string{
+		"object":      table,
+		"operation":   mode.String(),
+		"contentType": "CSV",
+		"lineEnding":  options.lineEnding,
+	}
+	if mode == BULK_UPSERT {
+		req["externalIdFieldName"] = options.externalIdFieldName
+	}
+	if options.assignmentRuleId != "" {
+		req["assignmentRuleId"] = options.assignmentRuleId
+	}
+
+	job := &BulkJobInfo{}
+	if err := forceApi.PostContext(ctx, forceApi.bulkJobUri(ingestJobsUri, ""), nil, req, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (forceApi *ForceApi) uploadIngestJobData(ctx context.Context, jobId string, data []byte) error {
+	return forceApi.do(ctx, &apiRequest{
+		method:      "PUT",
+		path:        forceApi.bulkJobUri(ingestJobsUri, jobId) + "/batches",
+		body:        data,
+		contentType: csvContentType,
+	}, nil)
+}
+
+func (forceApi *ForceApi) setIngestJobState(ctx context.Context, jobId, state string) (*BulkJobInfo, error) {
+	job := &BulkJobInfo{}
+	if err := forceApi.PatchContext(ctx, forceApi.bulkJobUri(ingestJobsUri, jobId), nil, map[string]string{"state": state}, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (forceApi *ForceApi) getBulkJob(ctx context.Context, uri string) (*BulkJobInfo, error) {
+	job := &BulkJobInfo{}
+	if err := forceApi.GetContext(ctx, uri, nil, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// waitForBulkJob polls the job at uri until it is done, passing every state
+// read to progress if it is not nil.
+func (forceApi *ForceApi) waitForBulkJob(ctx context.Context, uri string, options *bulkOptions, progress func(*BulkJobInfo)) (*BulkJobInfo, error) {
+	interval := options.minPollInterval
+	for {
+		if err := sleepContext(ctx, interval); err != nil {
+			return nil, err
+		}
+
+		job, err := forceApi.getBulkJob(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(job)
+		}
+		if job.Done() {
+			return job, nil
+		}
+
+		if interval *= 2; interval > options.maxPollInterval {
+			interval = options.maxPollInterval
+		}
+	}
+}
+
+// ingestJobResults fetches the record results of a job that is done.
+func (forceApi *ForceApi) ingestJobResults(ctx context.Context, job *BulkJobInfo) (*BulkIngestResult, error) {
+	result := &BulkIngestResult{Job: job}
+	if job.State == BulkJobAborted {
+		return result, fmt.Errorf("force: bulk job %v was aborted", job.Id)
+	}
+
+	var err error
+	if result.Successful, err = forceApi.BulkSuccessfulResultsContext(ctx, job.Id); err != nil {
+		return nil, err
+	}
+	if result.Failed, err = forceApi.BulkFailedResultsContext(ctx, job.Id); err != nil {
+		return nil, err
+	}
+	if result.Unprocessed, err = forceApi.BulkUnprocessedRecordsContext(ctx, job.Id); err != nil {
+		return nil, err
+	}
+
+	if job.State == BulkJobFailed {
+		return result, fmt.Errorf("force: bulk job %v failed: %v", job.Id, job.ErrorMessage)
+	}
+
+	return result, nil
+}
+
+// BulkSuccessfulResults returns the records an ingest job processed
+// successfully.
+func (forceApi *ForceApi) BulkSuccessfulResults(jobId string) ([]*BulkRecordResult, error) {
+	return forceApi.BulkSuccessfulResultsContext(context.Background(), jobId)
+}
+
+// BulkSuccessfulResultsContext is like BulkSuccessfulResults but the request
+// is bound to ctx.
+func (forceApi *ForceApi) BulkSuccessfulResultsContext(ctx context.Context, jobId string) ([]*BulkRecordResult, error) {
+	return forceApi.ingestRecordResults(ctx, jobId, "successfulResults")
+}
+
+// BulkFailedResults returns the records an ingest job failed to process.
+func (forceApi *ForceApi) BulkFailedResults(jobId string) ([]*BulkRecordResult, error) {
+	return forceApi.BulkFailedResultsContext(context.Background(), jobId)
+}
+
+// BulkFailedResultsContext is like BulkFailedResults but the request is
+// bound to ctx.
+func (forceApi *ForceApi) BulkFailedResultsContext(ctx context.Context, jobId string) ([]*BulkRecordResult, error) {
+	return forceApi.ingestRecordResults(ctx, jobId, "failedResults")
+}
+
+// BulkUnprocessedRecords returns the records an ingest job did not process,
+// e.g. because it failed or was aborted.
+func (forceApi *ForceApi) BulkUnprocessedRecords(jobId string) ([]*BulkRecordResult, error) {
+	return forceApi.BulkUnprocessedRecordsContext(context.Background(), jobId)
+}
+
+// BulkUnprocessedRecordsContext is like BulkUnprocessedRecords but the
+// request is bound to ctx.
+func (forceApi *ForceApi) BulkUnprocessedRecordsContext(ctx context.Context, jobId string) ([]*BulkRecordResult, error) {
+	return forceApi.ingestRecordResults(ctx, jobId, "unprocessedrecords")
+}
+
+func (forceApi *ForceApi) ingestRecordResults(ctx context.Context, jobId, kind string) ([]*BulkRecordResult, error) {
+	_, data, err := forceApi.getCSV(ctx, forceApi.bulkJobUri(ingestJobsUri, jobId)+"/"+kind+"/", nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := newCSVRows(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*BulkRecordResult{}
+	for {
+		row, err := rows.next()
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result := &BulkRecordResult{Id: row["sf__Id"], Error: row["sf__Error"], Fields: row}
+		result.Created, _ = strconv.ParseBool(row["sf__Created"])
+		delete(row, "sf__Id")
+		delete(row, "sf__Created")
+		delete(row, "sf__Error")
+		results = append(results, result)
+	}
+}
+
+// getCSV issues a GET for a CSV resource and returns the response along with
+// its body, or with the body unread if stream is set.
+func (forceApi *ForceApi) getCSV(ctx context.Context, path string, params url.Values, stream bool) (*http.Response, []byte, error) {
+	resp, body, err := forceApi.send(ctx, &apiRequest{
+		method:      "GET",
+		path:        path,
+		params:      params,
+		header:      http.Header{"Accept": {csvContentType}},
+		contentType: jsonContentType,
+		stream:      stream,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		if apiErrors := parseApiErrors(body); apiErrors != nil {
+			return nil, nil, apiErrors
+		}
+		return nil, nil, fmt.Errorf("force: GET %v failed with status %v", path, resp.StatusCode)
+	}
+
+	return resp, body, nil
+}
@@ -0,0 +1,186 @@
+package force
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeIngestJobs serves Bulk API 2.0 ingest jobs that complete after being
+// polled once, answering with the given results.
+type fakeIngestJobs struct {
+	mu      sync.Mutex
+	created map[string]string
+	upload  string
+	states  []string
+	polls   int
+
+	failUpload bool
+
+	successful, failed, unprocessed string
+}
+
+func newFakeIngestJobs(t *testing.T, org *fakeOrg) *fakeIngestJobs {
+	jobs := &fakeIngestJobs{}
+	org.HandleJSON("/services/data/v36.0/jobs/ingest", func(r *http.Request) interface{} {
+		jobs.mu.Lock()
+		defer jobs.mu.Unlock()
+		if err := json.NewDecoder(r.Body).Decode(&jobs.created); err != nil {
+			t.Errorf("Unable to decode job: %v", err)
+		}
+		return &BulkJobInfo{Id: "750A", Object: jobs.created["object"], Operation: jobs.created["operation"], State: BulkJobOpen}
+	})
+	org.mux.HandleFunc("/services/data/v36.0/jobs/ingest/750A/", func(w http.ResponseWriter, r *http.Request) {
+		jobs.mu.Lock()
+		defer jobs.mu.Unlock()
+
+		kind := strings.TrimPrefix(r.URL.Path, "/services/data/v36.0/jobs/ingest/750A/")
+		switch {
+		case kind == "batches" && r.Method == "PUT":
+			if r.Header.Get("Content-Type") != csvContentType {
+				t.Errorf("Unexpected upload content type %v", r.Header.Get("Content-Type"))
+			}
+			if jobs.failUpload {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `[{"errorCode":"INVALIDJOBSTATE","message":"Job is not open"}]`)
+				return
+			}
+			data, _ := io.ReadAll(r.Body)
+			jobs.upload = string(data)
+			w.WriteHeader(http.StatusCreated)
+		case kind == "successfulResults/":
+			io.WriteString(w, jobs.successful)
+		case kind == "failedResults/":
+			io.WriteString(w, jobs.failed)
+		case kind == "unprocessedrecords/":
+			io.WriteString(w, jobs.unprocessed)
+		default:
+			t.Errorf("Unexpected request %v %v", r.Method, r.URL)
+		}
+	})
+	org.HandleJSON("/services/data/v36.0/jobs/ingest/750A", func(r *http.Request) interface{} {
+		jobs.mu.Lock()
+		defer jobs.mu.Unlock()
+
+		switch r.Method {
+		case "PATCH":
+			state := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&state)
+			jobs.states = append(jobs.states, state["state"])
+			return &BulkJobInfo{Id: "750A", State: state["state"]}
+		default:
+			jobs.polls++
+			if jobs.polls == 1 {
+				return &BulkJobInfo{Id: "750A", State: BulkJobInProgress}
+			}
+			return &BulkJobInfo{Id: "750A", State: BulkJobComplete, NumberRecordsProcessed: 3, NumberRecordsFailed: 1}
+		}
+	})
+
+	return jobs
+}
+
+func TestBulkIngest(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeIngestJobs(t, org)
+	jobs.successful = "sf__Id,sf__Created,BillingCity,AccountNumber\n001A,true,Oslo,A-1\n001B,false,\"Rome, IT\",A-2\n"
+	jobs.failed = "sf__Id,sf__Error,BillingCity,AccountNumber\n,REQUIRED_FIELD_MISSING:Required fields are missing: [Name]:Name --,,A-3\n"
+	jobs.unprocessed = "BillingCity,AccountNumber\n"
+	forceApi := org.New(t)
+
+	in := []SObject{
+		&testExternalAccount{testCompositeAccount{BillingCity: "Oslo"}, "A-1"},
+		&testExternalAccount{testCompositeAccount{BillingCity: "Rome, IT"}, "A-2"},
+		&testExternalAccount{AccountNumber: "A-3"},
+	}
+	result, err := forceApi.BulkIngest(BULK_UPSERT, "Account", in, BulkPollInterval(time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatalf("BulkIngest failed: %v", err)
+	}
+
+	if jobs.created["operation"] != "upsert" || jobs.created["externalIdFieldName"] != "AccountNumber" || jobs.created["contentType"] != "CSV" {
+		t.Fatalf("Unexpected job %v", jobs.created)
+	}
+	if jobs.upload != "BillingCity,AccountNumber\nOslo,A-1\n\"Rome, IT\",A-2\n,A-3\n" {
+		t.Fatalf("Unexpected upload %q", jobs.upload)
+	}
+	if strings.Join(jobs.states, ",") != BulkJobUploadComplete || jobs.polls != 2 {
+		t.Fatalf("Expected the upload to be completed and the job polled twice, got %v and %v polls", jobs.states, jobs.polls)
+	}
+
+	if result.Job.State != BulkJobComplete || len(result.Successful) != 2 || len(result.Failed) != 1 || len(result.Unprocessed) != 0 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	if s := result.Successful[1]; s.Id != "001B" || s.Created || s.Fields["BillingCity"] != "Rome, IT" || len(s.Fields) != 2 {
+		t.Fatalf("Unexpected successful record %+v", s)
+	}
+	if f := result.Failed[0]; !strings.HasPrefix(f.Error, "REQUIRED_FIELD_MISSING") || f.Fields["AccountNumber"] != "A-3" {
+		t.Fatalf("Unexpected failed record %+v", f)
+	}
+}
+
+func TestBulkIngestDeleteSendsIds(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeIngestJobs(t, org)
+	forceApi := org.New(t)
+
+	account := &testCompositeAccount{BillingCity: "Oslo"}
+	account.Id = "001A"
+	if _, err := forceApi.BulkIngest(BULK_HARD_DELETE, "Account", []SObject{account}, BulkPollInterval(time.Millisecond, time.Millisecond)); err != nil {
+		t.Fatalf("BulkIngest failed: %v", err)
+	}
+	if jobs.created["operation"] != "hardDelete" || jobs.upload != "Id\n001A\n" {
+		t.Fatalf("Unexpected job %v with upload %q", jobs.created, jobs.upload)
+	}
+
+	jobs.created = nil
+	_, err := forceApi.BulkIngest(BULK_DELETE, "Account", []SObject{account, &testCompositeAccount{}})
+	if err == nil || !strings.Contains(err.Error(), "Account record 1 has no Id") || jobs.created != nil {
+		t.Fatalf("Expected the record without an Id to be named before creating a job, got %v", err)
+	}
+}
+
+func TestBulkIngestAbortsFailedUpload(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeIngestJobs(t, org)
+	jobs.failUpload = true
+	forceApi := org.New(t)
+
+	_, err := forceApi.BulkIngestCSV(BULK_INSERT, "Account", strings.NewReader("Name\nAcme\n"))
+	if apiErrors, ok := err.(ApiErrors); !ok || apiErrors[0].ErrorCode != "INVALIDJOBSTATE" {
+		t.Fatalf("Expected the upload error, got %v", err)
+	}
+	if strings.Join(jobs.states, ",") != BulkJobAborted {
+		t.Fatalf("Expected the job to be aborted, got %v", jobs.states)
+	}
+}
+
+func TestBulkIngestInvalid(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeIngestJobs(t, org)
+	forceApi := org.New(t)
+
+	_, err := forceApi.BulkIngestCSV(BULK_INSERT, "Account", strings.NewReader("Name\nAcme\n"), BulkLineEnding("CR"))
+	if err == nil {
+		t.Fatal("Expected an error for an invalid line ending")
+	}
+
+	failing := &failingReader{}
+	if _, err := forceApi.BulkIngestCSV(BULK_INSERT, "Account", failing); err == nil || jobs.created != nil {
+		t.Fatalf("Expected a failed read to fail before creating a job, got %v", err)
+	}
+
+	if _, err := forceApi.BulkIngestCSV(BULK_UPSERT, "Account", strings.NewReader("Name\nAcme\n")); err == nil {
+		t.Fatal("Expected an error for an upsert without an external id field")
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
@@ -0,0 +1,238 @@
+package force
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// BulkJob is a handle on a Bulk API 2.0 ingest job. Only its id needs to be
+// kept for a job to be resumed with ResumeBulkJob, e.g. by another process
+// once a load has been uploaded:
+//
+//	job, err := forceApi.CreateBulkJob(force.BULK_INSERT, "Account")
+//	...
+//	if err := job.Upload(csv); err != nil {
+//		job.Abort()
+//		return err
+//	}
+//	if err := job.Close(); err != nil {
+//		return err
+//	}
+//	save(job.Id())
+//
+// and later
+//
+//	job, err := forceApi.ResumeBulkJob(load())
+//	...
+//	progress := make(chan *force.BulkJobInfo)
+//	go func() {
+//		for info := range progress {
+//			log.Printf("%v records processed, %v failed", info.NumberRecordsProcessed, info.NumberRecordsFailed)
+//		}
+//	}()
+//	if _, err := job.Wait(progress); err != nil {
+//		return err
+//	}
+//	result, err := job.Results()
+//
+// A BulkJob is not safe for concurrent use.
+type BulkJob struct {
+	forceApi *ForceApi
+	options  *bulkOptions
+	info     *BulkJobInfo
+}
+
+// CreateBulkJob creates an ingest job applying mode to records of the sobject
+// table, ready for its data to be uploaded.
+func (forceApi *ForceApi) CreateBulkJob(mode bulkMode, table string, opts ...BulkOption) (*BulkJob, error) {
+	return forceApi.CreateBulkJobContext(context.Background(), mode, table, opts...)
+}
+
+// CreateBulkJobContext is like CreateBulkJob but the request is bound to ctx.
+func (forceApi *ForceApi) CreateBulkJobContext(ctx context.Context, mode bulkMode, table string, opts ...BulkOption) (*BulkJob, error) {
+	options, err := newBulkOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := forceApi.createIngestJob(ctx, mode, table, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BulkJob{forceApi: forceApi, options: options, info: info}, nil
+}
+
+// ResumeBulkJob returns a handle on the existing ingest job jobId. Only the
+// options about waiting apply.
+func (forceApi *ForceApi) ResumeBulkJob(jobId string, opts ...BulkOption) (*BulkJob, error) {
+	return forceApi.ResumeBulkJobContext(context.Background(), jobId, opts...)
+}
+
+// ResumeBulkJobContext is like ResumeBulkJob but the request is bound to ctx.
+func (forceApi *ForceApi) ResumeBulkJobContext(ctx context.Context, jobId string, opts ...BulkOption) (*BulkJob, error) {
+	options, err := newBulkOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := forceApi.getBulkJob(ctx, forceApi.bulkJobUri(ingestJobsUri, jobId))
+	if err != nil {
+		return nil, err
+	}
+
+	return &BulkJob{forceApi: forceApi, options: options, info: info}, nil
+}
+
+// ListBulkJobs returns every ingest job of the org, following the pages of
+// the list.
+func (forceApi *ForceApi) ListBulkJobs() ([]*BulkJobInfo, error) {
+	return forceApi.ListBulkJobsContext(context.Background())
+}
+
+// ListBulkJobsContext is like ListBulkJobs but every request is bound to ctx.
+func (forceApi *ForceApi) ListBulkJobsContext(ctx context.Context) ([]*BulkJobInfo, error) {
+	jobs := []*BulkJobInfo{}
+	uri := forceApi.bulkJobUri(ingestJobsUri, "")
+	for uri != "" {
+		page := &struct {
+			Done           bool           `json:"done"`
+			NextRecordsUrl string         `json:"nextRecordsUrl"`
+			Records        []*BulkJobInfo `json:"records"`
+		}{}
+		if err := forceApi.GetContext(ctx, uri, nil, page); err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, page.Records...)
+		uri = ""
+		if !page.Done {
+			uri = page.NextRecordsUrl
+		}
+	}
+
+	return jobs, nil
+}
+
+// Id returns the id of the job.
+func (job *BulkJob) Id() string {
+	return job.info.Id
+}
+
+// Info returns the job as last read, by creating, resuming, changing the
+// state of or waiting for it.
+func (job *BulkJob) Info() *BulkJobInfo {
+	return job.info
+}
+
+// Upload sends the CSV read from r, whose header row names the fields of the
+// sobject of the job. A job takes a single upload, of at most 100MB.
+func (job *BulkJob) Upload(r io.Reader) error {
+	return job.UploadContext(context.Background(), r)
+}
+
+// UploadContext is like Upload but the request is bound to ctx.
+func (job *BulkJob) UploadContext(ctx context.Context, r io.Reader) error {
+	data, err := readBulkUpload(r)
+	if err != nil {
+		return err
+	}
+
+	return job.forceApi.uploadIngestJobData(ctx, job.info.Id, data)
+}
+
+// Close marks the upload of the job complete, which queues it for
+// processing.
+func (job *BulkJob) Close() error {
+	return job.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but the request is bound to ctx.
+func (job *BulkJob) CloseContext(ctx context.Context) error {
+	return job.setState(ctx, BulkJobUploadComplete)
+}
+
+// Abort stops the job. Records it has already processed are not rolled back.
+func (job *BulkJob) Abort() error {
+	return job.AbortContext(context.Background())
+}
+
+// AbortContext is like Abort but the request is bound to ctx.
+func (job *BulkJob) AbortContext(ctx context.Context) error {
+	return job.setState(ctx, BulkJobAborted)
+}
+
+func (job *BulkJob) setState(ctx context.Context, state string) error {
+	info, err := job.forceApi.setIngestJobState(ctx, job.info.Id, state)
+	if err != nil {
+		return err
+	}
+
+	job.info = info
+	return nil
+}
+
+// Refresh reads the current state of the job.
+func (job *BulkJob) Refresh() (*BulkJobInfo, error) {
+	return job.RefreshContext(context.Background())
+}
+
+// RefreshContext is like Refresh but the request is bound to ctx.
+func (job *BulkJob) RefreshContext(ctx context.Context) (*BulkJobInfo, error) {
+	info, err := job.forceApi.getBulkJob(ctx, job.forceApi.bulkJobUri(ingestJobsUri, job.info.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	job.info = info
+	return info, nil
+}
+
+// Wait polls the job until it is done and returns its final state, which
+// tells whether it completed, failed or was aborted. Every state read is sent
+// to progress, unless it is nil, and progress is closed when Wait returns.
+func (job *BulkJob) Wait(progress chan<- *BulkJobInfo) (*BulkJobInfo, error) {
+	return job.WaitContext(context.Background(), progress)
+}
+
+// WaitContext is like Wait but every request, the wait between polls and
+// the sends to progress are bound to ctx.
+func (job *BulkJob) WaitContext(ctx context.Context, progress chan<- *BulkJobInfo) (*BulkJobInfo, error) {
+	if progress != nil {
+		defer close(progress)
+	}
+	if job.info.Done() {
+		return job.info, nil
+	}
+
+	info, err := job.forceApi.waitForBulkJob(ctx, job.forceApi.bulkJobUri(ingestJobsUri, job.info.Id), job.options, func(info *BulkJobInfo) {
+		if progress != nil {
+			select {
+			case progress <- info:
+			case <-ctx.Done():
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	job.info = info
+	return info, nil
+}
+
+// Results returns the record results of the job once it is done. If the job
+// failed or was aborted the error says so, along with the results.
+func (job *BulkJob) Results() (*BulkIngestResult, error) {
+	return job.ResultsContext(context.Background())
+}
+
+// ResultsContext is like Results but every request is bound to ctx.
+func (job *BulkJob) ResultsContext(ctx context.Context) (*BulkIngestResult, error) {
+	if !job.info.Done() {
+		return nil, fmt.Errorf("force: bulk job %v is %v", job.info.Id, job.info.State)
+	}
+
+	return job.forceApi.ingestJobResults(ctx, job.info)
+}
@@ -0,0 +1,100 @@
+package force
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBulkJob(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeIngestJobs(t, org)
+	jobs.successful = "sf__Id,sf__Created,Name\n001A,true,Acme\n"
+	forceApi := org.New(t)
+
+	job, err := forceApi.CreateBulkJob(BULK_INSERT, "Account")
+	if err != nil {
+		t.Fatalf("CreateBulkJob failed: %v", err)
+	}
+	if err := job.Upload(strings.NewReader("Name\nAcme\n")); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if err := job.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if job.Id() != "750A" || job.Info().State != BulkJobUploadComplete || jobs.upload != "Name\nAcme\n" {
+		t.Fatalf("Unexpected job %+v with upload %q", job.Info(), jobs.upload)
+	}
+	if _, err := job.Results(); err == nil {
+		t.Fatal("Expected an error for the results of a job that is not done")
+	}
+
+	// Resume the job as another process would.
+	job, err = forceApi.ResumeBulkJob(job.Id(), BulkPollInterval(time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatalf("ResumeBulkJob failed: %v", err)
+	}
+	if job.Info().State != BulkJobInProgress {
+		t.Fatalf("Unexpected resumed job %+v", job.Info())
+	}
+
+	progress := make(chan *BulkJobInfo)
+	var states []*BulkJobInfo
+	done := make(chan struct{})
+	go func() {
+		for info := range progress {
+			states = append(states, info)
+		}
+		close(done)
+	}()
+	info, err := job.Wait(progress)
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	<-done
+	if info.State != BulkJobComplete || len(states) != 1 || states[0].NumberRecordsProcessed != 3 || states[0].NumberRecordsFailed != 1 {
+		t.Fatalf("Unexpected progress %+v", states)
+	}
+
+	result, err := job.Results()
+	if err != nil {
+		t.Fatalf("Results failed: %v", err)
+	}
+	if len(result.Successful) != 1 || result.Successful[0].Id != "001A" {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+
+	if err := job.Abort(); err != nil || job.Info().State != BulkJobAborted {
+		t.Fatalf("Abort failed: %v", err)
+	}
+	if strings.Join(jobs.states, ",") != BulkJobUploadComplete+","+BulkJobAborted {
+		t.Fatalf("Unexpected state changes %v", jobs.states)
+	}
+}
+
+func TestListBulkJobs(t *testing.T) {
+	org := newFakeOrg(t)
+	org.HandleJSON("/services/data/v36.0/jobs/ingest", func(r *http.Request) interface{} {
+		if r.URL.Query().Get("queryLocator") == "" {
+			return map[string]interface{}{
+				"done":           false,
+				"nextRecordsUrl": "/services/data/v36.0/jobs/ingest?queryLocator=01gA-1",
+				"records":        []*BulkJobInfo{{Id: "750A", State: BulkJobComplete}},
+			}
+		}
+		return map[string]interface{}{
+			"done":    true,
+			"records": []*BulkJobInfo{{Id: "750B", State: BulkJobOpen}},
+		}
+	})
+	forceApi := org.New(t)
+
+	jobs, err := forceApi.ListBulkJobs()
+	if err != nil {
+		t.Fatalf("ListBulkJobs failed: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].Id != "750A" || jobs[1].State != BulkJobOpen {
+		t.Fatalf("Unexpected jobs %+v", jobs)
+	}
+}
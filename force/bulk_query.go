@@ -0,0 +1,268 @@
+package force
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/url"
+	"strconv"
+)
+
+const (
+	queryJobsUri = "jobs/query"
+
+	// bulkLocatorHeader holds the locator of the next page of query job
+	// results, or "null" after the last page.
+	bulkLocatorHeader = "Sforce-Locator"
+)
+
+// BulkQueryAll includes records that have been deleted because of a merge or
+// delete, and archived activities, in the results of a query job.
+func BulkQueryAll() BulkOption {
+	return func(options *bulkOptions) error {
+		options.queryAll = true
+		return nil
+	}
+}
+
+// BulkMaxRecords asks for pages of at most n records of query job results.
+// Without it force.com picks the page size, possibly many megabytes.
+func BulkMaxRecords(n int) BulkOption {
+	return func(options *bulkOptions) error {
+		if n <= 0 {
+			return fmt.Errorf("force: invalid bulk max records %v", n)
+		}
+		options.maxRecords = n
+		return nil
+	}
+}
+
+// BulkQueryIterator runs a Bulk API 2.0 query job and walks its results one
+// CSV row at a time, streaming each page of results as it is read.
+//
+//	it := forceApi.BulkQuery("SELECT Id, Name, Owner.Name FROM Account")
+//	defer it.Close()
+//	for it.Next() {
+//		account := &sobjects.Account{}
+//		if err := it.Decode(account); err != nil {
+//			return err
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+//
+// The job is created and waited for by the first call to Next. A
+// BulkQueryIterator is not safe for concurrent use.
+type BulkQueryIterator struct {
+	forceApi *ForceApi
+	ctx      context.Context
+	query    string
+	options  *bulkOptions
+
+	job     *BulkJobInfo
+	locator string
+	last    bool
+	body    io.ReadCloser
+	rows    *csvRows
+	row     map[string]string
+	err     error
+}
+
+// BulkQuery returns an iterator over the results of a query job for query.
+func (forceApi *ForceApi) BulkQuery(query string, opts ...BulkOption) *BulkQueryIterator {
+	return forceApi.BulkQueryContext(context.Background(), query, opts...)
+}
+
+// BulkQueryContext is like BulkQuery but every request and the wait between
+// polls are bound to ctx.
+func (forceApi *ForceApi) BulkQueryContext(ctx context.Context, query string, opts ...BulkOption) *BulkQueryIterator {
+	it := &BulkQueryIterator{
+		forceApi: forceApi,
+		ctx:      ctx,
+		query:    query,
+	}
+	it.options, it.err = newBulkOptions(opts)
+
+	return it
+}
+
+// Next advances to the next row, fetching the next page of results if
+// needed. It returns false when there are no more rows or an error occurred;
+// Err tells the two apart.
+func (it *BulkQueryIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.job == nil && !it.start() {
+		return false
+	}
+
+	for {
+		if it.rows != nil {
+			row, err := it.rows.next()
+			if err == nil {
+				it.row = row
+				return true
+			}
+			it.closeBody()
+			if err != io.EOF {
+				it.err = err
+				return false
+			}
+		}
+
+		it.row = nil
+		if it.last {
+			return false
+		}
+		if !it.fetch() {
+			return false
+		}
+	}
+}
+
+// start creates the job and waits for it to complete.
+func (it *BulkQueryIterator) start() bool {
+	operation := "query"
+	if it.options.queryAll {
+		operation = "queryAll"
+	}
+
+	req := map[string]string{
+		"operation":   operation,
+		"query":       it.query,
+		"contentType": "CSV",
+		"lineEnding":  it.options.lineEnding,
+	}
+	job := &BulkJobInfo{}
+	if err := it.forceApi.PostContext(it.ctx, it.forceApi.bulkJobUri(queryJobsUri, ""), nil, req, job); err != nil {
+		it.err = err
+		return false
+	}
+
+	job, err := it.forceApi.waitForBulkJob(it.ctx, it.forceApi.bulkJobUri(queryJobsUri, job.Id), it.options, nil)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.job = job
+
+	switch job.State {
+	case BulkJobComplete:
+		return true
+	case BulkJobFailed:
+		it.err = fmt.Errorf("force: bulk job %v failed: %v", job.Id, job.ErrorMessage)
+	default:
+		it.err = fmt.Errorf("force: bulk job %v was aborted", job.Id)
+	}
+
+	return false
+}
+
+// fetch opens the next page of results.
+func (it *BulkQueryIterator) fetch() bool {
+	params := url.Values{}
+	if it.locator != "" {
+		params.Set("locator", it.locator)
+	}
+	if it.options.maxRecords > 0 {
+		params.Set("maxRecords", strconv.Itoa(it.options.maxRecords))
+	}
+
+	resp, _, err := it.forceApi.getCSV(it.ctx, it.forceApi.bulkJobUri(queryJobsUri, it.job.Id)+"/results", params, true)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.body = resp.Body
+
+	it.locator = resp.Header.Get(bulkLocatorHeader)
+	it.last = it.locator == "" || it.locator == "null"
+
+	if it.rows, err = newCSVRows(it.body); err != nil {
+		it.closeBody()
+		it.err = err
+		return false
+	}
+
+	return true
+}
+
+func (it *BulkQueryIterator) closeBody() {
+	if it.body != nil {
+		it.body.Close()
+		it.body = nil
+	}
+	it.rows = nil
+}
+
+// Row returns the current row as a map from column to value. Values are
+// empty for null fields.
+func (it *BulkQueryIterator) Row() map[string]string {
+	return it.row
+}
+
+// Decode copies the current row into out, a pointer to a map[string]string
+// or to a struct whose fields are named by force tags. Columns of parent
+// fields, such as Owner.Name, are decoded into the struct held by the field
+// of the relationship.
+func (it *BulkQueryIterator) Decode(out interface{}) error {
+	if it.row == nil {
+		return errors.New("force: Decode called without a current row")
+	}
+
+	return decodeCSVRow(it.row, out)
+}
+
+// Job returns the query job, or nil until Next has been called.
+func (it *BulkQueryIterator) Job() *BulkJobInfo {
+	return it.job
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *BulkQueryIterator) Err() error {
+	return it.err
+}
+
+// Close releases the page of results being read. It need only be called if
+// the iteration is stopped before Next returns false.
+func (it *BulkQueryIterator) Close() error {
+	it.closeBody()
+	it.row = nil
+	it.last = true
+
+	return nil
+}
+
+// BulkRecords returns an iter.Seq2 over the remaining rows of it decoded into
+// T. An error, either fetching a page or decoding a row, is yielded once with
+// the zero T and ends the sequence. it is closed when the sequence ends.
+//
+//	for account, err := range force.BulkRecords[sobjects.Account](it) {
+//		...
+//	}
+func BulkRecords[T any](it *BulkQueryIterator) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer it.Close()
+
+		for it.Next() {
+			var record T
+			if err := it.Decode(&record); err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if !yield(record, nil) {
+				return
+			}
+		}
+
+		if err := it.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package force
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testBulkAccount struct {
+	Id                string
+	Name              string
+	NumberOfEmployees int
+	AnnualRevenue     *float64
+	Active            bool `force:"IsActive__c"`
+	CreatedDate       time.Time
+	Owner             *struct {
+		Name string
+	}
+}
+
+// fakeQueryJobs serves a Bulk API 2.0 query job that completes after being
+// polled once, with pages of results chained by locators.
+type fakeQueryJobs struct {
+	mu      sync.Mutex
+	created map[string]string
+	pages   []string
+	params  []string
+}
+
+func newFakeQueryJobs(t *testing.T, org *fakeOrg, pages ...string) *fakeQueryJobs {
+	jobs := &fakeQueryJobs{pages: pages}
+	org.HandleJSON("/services/data/v36.0/jobs/query", func(r *http.Request) interface{} {
+		jobs.mu.Lock()
+		defer jobs.mu.Unlock()
+		if err := json.NewDecoder(r.Body).Decode(&jobs.created); err != nil {
+			t.Errorf("Unable to decode job: %v", err)
+		}
+		return &BulkJobInfo{Id: "750Q", Operation: jobs.created["operation"], State: BulkJobUploadComplete}
+	})
+	org.HandleJSON("/services/data/v36.0/jobs/query/750Q", func(r *http.Request) interface{} {
+		return &BulkJobInfo{Id: "750Q", State: BulkJobComplete}
+	})
+	org.mux.HandleFunc("/services/data/v36.0/jobs/query/750Q/results", func(w http.ResponseWriter, r *http.Request) {
+		jobs.mu.Lock()
+		defer jobs.mu.Unlock()
+		if r.Header.Get("Accept") != csvContentType {
+			t.Errorf("Unexpected accept header %v", r.Header.Get("Accept"))
+		}
+		jobs.params = append(jobs.params, r.URL.RawQuery)
+
+		page := len(jobs.params) - 1
+		if page >= len(jobs.pages) {
+			t.Errorf("Unexpected request for page %v", page)
+			return
+		}
+		locator := "null"
+		if page < len(jobs.pages)-1 {
+			locator = "L" + string(rune('1'+page))
+		}
+		w.Header().Set(bulkLocatorHeader, locator)
+		w.Header().Set("Content-Type", csvContentType)
+		io.WriteString(w, jobs.pages[page])
+	})
+
+	return jobs
+}
+
+func TestBulkQuery(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeQueryJobs(t, org,
+		"Id,Name,NumberOfEmployees,AnnualRevenue,IsActive__c,CreatedDate,Owner.Name\n"+
+			"001A,Acme,10,1.5,true,2024-03-01T10:00:00.000Z,Ada\n"+
+			"001B,\"Globex, Inc\",,,false,,\n",
+		"Id,Name,NumberOfEmployees,AnnualRevenue,IsActive__c,CreatedDate,Owner.Name\n"+
+			"001C,Initech,3,,true,2024-03-02T10:00:00.000Z,Bob\n",
+	)
+	forceApi := org.New(t)
+
+	it := forceApi.BulkQuery("SELECT Id FROM Account", BulkMaxRecords(2), BulkPollInterval(time.Millisecond, time.Millisecond))
+	defer it.Close()
+
+	var accounts []*testBulkAccount
+	for it.Next() {
+		account := &testBulkAccount{}
+		if err := it.Decode(account); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		accounts = append(accounts, account)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("BulkQuery failed: %v", err)
+	}
+
+	if jobs.created["operation"] != "query" || jobs.created["query"] != "SELECT Id FROM Account" || jobs.created["contentType"] != "CSV" {
+		t.Fatalf("Unexpected job %v", jobs.created)
+	}
+	if len(jobs.params) != 2 || jobs.params[0] != "maxRecords=2" || jobs.params[1] != "locator=L1&maxRecords=2" {
+		t.Fatalf("Unexpected page requests %v", jobs.params)
+	}
+	if it.Job().Id != "750Q" {
+		t.Fatalf("Unexpected job %+v", it.Job())
+	}
+
+	if len(accounts) != 3 {
+		t.Fatalf("Expected 3 accounts, got %v", len(accounts))
+	}
+	a := accounts[0]
+	if a.Id != "001A" || a.NumberOfEmployees != 10 || a.AnnualRevenue == nil || *a.AnnualRevenue != 1.5 || !a.Active ||
+		!a.CreatedDate.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) || a.Owner == nil || a.Owner.Name != "Ada" {
+		t.Fatalf("Unexpected account %+v", a)
+	}
+	if b := accounts[1]; b.Name != "Globex, Inc" || b.AnnualRevenue != nil || b.Owner != nil || b.Active {
+		t.Fatalf("Expected empty values to leave fields unset, got %+v", b)
+	}
+	if accounts[2].Owner.Name != "Bob" {
+		t.Fatalf("Unexpected account %+v", accounts[2])
+	}
+}
+
+func TestBulkRecordsMap(t *testing.T) {
+	org := newFakeOrg(t)
+	jobs := newFakeQueryJobs(t, org, "Id,Name\n001A,Acme\n001B,Globex\n")
+	forceApi := org.New(t)
+
+	var rows []map[string]string
+	it := forceApi.BulkQuery("SELECT Id, Name FROM Account", BulkQueryAll(), BulkPollInterval(time.Millisecond, time.Millisecond))
+	for row, err := range BulkRecords[map[string]string](it) {
+		if err != nil {
+			t.Fatalf("BulkRecords failed: %v", err)
+		}
+		rows = append(rows, row)
+	}
+
+	if jobs.created["operation"] != "queryAll" {
+		t.Fatalf("Unexpected job %v", jobs.created)
+	}
+	if len(rows) != 2 || rows[1]["Name"] != "Globex" || rows[0]["Id"] != "001A" {
+		t.Fatalf("Unexpected rows %v", rows)
+	}
+}
+
+func TestBulkQueryFailedJob(t *testing.T) {
+	org := newFakeOrg(t)
+	org.HandleJSON("/services/data/v36.0/jobs/query", func(r *http.Request) interface{} {
+		return &BulkJobInfo{Id: "750F", State: BulkJobUploadComplete}
+	})
+	org.HandleJSON("/services/data/v36.0/jobs/query/750F", func(r *http.Request) interface{} {
+		return &BulkJobInfo{Id: "750F", State: BulkJobFailed, ErrorMessage: "INVALID_FIELD"}
+	})
+	forceApi := org.New(t)
+
+	it := forceApi.BulkQuery("SELECT Nope FROM Account", BulkPollInterval(time.Millisecond, time.Millisecond))
+	if it.Next() || it.Err() == nil {
+		t.Fatalf("Expected the failed job to stop the iteration, got %v", it.Err())
+	}
+
+	if it := forceApi.BulkQuery("SELECT Id FROM Account", BulkMaxRecords(0)); it.Next() || it.Err() == nil {
+		t.Fatal("Expected an error for invalid max records")
+	}
+}
@@ -2,6 +2,7 @@ package force
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,67 +10,77 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 const (
-	version         = "1.0.0"
-	userAgent       = "go-force/" + version
-	jsonContentType = "application/json"
-	responseType    = "application/json"
+	version          = "1.0.0"
+	defaultUserAgent = "go-force/" + version
+	jsonContentType  = "application/json"
+	responseType     = "application/json"
 )
 
 // Get issues a GET to the specified path with the given params and put the
 // umarshalled (json) result in the third parameter
 func (forceApi *ForceApi) Get(path string, params url.Values, out interface{}) error {
-	return forceApi.request("GET", path, params, nil, out)
+	return forceApi.GetContext(context.Background(), path, params, out)
+}
+
+// GetContext is like Get but the request is bound to ctx.
+func (forceApi *ForceApi) GetContext(ctx context.Context, path string, params url.Values, out interface{}) error {
+	return forceApi.request(ctx, "GET", path, params, nil, out)
 }
 
 // Post issues a POST to the specified path with the given params and payload
 // and put the unmarshalled (json) result in the third parameter
 func (forceApi *ForceApi) Post(path string, params url.Values, payload, out interface{}) error {
-	return forceApi.request("POST", path, params, payload, out)
+	return forceApi.PostContext(context.Background(), path, params, payload, out)
+}
+
+// PostContext is like Post but the request is bound to ctx.
+func (forceApi *ForceApi) PostContext(ctx context.Context, path string, params url.Values, payload, out interface{}) error {
+	return forceApi.request(ctx, "POST", path, params, payload, out)
 }
 
 // Put issues a PUT to the specified path with the given params and payload
 // and put the unmarshalled (json) result in the third parameter
 func (forceApi *ForceApi) Put(path string, params url.Values, payload, out interface{}) error {
-	return forceApi.request("PUT", path, params, payload, out)
+	return forceApi.PutContext(context.Background(), path, params, payload, out)
+}
+
+// PutContext is like Put but the request is bound to ctx.
+func (forceApi *ForceApi) PutContext(ctx context.Context, path string, params url.Values, payload, out interface{}) error {
+	return forceApi.request(ctx, "PUT", path, params, payload, out)
 }
 
 // Patch issues a PATCH to the specified path with the given params and payload
 // and put the unmarshalled (json) result in the third parameter
 func (forceApi *ForceApi) Patch(path string, params url.Values, payload, out interface{}) error {
-	return forceApi.request("PATCH", path, params, payload, out)
+	return forceApi.PatchContext(context.Background(), path, params, payload, out)
+}
+
+// PatchContext is like Patch but the request is bound to ctx.
+func (forceApi *ForceApi) PatchContext(ctx context.Context, path string, params url.Values, payload, out interface{}) error {
+	return forceApi.request(ctx, "PATCH", path, params, payload, out)
 }
 
 // Delete issues a DELETE to the specified path with the given payload
 func (forceApi *ForceApi) Delete(path string, params url.Values) error {
-	return forceApi.request("DELETE", path, params, nil, nil)
+	return forceApi.DeleteContext(context.Background(), path, params)
 }
 
-func (forceApi *ForceApi) request(method, path string, params url.Values, payload, out interface{}) error {
-	return forceApi.requestWithContentType(method, path, params, payload, out, jsonContentType)
+// DeleteContext is like Delete but the request is bound to ctx.
+func (forceApi *ForceApi) DeleteContext(ctx context.Context, path string, params url.Values) error {
+	return forceApi.request(ctx, "DELETE", path, params, nil, nil)
 }
 
-func (forceApi *ForceApi) requestWithContentType(method, path string, params url.Values, payload, out interface{}, contentType string) error {
-	if err := forceApi.OAuth.Validate(); err != nil {
-		return fmt.Errorf("Error creating %v request: %v", method, err)
-	}
-
-	// Build Uri
-	var uri bytes.Buffer
-	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
-		uri.WriteString(forceApi.OAuth.InstanceUrl)
-	}
-
-	uri.WriteString(path)
-	if params != nil && len(params) != 0 {
-		uri.WriteString("?")
-		uri.WriteString(params.Encode())
-	}
+func (forceApi *ForceApi) request(ctx context.Context, method, path string, params url.Values, payload, out interface{}) error {
+	return forceApi.requestWithContentType(ctx, method, path, params, payload, out, jsonContentType)
+}
 
+func (forceApi *ForceApi) requestWithContentType(ctx context.Context, method, path string, params url.Values, payload, out interface{}, contentType string) error {
 	// Build body
-	var body io.Reader
+	var body []byte
 	if payload != nil {
 		if contentType == jsonContentType {
 			jsonBytes, err := json.Marshal(payload)
@@ -77,43 +88,53 @@ func (forceApi *ForceApi) requestWithContentType(method, path string, params url
 				return fmt.Errorf("Error marshaling encoded payload: %v", err)
 			}
 
-			body = bytes.NewReader(jsonBytes)
+			body = jsonBytes
 		} else {
-			body = bytes.NewReader([]byte(payload.(string)))
+			body = []byte(payload.(string))
 		}
 	}
 
-	// Build Request
-	req, err := http.NewRequest(method, uri.String(), body)
-	if err != nil {
-		return fmt.Errorf("Error creating %v request: %v", method, err)
-	}
-
-	// Add Headers
-	req.Header.Set("User-Agent", userAgent)
-	req.Header.Set("Content-Type", contentType)
-	req.Header.Set("Accept", responseType)
-	req.Header.Set("Authorization", fmt.Sprintf("%v %v", "Bearer", forceApi.OAuth.AccessToken))
-	req.Header.Set("X-SFDC-Session", forceApi.OAuth.AccessToken)
-	// Send
-	forceApi.traceRequest(req)
-	resp, err := http.DefaultClient.Do(req)
+	return forceApi.do(ctx, &apiRequest{
+		method:      method,
+		path:        path,
+		params:      params,
+		body:        body,
+		contentType: contentType,
+	}, out)
+}
+
+// apiRequest describes a request to the force.com api.
+type apiRequest struct {
+	method      string
+	path        string
+	params      url.Values
+	header      http.Header // sent in addition to, or instead of, the standard headers
+	body        []byte
+	contentType string
+
+	// stream leaves the body of a successful response unread, for the caller
+	// to read and close.
+	stream bool
+}
+
+// do sends r and unmarshals the JSON response into out.
+func (forceApi *ForceApi) do(ctx context.Context, r *apiRequest, out interface{}) error {
+	resp, respBytes, err := forceApi.send(ctx, r)
 	if err != nil {
-		return fmt.Errorf("Error sending %v request: %v", method, err)
+		return err
 	}
-	defer resp.Body.Close()
-	forceApi.traceResponse(resp)
 
 	// Sometimes the force API returns no body, we should catch this early
 	if resp.StatusCode == http.StatusNoContent {
 		return nil
 	}
 
-	respBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("Error reading response bytes: %v", err)
+	// An error response could unmarshal into out as well, e.g. into a slice
+	if resp.StatusCode >= http.StatusBadRequest {
+		if apiErrors := parseApiErrors(respBytes); apiErrors != nil {
+			return apiErrors
+		}
 	}
-	forceApi.traceResponseBody(respBytes)
 
 	// Attempt to parse response into out
 	var objectUnmarshalErr error
@@ -125,28 +146,8 @@ func (forceApi *ForceApi) requestWithContentType(method, path string, params url
 	}
 
 	// Attempt to parse response as a force.com api error before returning object unmarshal err
-	apiErrors := ApiErrors{}
-	if marshalErr := json.Unmarshal(respBytes, &apiErrors); marshalErr == nil {
-		if apiErrors.Validate() {
-			// Check if error is oauth token expired
-			if forceApi.OAuth.Expired(apiErrors) {
-				// Reauthenticate then attempt query again
-				var oauthErr error
-
-				if "" != forceApi.OAuth.RefreshToken {
-					oauthErr = forceApi.OAuth.RefreshAccessToken()
-				} else {
-					oauthErr = forceApi.OAuth.Authenticate()
-				}
-				if oauthErr != nil {
-					return oauthErr
-				}
-
-				return forceApi.request(method, path, params, payload, out)
-			}
-
-			return apiErrors
-		}
+	if apiErrors := parseApiErrors(respBytes); apiErrors != nil {
+		return apiErrors
 	}
 
 	if objectUnmarshalErr != nil {
@@ -158,6 +159,153 @@ func (forceApi *ForceApi) requestWithContentType(method, path string, params url
 	return nil
 }
 
+// send issues a request and returns the response along with its body. It
+// reauthenticates and sends the request again once if the session has
+// expired, and retries transient failures according to the retry policy.
+func (forceApi *ForceApi) send(ctx context.Context, r *apiRequest) (*http.Response, []byte, error) {
+	method, path, params, body := r.method, r.path, r.params, r.body
+	if err := forceApi.OAuth.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("Error creating %v request: %v", method, err)
+	}
+	if err := forceApi.usage.check(); err != nil {
+		return nil, nil, err
+	}
+
+	// Build Uri
+	var uri bytes.Buffer
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		uri.WriteString(forceApi.OAuth.instanceUrl())
+	}
+
+	uri.WriteString(path)
+	if params != nil && len(params) != 0 {
+		uri.WriteString("?")
+		uri.WriteString(params.Encode())
+	}
+
+	idempotent := isIdempotent(method)
+	refreshed := false
+	for attempt := 1; ; attempt++ {
+		// Build Request, with a fresh body reader for every attempt
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, uri.String(), reqBody)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error creating %v request: %v", method, err)
+		}
+
+		// Add Headers
+		req.Header.Set("User-Agent", forceApi.getUserAgent())
+		req.Header.Set("Content-Type", r.contentType)
+		req.Header.Set("Accept", responseType)
+		accessToken := forceApi.OAuth.accessToken()
+		req.Header.Set("Authorization", fmt.Sprintf("%v %v", "Bearer", accessToken))
+		req.Header.Set("X-SFDC-Session", accessToken)
+		for key, values := range r.header {
+			req.Header[key] = values
+		}
+
+		// Send
+		forceApi.traceRequest(req)
+		resp, err := forceApi.client().Do(req)
+		if err != nil {
+			retry, waitErr := forceApi.retryPolicy.retry(ctx, attempt, idempotent, nil, nil, err)
+			if retry {
+				continue
+			}
+			if waitErr != nil {
+				err = waitErr
+			}
+			return nil, nil, fmt.Errorf("Error sending %v request: %w", method, err)
+		}
+		forceApi.traceResponse(resp)
+		forceApi.usage.record(resp.Header.Get(limitInfoHeader))
+		if r.stream && resp.StatusCode < http.StatusMultipleChoices {
+			return resp, nil, nil
+		}
+
+		respBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error reading response bytes: %v", err)
+		}
+		if resp.StatusCode != http.StatusNoContent {
+			forceApi.traceResponseBody(respBytes)
+		}
+
+		apiErrors := parseApiErrors(respBytes)
+
+		// Check if error is oauth token expired
+		if !refreshed && forceApi.OAuth.Expired(apiErrors) {
+			// Reauthenticate then attempt request again
+			if oauthErr := forceApi.OAuth.refreshExpired(ctx, accessToken); oauthErr != nil {
+				return nil, nil, oauthErr
+			}
+			if forceApi.OAuth.accessToken() == accessToken {
+				// Retrying with the same token would fail the same way.
+				return resp, respBytes, nil
+			}
+
+			refreshed = true
+			attempt--
+			continue
+		}
+
+		retry, err := forceApi.retryPolicy.retry(ctx, attempt, idempotent, resp, apiErrors, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error sending %v request: %w", method, err)
+		}
+		if retry {
+			continue
+		}
+
+		return resp, respBytes, nil
+	}
+}
+
+// parseApiErrors returns the force.com api errors in a response body, or nil
+// if it does not hold any.
+func parseApiErrors(respBytes []byte) ApiErrors {
+	apiErrors := ApiErrors{}
+	if err := json.Unmarshal(respBytes, &apiErrors); err != nil || !apiErrors.Validate() {
+		return nil
+	}
+
+	return apiErrors
+}
+
+func (forceApi *ForceApi) client() *http.Client {
+	if forceApi.httpClient != nil {
+		return forceApi.httpClient
+	}
+
+	return http.DefaultClient
+}
+
+func (forceApi *ForceApi) getUserAgent() string {
+	if forceApi.userAgent != "" {
+		return forceApi.userAgent
+	}
+
+	return defaultUserAgent
+}
+
+// sleepContext pauses for d, returning early with the context's error if ctx
+// is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 func (forceApi *ForceApi) traceRequest(req *http.Request) {
 	if forceApi.logger != nil {
 		forceApi.trace("Request:", req, "%v")
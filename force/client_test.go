@@ -0,0 +1,129 @@
+package force
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+// newLocalForceApi returns a ForceApi that talks to a local test server
+// instead of force.com.
+func newLocalForceApi(server *httptest.Server) *ForceApi {
+	return &ForceApi{
+		apiResources: map[string]string{
+			queryKey:    "/services/data/v36.0/query",
+			queryAllKey: "/services/data/v36.0/queryAll",
+		},
+		apiSObjects:            make(map[string]*SObjectMetaData),
+		apiSObjectDescriptions: make(map[string]*SObjectDescription),
+		apiVersion:             testVersion,
+		OAuth: &ForceOauth{
+			AccessToken: "token",
+			InstanceUrl: server.URL,
+		},
+	}
+}
+
+func TestQueryContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	forceApi := newLocalForceApi(server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var out map[string]interface{}
+	err := forceApi.QueryContext(ctx, "SELECT Id FROM Account", &out)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got: %v", err)
+	}
+}
+
+func TestSleepContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected canceled, got: %v", err)
+	}
+}
+
+func TestConcurrentExpiryRefreshesOnce(t *testing.T) {
+	const workers = 20
+
+	org := newFakeOrg(t)
+
+	// Hold every request made with the first token until all workers have
+	// sent one, so they all see the session expire at the same time.
+	var arrived sync.WaitGroup
+	arrived.Add(workers)
+	org.HandleJSON("/services/data/v36.0/query/", func(r *http.Request) interface{} {
+		if r.Header.Get("Authorization") == "Bearer token1" {
+			arrived.Done()
+			arrived.Wait()
+			return ApiErrors{{ErrorCode: invalidSessionErrorCode}}
+		}
+		return map[string]interface{}{"totalSize": 0, "done": true}
+	})
+	org.HandleJSON("/services/data/v36.0/sobjects/Account/describe", func(r *http.Request) interface{} {
+		return &SObjectDescription{Name: "Account"}
+	})
+
+	forceApi := org.New(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var out map[string]interface{}
+			if err := forceApi.Query("SELECT Id FROM Account", &out); err != nil {
+				errs <- err
+			}
+			if _, err := forceApi.DescribeSObject(sobjects.Account{}); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("Concurrent request failed: %v", err)
+	}
+	// The initial login plus exactly one refresh.
+	if org.tokenRequests != 2 {
+		t.Fatalf("Expected 2 token requests, got %v", org.tokenRequests)
+	}
+}
+
+func TestDescribeAndLimitsContextCanceled(t *testing.T) {
+	org := newFakeOrg(t)
+	forceApi := org.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := forceApi.DescribeSObjectsContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected DescribeSObjectsContext to be canceled, got: %v", err)
+	}
+	if _, err := forceApi.GetLimitsContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected GetLimitsContext to be canceled, got: %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/nimajalali/go-force/forcejson"
+)
+
+const (
+	// maxCollectionSize is the number of records a single sobject
+	// collections call creates, updates, upserts or deletes.
+	maxCollectionSize = 200
+
+	// maxCollectionRetrieveSize is the number of records a single sobject
+	// collections call retrieves.
+	maxCollectionRetrieveSize = 2000
+)
+
+// CreateSObjects inserts the records in, which may be of different sobjects,
+// in calls of up to 200 records. The responses are in the order of in, and
+// records inserted successfully get their id set with SetID.
+//
+// allOrNone applies to each call: if a record fails, the other records of
+// its call are rolled back, but not those of earlier calls. If a call fails,
+// the responses of the earlier calls are returned along with the error and
+// their records get their ids set.
+func (forceApi *ForceApi) CreateSObjects(in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	return forceApi.CreateSObjectsContext(context.Background(), in, allOrNone)
+}
+
+// CreateSObjectsContext is like CreateSObjects but the requests are bound to
+// ctx.
+func (forceApi *ForceApi) CreateSObjectsContext(ctx context.Context, in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	uri, err := forceApi.compositeUri("/sobjects")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := forceApi.modifyCollection(ctx, "POST", uri, in, allOrNone)
+	for i, r := range resp {
+		if r.Success && r.Id != "" {
+			in[i].SetID(r.Id)
+		}
+	}
+
+	return resp, err
+}
+
+// UpdateSObjects updates the records in, which need their Id set and may be
+// of different sobjects, in calls of up to 200 records. The responses are in
+// the order of in. allOrNone applies to each call as for CreateSObjects.
+func (forceApi *ForceApi) UpdateSObjects(in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	return forceApi.UpdateSObjectsContext(context.Background(), in, allOrNone)
+}
+
+// UpdateSObjectsContext is like UpdateSObjects but the requests are bound to
+// ctx.
+func (forceApi *ForceApi) UpdateSObjectsContext(ctx context.Context, in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	uri, err := forceApi.compositeUri("/sobjects")
+	if err != nil {
+		return nil, err
+	}
+
+	return forceApi.modifyCollection(ctx, "PATCH", uri, in, allOrNone)
+}
+
+// UpsertSObjectsByExternalId upserts the records in, which must be of the same
+// sobject, by the external id field named by its ExternalIdApiName, in calls
+// of up to 200 records. The responses are in the order of in and tell by
+// Created whether a record was inserted. allOrNone applies to each call as
+// for CreateSObjects.
+func (forceApi *ForceApi) UpsertSObjectsByExternalId(in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	return forceApi.UpsertSObjectsByExternalIdContext(context.Background(), in, allOrNone)
+}
+
+// UpsertSObjectsByExternalIdContext is like UpsertSObjectsByExternalId but
+// the requests are bound to ctx.
+func (forceApi *ForceApi) UpsertSObjectsByExternalIdContext(ctx context.Context, in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	if len(in) == 0 {
+		return nil, nil
+	}
+
+	name, field := in[0].ApiName(), in[0].ExternalIdApiName()
+	if field == "" {
+		return nil, fmt.Errorf("force: %v has no external id field", name)
+	}
+	for _, record := range in {
+		if record.ApiName() != name || record.ExternalIdApiName() != field {
+			return nil, fmt.Errorf("force: upserted records must all be %v by %v", name, field)
+		}
+	}
+
+	uri, err := forceApi.compositeUri("/sobjects/" + name + "/" + field)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := forceApi.modifyCollection(ctx, "PATCH", uri, in, allOrNone)
+	for i, r := range resp {
+		if r.Success && r.Id != "" {
+			in[i].SetID(r.Id)
+		}
+	}
+
+	return resp, err
+}
+
+// DeleteSObjects deletes the records ids, which may be of different sobjects,
+// in calls of up to 200 records. The responses are in the order of ids.
+// allOrNone and failed calls are handled as for CreateSObjects.
+func (forceApi *ForceApi) DeleteSObjects(ids []string, allOrNone bool) ([]*SObjectResponse, error) {
+	return forceApi.DeleteSObjectsContext(context.Background(), ids, allOrNone)
+}
+
+// DeleteSObjectsContext is like DeleteSObjects but the requests are bound to
+// ctx.
+func (forceApi *ForceApi) DeleteSObjectsContext(ctx context.Context, ids []string, allOrNone bool) ([]*SObjectResponse, error) {
+	uri, err := forceApi.compositeUri("/sobjects")
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*SObjectResponse, 0, len(ids))
+	for start := 0; start < len(ids); start += maxCollectionSize {
+		chunk := ids[start:min(start+maxCollectionSize, len(ids))]
+
+		params := url.Values{
+			"ids":       {strings.Join(chunk, ",")},
+			"allOrNone": {strconv.FormatBool(allOrNone)},
+		}
+
+		resp := []*SObjectResponse{}
+		if err := forceApi.request(ctx, "DELETE", uri, params, nil, &resp); err != nil {
+			return results, err
+		}
+		if len(resp) != len(chunk) {
+			return results, fmt.Errorf("force: %v responses to %v deleted records", len(resp), len(chunk))
+		}
+		results = append(results, resp...)
+	}
+
+	return results, nil
+}
+
+// RetrieveSObjects retrieves fields of the records ids of the sobject table,
+// in calls of up to 2000 records, into out, a pointer to a slice of SObject
+// structs or pointers to them. The records are in the order of ids; a record
+// that was not found is left zero.
+func (forceApi *ForceApi) RetrieveSObjects(table string, ids []string, fields []string, out interface{}) error {
+	return forceApi.RetrieveSObjectsContext(context.Background(), table, ids, fields, out)
+}
+
+// RetrieveSObjectsContext is like RetrieveSObjects but the requests are bound
+// to ctx.
+func (forceApi *ForceApi) RetrieveSObjectsContext(ctx context.Context, table string, ids []string, fields []string, out interface{}) error {
+	slice, err := recordSlice(out, "RetrieveSObjects")
+	if err != nil {
+		return err
+	}
+
+	if len(fields) == 0 {
+		return errors.New("force: RetrieveSObjects needs fields to retrieve")
+	}
+	if _, ok := forceApi.sObjectMetaData(table); !ok {
+		return fmt.Errorf("Unable to find metadata for object: %v", table)
+	}
+
+	uri, err := forceApi.compositeUri("/sobjects/" + table)
+	if err != nil {
+		return err
+	}
+
+	records := reflect.MakeSlice(slice.Type(), 0, len(ids))
+	for start := 0; start < len(ids); start += maxCollectionRetrieveSize {
+		chunk := ids[start:min(start+maxCollectionRetrieveSize, len(ids))]
+
+		// POST takes more ids than fit in the url of a GET.
+		payload := map[string][]string{
+			"ids":    chunk,
+			"fields": fields,
+		}
+
+		resp := []json.RawMessage{}
+		if err := forceApi.PostContext(ctx, uri, nil, payload, &resp); err != nil {
+			return err
+		}
+		if len(resp) != len(chunk) {
+			return fmt.Errorf("force: %v records retrieved for %v ids", len(resp), len(chunk))
+		}
+
+		if records, err = appendRecords(records, resp); err != nil {
+			return err
+		}
+	}
+
+	slice.Set(records)
+	return nil
+}
+
+// recordSlice returns the slice out points to, for caller to decode records
+// into.
+func recordSlice(out interface{}, caller string) (reflect.Value, error) {
+	slice := reflect.ValueOf(out)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, fmt.Errorf("force: %v needs a pointer to a slice", caller)
+	}
+
+	return slice.Elem(), nil
+}
+
+// appendRecords decodes the JSON records data and appends them to records, a
+// slice. A null record is appended as the zero value.
+func appendRecords(records reflect.Value, data []json.RawMessage) (reflect.Value, error) {
+	for _, d := range data {
+		record := reflect.New(records.Type().Elem())
+		if string(d) != "null" {
+			if err := forcejson.Unmarshal(d, record.Interface()); err != nil {
+				return records, fmt.Errorf("Unable to unmarshal record: %v", err)
+			}
+		}
+		records = reflect.Append(records, record.Elem())
+	}
+
+	return records, nil
+}
+
+// modifyCollection sends the records in to the collection uri in chunks and
+// returns the merged responses. If a chunk fails, the responses of the
+// earlier chunks are returned with the error.
+func (forceApi *ForceApi) modifyCollection(ctx context.Context, method, uri string, in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	results := make([]*SObjectResponse, 0, len(in))
+	for start := 0; start < len(in); start += maxCollectionSize {
+		chunk := in[start:min(start+maxCollectionSize, len(in))]
+
+		payload := struct {
+			AllOrNone bool              `json:"allOrNone"`
+			Records   []json.RawMessage `json:"records"`
+		}{AllOrNone: allOrNone}
+		for _, record := range chunk {
+			data, err := collectionRecord(record)
+			if err != nil {
+				return results, err
+			}
+			payload.Records = append(payload.Records, data)
+		}
+
+		resp := []*SObjectResponse{}
+		if err := forceApi.request(ctx, method, uri, nil, payload, &resp); err != nil {
+			return results, err
+		}
+		if len(resp) != len(chunk) {
+			return results, fmt.Errorf("force: %v responses to %v records", len(resp), len(chunk))
+		}
+		results = append(results, resp...)
+	}
+
+	return results, nil
+}
+
+// collectionRecord returns the JSON of record with the attributes naming its
+// sobject, which collections need to tell records apart.
+func collectionRecord(record SObject) (json.RawMessage, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshaling encoded payload: %v", err)
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("force: %v record is not a JSON object: %v", record.ApiName(), err)
+	}
+
+	attributes, err := json.Marshal(map[string]string{"type": record.ApiName()})
+	if err != nil {
+		return nil, err
+	}
+	fields["attributes"] = attributes
+
+	return json.Marshal(fields)
+}
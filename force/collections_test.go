@@ -0,0 +1,230 @@
+package force
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+type testExternalAccount struct {
+	testCompositeAccount
+	AccountNumber string `force:",omitempty"`
+}
+
+func (testExternalAccount) ExternalIdApiName() string {
+	return "AccountNumber"
+}
+
+type testCollectionRequest struct {
+	AllOrNone bool                     `json:"allOrNone"`
+	Records   []map[string]interface{} `json:"records"`
+}
+
+// handleCollection answers every record of a collection call with an id
+// derived from its BillingCity, failing records without one.
+func handleCollection(t *testing.T, org *fakeOrg, pattern string) *[]*testCollectionRequest {
+	var mu sync.Mutex
+	requests := []*testCollectionRequest{}
+	org.HandleJSON(pattern, func(r *http.Request) interface{} {
+		req := &testCollectionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			t.Errorf("Unable to decode request: %v", err)
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+
+		resp := []*SObjectResponse{}
+		for _, record := range req.Records {
+			if city, _ := record["BillingCity"].(string); city != "" {
+				resp = append(resp, &SObjectResponse{Id: "001" + city, Success: true, Created: r.Method == "PATCH"})
+			} else {
+				resp = append(resp, &SObjectResponse{Errors: []SObjectError{{StatusCode: "REQUIRED_FIELD_MISSING"}}})
+			}
+		}
+		return resp
+	})
+
+	return &requests
+}
+
+func TestCreateSObjects(t *testing.T) {
+	org := newFakeOrg(t)
+	requests := handleCollection(t, org, "/services/data/v36.0/composite/sobjects")
+	forceApi := org.New(t)
+
+	var in []SObject
+	for i := 0; i < 450; i++ {
+		account := &testCompositeAccount{}
+		if i != 300 {
+			account.BillingCity = fmt.Sprint(i)
+		}
+		in = append(in, account)
+	}
+
+	resp, err := forceApi.CreateSObjects(in, true)
+	if err != nil {
+		t.Fatalf("CreateSObjects failed: %v", err)
+	}
+
+	if len(*requests) != 3 || len((*requests)[0].Records) != 200 || len((*requests)[2].Records) != 50 || !(*requests)[0].AllOrNone {
+		t.Fatalf("Expected calls of 200, 200 and 50 records, got %v calls", len(*requests))
+	}
+	attributes, _ := (*requests)[0].Records[0]["attributes"].(map[string]interface{})
+	if attributes["type"] != "Account" {
+		t.Fatalf("Expected records to name their sobject, got %v", (*requests)[0].Records[0])
+	}
+
+	if len(resp) != 450 || resp[449].Id != "001449" || resp[300].Success {
+		t.Fatalf("Expected responses in input order, got %v", len(resp))
+	}
+	if in[201].(*testCompositeAccount).Id != "001201" || in[300].(*testCompositeAccount).Id != "" {
+		t.Fatalf("Expected inserted records to get their ids, got %+v", in[201])
+	}
+}
+
+func TestUpsertSObjectsByExternalId(t *testing.T) {
+	org := newFakeOrg(t)
+	requests := handleCollection(t, org, "/services/data/v36.0/composite/sobjects/Account/AccountNumber")
+	forceApi := org.New(t)
+
+	in := []SObject{
+		&testExternalAccount{testCompositeAccount{BillingCity: "Oslo"}, "A-1"},
+		&testExternalAccount{testCompositeAccount{BillingCity: "Rome"}, "A-2"},
+	}
+	resp, err := forceApi.UpsertSObjectsByExternalId(in, false)
+	if err != nil {
+		t.Fatalf("UpsertSObjectsByExternalId failed: %v", err)
+	}
+	if len(*requests) != 1 || (*requests)[0].Records[1]["AccountNumber"] != "A-2" {
+		t.Fatalf("Unexpected requests %+v", *requests)
+	}
+	if len(resp) != 2 || !resp[0].Created || in[1].(*testExternalAccount).Id != "001Rome" {
+		t.Fatalf("Unexpected responses %+v", resp)
+	}
+
+	if _, err := forceApi.UpsertSObjectsByExternalId([]SObject{&testCompositeAccount{}}, false); err == nil {
+		t.Fatal("Expected an error for records without an external id")
+	}
+}
+
+func TestDeleteSObjects(t *testing.T) {
+	org := newFakeOrg(t)
+	var calls []string
+	org.HandleJSON("/services/data/v36.0/composite/sobjects", func(r *http.Request) interface{} {
+		if r.Method != "DELETE" || r.URL.Query().Get("allOrNone") != "false" {
+			t.Errorf("Unexpected request %v %v", r.Method, r.URL)
+		}
+		ids := strings.Split(r.URL.Query().Get("ids"), ",")
+		calls = append(calls, r.URL.Query().Get("ids"))
+
+		resp := []*SObjectResponse{}
+		for _, id := range ids {
+			resp = append(resp, &SObjectResponse{Id: id, Success: true})
+		}
+		return resp
+	})
+	forceApi := org.New(t)
+
+	var ids []string
+	for i := 0; i < 201; i++ {
+		ids = append(ids, fmt.Sprintf("001%03d", i))
+	}
+	resp, err := forceApi.DeleteSObjects(ids, false)
+	if err != nil {
+		t.Fatalf("DeleteSObjects failed: %v", err)
+	}
+	if len(calls) != 2 || calls[1] != "001200" || len(resp) != 201 || resp[200].Id != "001200" {
+		t.Fatalf("Unexpected calls %v", len(calls))
+	}
+}
+
+func TestRetrieveSObjects(t *testing.T) {
+	org := newFakeOrg(t)
+	org.HandleJSON("/services/data/v36.0/composite/sobjects/Account", func(r *http.Request) interface{} {
+		req := struct {
+			Ids    []string `json:"ids"`
+			Fields []string `json:"fields"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Unable to decode request: %v", err)
+		}
+		if strings.Join(req.Fields, ",") != "Id,BillingCity" {
+			t.Errorf("Unexpected fields %v", req.Fields)
+		}
+		return []interface{}{
+			map[string]interface{}{"attributes": map[string]string{"type": "Account"}, "Id": req.Ids[0], "BillingCity": "Oslo"},
+			nil,
+		}
+	})
+	forceApi := org.New(t)
+
+	var accounts []*sobjects.Account
+	if err := forceApi.RetrieveSObjects("Account", []string{"001A", "001B"}, []string{"Id", "BillingCity"}, &accounts); err != nil {
+		t.Fatalf("RetrieveSObjects failed: %v", err)
+	}
+	if len(accounts) != 2 || accounts[0].Id != "001A" || accounts[0].BillingCity != "Oslo" || accounts[1] != nil {
+		t.Fatalf("Unexpected accounts %+v", accounts)
+	}
+
+	if err := forceApi.RetrieveSObjects("Account", []string{"001A"}, []string{"Id"}, accounts); err == nil {
+		t.Fatal("Expected an error for a slice that is not a pointer")
+	}
+}
+
+func TestCollectionsApiErrors(t *testing.T) {
+	org := newFakeOrg(t)
+	calls := 0
+	org.mux.HandleFunc("/services/data/v36.0/composite/sobjects", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", jsonContentType)
+		if calls == 1 {
+			req := &testCollectionRequest{}
+			json.NewDecoder(r.Body).Decode(req)
+			resp := []*SObjectResponse{}
+			for i := range req.Records {
+				resp = append(resp, &SObjectResponse{Id: fmt.Sprint("001-", i), Success: true})
+			}
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `[{"errorCode":"REQUEST_LIMIT_EXCEEDED","message":"Too many requests"}]`)
+	})
+	org.mux.HandleFunc("/services/data/v36.0/composite/sobjects/Account", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", jsonContentType)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `[{"errorCode":"INVALID_FIELD","message":"No such column 'Nmae'"}]`)
+	})
+	forceApi := org.New(t)
+
+	var in []SObject
+	for i := 0; i < 201; i++ {
+		in = append(in, &testCompositeAccount{})
+	}
+	resp, err := forceApi.CreateSObjects(in, true)
+	if apiErrors, ok := err.(ApiErrors); !ok || apiErrors[0].ErrorCode != "REQUEST_LIMIT_EXCEEDED" {
+		t.Fatalf("Expected the error of the second call, got %v", err)
+	}
+	if len(resp) != 200 || in[199].(*testCompositeAccount).Id != "001-199" || in[200].(*testCompositeAccount).Id != "" {
+		t.Fatalf("Expected the records of the first call to get their ids, got %v responses", len(resp))
+	}
+
+	if _, err := forceApi.DeleteSObjects([]string{"001A"}, false); err == nil {
+		t.Fatal("Expected the delete to fail")
+	}
+
+	for _, ids := range [][]string{{"001A"}, {"001A", "001B"}} {
+		var accounts []*sobjects.Account
+		err := forceApi.RetrieveSObjects("Account", ids, []string{"Id", "Nmae"}, &accounts)
+		if apiErrors, ok := err.(ApiErrors); !ok || apiErrors[0].ErrorCode != "INVALID_FIELD" {
+			t.Fatalf("Expected the retrieve error for %v ids, got %v", len(ids), err)
+		}
+	}
+}
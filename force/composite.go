@@ -0,0 +1,564 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/nimajalali/go-force/forcejson"
+)
+
+const (
+	// externalIdKey resolves the url of a record by the external id of its
+	// sobject.
+	externalIdKey = "externalId"
+
+	// Limits of the composite resources.
+	maxCompositeSubrequests      = 25
+	maxCompositeBatchSubrequests = 25
+	maxCompositeGraphNodes       = 500
+
+	dataUriPrefix = "/services/data/"
+)
+
+// Reference returns a reference to field of the result of the subrequest
+// with reference id ref, for use in the url or body of a later subrequest:
+//
+//	contact.AccountId = force.Reference("refAccount", "id") // "@{refAccount.id}"
+func Reference(ref, field string) string {
+	return "@{" + ref + "." + field + "}"
+}
+
+// compositeSubrequest is a subrequest whose url is resolved from the sobject
+// metadata when it is sent.
+type compositeSubrequest struct {
+	method string
+	ref    string
+	body   interface{}
+
+	// Either url is given or it is resolved from sobject, id and key.
+	url     string
+	sobject SObject
+	id      string
+	key     string
+	params  url.Values
+}
+
+func insertSubrequest(ref string, in SObject) *compositeSubrequest {
+	return &compositeSubrequest{method: "POST", ref: ref, body: in, sobject: in, key: sObjectKey}
+}
+
+func updateSubrequest(ref, id string, in SObject) *compositeSubrequest {
+	return &compositeSubrequest{method: "PATCH", ref: ref, body: in, sobject: in, id: id, key: rowTemplateKey}
+}
+
+func upsertSubrequest(ref, id string, in SObject) *compositeSubrequest {
+	return &compositeSubrequest{method: "PATCH", ref: ref, body: in, sobject: in, id: id, key: externalIdKey}
+}
+
+func deleteSubrequest(ref, id string, in SObject) *compositeSubrequest {
+	return &compositeSubrequest{method: "DELETE", ref: ref, sobject: in, id: id, key: rowTemplateKey}
+}
+
+func getSubrequest(ref, id string, fields []string, out SObject) *compositeSubrequest {
+	s := &compositeSubrequest{method: "GET", ref: ref, sobject: out, id: id, key: rowTemplateKey}
+	if len(fields) > 0 {
+		s.params = url.Values{"fields": {strings.Join(fields, ",")}}
+	}
+
+	return s
+}
+
+func querySubrequest(ref, query string) *compositeSubrequest {
+	return &compositeSubrequest{method: "GET", ref: ref, key: queryKey, params: url.Values{"q": {query}}}
+}
+
+// resolveUrl returns the url of the subrequest.
+func (s *compositeSubrequest) resolveUrl(forceApi *ForceApi) (string, error) {
+	uri := s.url
+	switch {
+	case uri != "":
+	case s.key == queryKey:
+		if uri = forceApi.resource(queryKey); uri == "" {
+			return "", errors.New("force: query resource is not available")
+		}
+	case s.key == externalIdKey:
+		sObjectUrl, err := forceApi.sObjectUrl(s.sobject.ApiName(), sObjectKey)
+		if err != nil {
+			return "", err
+		}
+		uri = fmt.Sprintf("%v/%v/%v", sObjectUrl, s.sobject.ExternalIdApiName(), s.id)
+	default:
+		template, err := forceApi.sObjectUrl(s.sobject.ApiName(), s.key)
+		if err != nil {
+			return "", err
+		}
+		uri = strings.Replace(template, idKey, s.id, 1)
+	}
+
+	if len(s.params) != 0 {
+		uri += "?" + s.params.Encode()
+	}
+
+	return uri, nil
+}
+
+// setID passes the id of a record inserted by the subrequest to its SObject.
+func (s *compositeSubrequest) setID(result *CompositeResult) {
+	if s.method != "POST" || s.key != sObjectKey || s.sobject == nil || result.Err() != nil {
+		return
+	}
+
+	created := &SObjectResponse{}
+	if err := json.Unmarshal(result.Body, created); err == nil && created.Id != "" {
+		s.sobject.SetID(created.Id)
+	}
+}
+
+// CompositeRequest is a list of subrequests sent in a single call to the
+// composite resource and run in order. A subrequest can refer to the results
+// of earlier ones with Reference.
+//
+// Records inserted successfully get their id set with SetID.
+type CompositeRequest struct {
+	// AllOrNone rolls back every subrequest if one of them fails.
+	AllOrNone bool
+
+	// CollateSubrequests lets force.com run consecutive subrequests that do
+	// not depend on each other in parallel.
+	CollateSubrequests bool
+
+	subrequests []*compositeSubrequest
+	err         error
+}
+
+// Insert adds a subrequest inserting in.
+func (req *CompositeRequest) Insert(ref string, in SObject) *CompositeRequest {
+	return req.add(insertSubrequest(ref, in))
+}
+
+// Update adds a subrequest updating the record id with the fields of in.
+func (req *CompositeRequest) Update(ref, id string, in SObject) *CompositeRequest {
+	return req.add(updateSubrequest(ref, id, in))
+}
+
+// Upsert adds a subrequest upserting in by the external id id.
+func (req *CompositeRequest) Upsert(ref, id string, in SObject) *CompositeRequest {
+	return req.add(upsertSubrequest(ref, id, in))
+}
+
+// Delete adds a subrequest deleting the record id of the sobject of in.
+func (req *CompositeRequest) Delete(ref, id string, in SObject) *CompositeRequest {
+	return req.add(deleteSubrequest(ref, id, in))
+}
+
+// Get adds a subrequest retrieving fields of the record id of the sobject of
+// out. Decode its result into out.
+func (req *CompositeRequest) Get(ref, id string, fields []string, out SObject) *CompositeRequest {
+	return req.add(getSubrequest(ref, id, fields, out))
+}
+
+// Query adds a subrequest running query.
+func (req *CompositeRequest) Query(ref, query string) *CompositeRequest {
+	return req.add(querySubrequest(ref, query))
+}
+
+// Add adds a subrequest to any resource, given by its path such as
+// "/services/data/v36.0/sobjects/Account/describe".
+func (req *CompositeRequest) Add(ref, method, path string, body interface{}) *CompositeRequest {
+	return req.add(&compositeSubrequest{method: method, ref: ref, url: path, body: body})
+}
+
+func (req *CompositeRequest) add(s *compositeSubrequest) *CompositeRequest {
+	if req.err != nil {
+		return req
+	}
+
+	if !soqlNamePattern.MatchString(s.ref) {
+		req.err = fmt.Errorf("force: invalid composite reference id %q", s.ref)
+		return req
+	}
+	if s.sobject == nil && s.url == "" && s.key != queryKey {
+		req.err = errors.New("force: composite subrequest without sobject")
+		return req
+	}
+
+	req.subrequests = append(req.subrequests, s)
+	return req
+}
+
+type compositeSubrequestPayload struct {
+	Method      string      `json:"method"`
+	Url         string      `json:"url"`
+	ReferenceId string      `json:"referenceId"`
+	Body        interface{} `json:"body,omitempty"`
+}
+
+func (req *CompositeRequest) payload(forceApi *ForceApi) ([]*compositeSubrequestPayload, error) {
+	if req.err != nil {
+		return nil, req.err
+	}
+	if len(req.subrequests) == 0 {
+		return nil, errors.New("force: composite request without subrequests")
+	}
+
+	payload := make([]*compositeSubrequestPayload, len(req.subrequests))
+	for i, s := range req.subrequests {
+		uri, err := s.resolveUrl(forceApi)
+		if err != nil {
+			return nil, err
+		}
+		payload[i] = &compositeSubrequestPayload{Method: s.method, Url: uri, ReferenceId: s.ref, Body: s.body}
+	}
+
+	return payload, nil
+}
+
+// setIDs passes the ids of inserted records to their SObjects, unless a
+// failure rolled back an all-or-none request.
+func (req *CompositeRequest) setIDs(resp *CompositeResponse) {
+	if req.AllOrNone && resp.Err() != nil {
+		return
+	}
+	for _, s := range req.subrequests {
+		if result := resp.Result(s.ref); result != nil {
+			s.setID(result)
+		}
+	}
+}
+
+// CompositeResult is the result of a subrequest.
+type CompositeResult struct {
+	ReferenceId    string            `json:"referenceId"`
+	HttpStatusCode int               `json:"httpStatusCode"`
+	HttpHeaders    map[string]string `json:"httpHeaders"`
+	Body           json.RawMessage   `json:"body"`
+}
+
+// Err returns the error of a failed subrequest, usually ApiErrors, or nil.
+func (result *CompositeResult) Err() error {
+	if result.HttpStatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	if apiErrors := parseApiErrors(result.Body); apiErrors != nil {
+		return apiErrors
+	}
+
+	return fmt.Errorf("force: subrequest %v failed with status %v", result.ReferenceId, result.HttpStatusCode)
+}
+
+// Decode unmarshals the body of the result into out.
+func (result *CompositeResult) Decode(out interface{}) error {
+	if err := result.Err(); err != nil {
+		return err
+	}
+
+	if err := forcejson.Unmarshal(result.Body, out); err != nil {
+		return fmt.Errorf("Unable to unmarshal subrequest result: %v", err)
+	}
+
+	return nil
+}
+
+// CompositeResponse holds the results of the subrequests of a composite
+// request, in the order of the subrequests.
+type CompositeResponse struct {
+	Results []*CompositeResult `json:"compositeResponse"`
+}
+
+// Result returns the result of the subrequest with reference id ref, or nil.
+func (resp *CompositeResponse) Result(ref string) *CompositeResult {
+	for _, result := range resp.Results {
+		if result.ReferenceId == ref {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// Err returns the error of the first failed subrequest, or nil.
+func (resp *CompositeResponse) Err() error {
+	for _, result := range resp.Results {
+		if err := result.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (forceApi *ForceApi) compositeUri(resource string) (string, error) {
+	uri := forceApi.resource(compositeKey)
+	if uri == "" {
+		return "", errors.New("force: composite resource is not available")
+	}
+
+	return strings.TrimSuffix(uri, "/") + resource, nil
+}
+
+// Composite sends req. The error is only that of the call itself; check the
+// results, or the Err of the response, for failed subrequests.
+func (forceApi *ForceApi) Composite(req *CompositeRequest) (*CompositeResponse, error) {
+	return forceApi.CompositeContext(context.Background(), req)
+}
+
+// CompositeContext is like Composite but the request is bound to ctx.
+func (forceApi *ForceApi) CompositeContext(ctx context.Context, req *CompositeRequest) (*CompositeResponse, error) {
+	subrequests, err := req.payload(forceApi)
+	if err != nil {
+		return nil, err
+	}
+	if len(subrequests) > maxCompositeSubrequests {
+		return nil, fmt.Errorf("force: composite request has %v subrequests, more than %v", len(subrequests), maxCompositeSubrequests)
+	}
+
+	uri, err := forceApi.compositeUri("")
+	if err != nil {
+		return nil, err
+	}
+
+	payload := struct {
+		AllOrNone          bool                          `json:"allOrNone"`
+		CollateSubrequests bool                          `json:"collateSubrequests"`
+		CompositeRequest   []*compositeSubrequestPayload `json:"compositeRequest"`
+	}{req.AllOrNone, req.CollateSubrequests, subrequests}
+
+	resp := &CompositeResponse{}
+	if err := forceApi.PostContext(ctx, uri, nil, payload, resp); err != nil {
+		return nil, err
+	}
+	req.setIDs(resp)
+
+	return resp, nil
+}
+
+// CompositeBatchRequest is a list of independent subrequests sent in a single
+// call to the composite batch resource. They cannot refer to each other and
+// are not rolled back if one fails.
+//
+// Records inserted successfully get their id set with SetID.
+type CompositeBatchRequest struct {
+	// HaltOnError skips the subrequests after the first failed one.
+	HaltOnError bool
+
+	subrequests []*compositeSubrequest
+	err         error
+}
+
+// Insert adds a subrequest inserting in.
+func (req *CompositeBatchRequest) Insert(in SObject) *CompositeBatchRequest {
+	return req.add(insertSubrequest("", in))
+}
+
+// Update adds a subrequest updating the record id with the fields of in.
+func (req *CompositeBatchRequest) Update(id string, in SObject) *CompositeBatchRequest {
+	return req.add(updateSubrequest("", id, in))
+}
+
+// Upsert adds a subrequest upserting in by the external id id.
+func (req *CompositeBatchRequest) Upsert(id string, in SObject) *CompositeBatchRequest {
+	return req.add(upsertSubrequest("", id, in))
+}
+
+// Delete adds a subrequest deleting the record id of the sobject of in.
+func (req *CompositeBatchRequest) Delete(id string, in SObject) *CompositeBatchRequest {
+	return req.add(deleteSubrequest("", id, in))
+}
+
+// Get adds a subrequest retrieving fields of the record id of the sobject of
+// out. Decode its result into out.
+func (req *CompositeBatchRequest) Get(id string, fields []string, out SObject) *CompositeBatchRequest {
+	return req.add(getSubrequest("", id, fields, out))
+}
+
+// Query adds a subrequest running query.
+func (req *CompositeBatchRequest) Query(query string) *CompositeBatchRequest {
+	return req.add(querySubrequest("", query))
+}
+
+// Add adds a subrequest to any resource, given by its path such as
+// "/services/data/v36.0/sobjects/Account/describe".
+func (req *CompositeBatchRequest) Add(method, path string, body interface{}) *CompositeBatchRequest {
+	return req.add(&compositeSubrequest{method: method, url: path, body: body})
+}
+
+func (req *CompositeBatchRequest) add(s *compositeSubrequest) *CompositeBatchRequest {
+	if req.err != nil {
+		return req
+	}
+
+	if s.sobject == nil && s.url == "" && s.key != queryKey {
+		req.err = errors.New("force: composite subrequest without sobject")
+		return req
+	}
+
+	req.subrequests = append(req.subrequests, s)
+	return req
+}
+
+// CompositeBatchResponse holds the results of the subrequests of a batch, in
+// the order of the subrequests. Their reference ids are empty.
+type CompositeBatchResponse struct {
+	HasErrors bool
+	Results   []*CompositeResult
+}
+
+// CompositeBatch sends req. The error is only that of the call itself; check
+// the results for failed subrequests.
+func (forceApi *ForceApi) CompositeBatch(req *CompositeBatchRequest) (*CompositeBatchResponse, error) {
+	return forceApi.CompositeBatchContext(context.Background(), req)
+}
+
+// CompositeBatchContext is like CompositeBatch but the request is bound to
+// ctx.
+func (forceApi *ForceApi) CompositeBatchContext(ctx context.Context, req *CompositeBatchRequest) (*CompositeBatchResponse, error) {
+	if req.err != nil {
+		return nil, req.err
+	}
+	if len(req.subrequests) == 0 {
+		return nil, errors.New("force: composite batch without subrequests")
+	}
+	if len(req.subrequests) > maxCompositeBatchSubrequests {
+		return nil, fmt.Errorf("force: composite batch has %v subrequests, more than %v", len(req.subrequests), maxCompositeBatchSubrequests)
+	}
+
+	type batchSubrequest struct {
+		Method    string      `json:"method"`
+		Url       string      `json:"url"`
+		RichInput interface{} `json:"richInput,omitempty"`
+	}
+	payload := struct {
+		HaltOnError   bool               `json:"haltOnError"`
+		BatchRequests []*batchSubrequest `json:"batchRequests"`
+	}{HaltOnError: req.HaltOnError}
+	for _, s := range req.subrequests {
+		uri, err := s.resolveUrl(forceApi)
+		if err != nil {
+			return nil, err
+		}
+		// Batch subrequests are relative to the data resource, e.g.
+		// v36.0/sobjects/Account.
+		uri = strings.TrimPrefix(uri, dataUriPrefix)
+		payload.BatchRequests = append(payload.BatchRequests, &batchSubrequest{s.method, uri, s.body})
+	}
+
+	uri, err := forceApi.compositeUri("/batch")
+	if err != nil {
+		return nil, err
+	}
+
+	response := &struct {
+		HasErrors bool `json:"hasErrors"`
+		Results   []struct {
+			StatusCode int             `json:"statusCode"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"results"`
+	}{}
+	if err := forceApi.PostContext(ctx, uri, nil, payload, response); err != nil {
+		return nil, err
+	}
+
+	resp := &CompositeBatchResponse{HasErrors: response.HasErrors}
+	for i, r := range response.Results {
+		result := &CompositeResult{HttpStatusCode: r.StatusCode, Body: r.Result}
+		resp.Results = append(resp.Results, result)
+		if i < len(req.subrequests) {
+			req.subrequests[i].setID(result)
+		}
+	}
+
+	return resp, nil
+}
+
+// CompositeGraph is a composite request with an id, sent together with other
+// graphs to the composite graph resource. Each graph is rolled back as a
+// whole if one of its subrequests fails, regardless of AllOrNone.
+type CompositeGraph struct {
+	Id      string
+	Request *CompositeRequest
+}
+
+// CompositeGraphResult is the outcome of a graph.
+type CompositeGraphResult struct {
+	GraphId      string            `json:"graphId"`
+	IsSuccessful bool              `json:"isSuccessful"`
+	Response     CompositeResponse `json:"graphResponse"`
+}
+
+// CompositeGraphResponse holds the results of the graphs, in the order of the
+// graphs.
+type CompositeGraphResponse struct {
+	Graphs []*CompositeGraphResult `json:"graphs"`
+}
+
+// Graph returns the result of the graph id, or nil.
+func (resp *CompositeGraphResponse) Graph(id string) *CompositeGraphResult {
+	for _, graph := range resp.Graphs {
+		if graph.GraphId == id {
+			return graph
+		}
+	}
+
+	return nil
+}
+
+// CompositeGraph sends graphs in a single call. The error is only that of the
+// call itself; check IsSuccessful of each graph.
+func (forceApi *ForceApi) CompositeGraph(graphs ...*CompositeGraph) (*CompositeGraphResponse, error) {
+	return forceApi.CompositeGraphContext(context.Background(), graphs...)
+}
+
+// CompositeGraphContext is like CompositeGraph but the request is bound to
+// ctx.
+func (forceApi *ForceApi) CompositeGraphContext(ctx context.Context, graphs ...*CompositeGraph) (*CompositeGraphResponse, error) {
+	if len(graphs) == 0 {
+		return nil, errors.New("force: composite graph request without graphs")
+	}
+
+	type graphPayload struct {
+		GraphId          string                        `json:"graphId"`
+		CompositeRequest []*compositeSubrequestPayload `json:"compositeRequest"`
+	}
+	payload := struct {
+		Graphs []*graphPayload `json:"graphs"`
+	}{}
+
+	nodes := 0
+	for _, graph := range graphs {
+		if graph.Id == "" || graph.Request == nil {
+			return nil, errors.New("force: composite graph without id or request")
+		}
+		subrequests, err := graph.Request.payload(forceApi)
+		if err != nil {
+			return nil, fmt.Errorf("force: graph %v: %v", graph.Id, err)
+		}
+		nodes += len(subrequests)
+		payload.Graphs = append(payload.Graphs, &graphPayload{graph.Id, subrequests})
+	}
+	if nodes > maxCompositeGraphNodes {
+		return nil, fmt.Errorf("force: composite graph request has %v subrequests, more than %v", nodes, maxCompositeGraphNodes)
+	}
+
+	uri, err := forceApi.compositeUri("/graph")
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &CompositeGraphResponse{}
+	if err := forceApi.PostContext(ctx, uri, nil, payload, resp); err != nil {
+		return nil, err
+	}
+	for _, graph := range graphs {
+		if result := resp.Graph(graph.Id); result != nil && result.IsSuccessful {
+			graph.Request.setIDs(&result.Response)
+		}
+	}
+
+	return resp, nil
+}
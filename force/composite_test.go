@@ -0,0 +1,240 @@
+package force
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+// testCompositeAccount gets its id set by SetID, unlike sobjects.Account,
+// whose SetID has a value receiver.
+type testCompositeAccount struct {
+	sobjects.BaseSObject
+	BillingCity string `force:",omitempty"`
+}
+
+func (testCompositeAccount) ApiName() string {
+	return "Account"
+}
+
+type testCompositeSubrequest struct {
+	Method      string                 `json:"method"`
+	Url         string                 `json:"url"`
+	ReferenceId string                 `json:"referenceId"`
+	Body        map[string]interface{} `json:"body"`
+}
+
+func TestComposite(t *testing.T) {
+	org := newFakeOrg(t)
+	var req struct {
+		AllOrNone        bool                       `json:"allOrNone"`
+		CompositeRequest []*testCompositeSubrequest `json:"compositeRequest"`
+	}
+	org.HandleJSON("/services/data/v36.0/composite", func(r *http.Request) interface{} {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Unable to decode request: %v", err)
+		}
+		// The failed update rolls back the insert of an all-or-none request.
+		inserted := map[string]interface{}{
+			"referenceId":    "refAccount",
+			"httpStatusCode": 201,
+			"body":           map[string]interface{}{"id": "001A", "success": true, "errors": []string{}},
+		}
+		if req.AllOrNone {
+			inserted["httpStatusCode"] = 400
+			inserted["body"] = []interface{}{map[string]interface{}{"errorCode": "PROCESSING_HALTED", "message": "The transaction was rolled back"}}
+		}
+		return map[string]interface{}{
+			"compositeResponse": []interface{}{
+				inserted,
+				map[string]interface{}{
+					"referenceId":    "refParent",
+					"httpStatusCode": 400,
+					"body":           []interface{}{map[string]interface{}{"errorCode": "INVALID_FIELD", "message": "No such column"}},
+				},
+				map[string]interface{}{
+					"referenceId":    "refQuery",
+					"httpStatusCode": 200,
+					"body":           map[string]interface{}{"totalSize": 1, "done": true, "records": []interface{}{}},
+				},
+			},
+		}
+	})
+	forceApi := org.New(t)
+
+	account := &testCompositeAccount{BillingCity: "Paris"}
+	parent := &sobjects.Account{}
+	parent.Name = Reference("refAccount", "Name")
+	resp, err := forceApi.Composite((&CompositeRequest{}).
+		Insert("refAccount", account).
+		Update("refParent", Reference("refAccount", "id"), parent).
+		Query("refQuery", "SELECT Id FROM Account"))
+	if err != nil {
+		t.Fatalf("Composite failed: %v", err)
+	}
+
+	if req.AllOrNone || len(req.CompositeRequest) != 3 {
+		t.Fatalf("Unexpected request %+v", req)
+	}
+	if s := req.CompositeRequest[0]; s.Method != "POST" || s.Url != "/services/data/v36.0/sobjects/Account" || s.Body["BillingCity"] != "Paris" {
+		t.Fatalf("Unexpected insert %+v", s)
+	}
+	if s := req.CompositeRequest[1]; s.Method != "PATCH" || s.Url != "/services/data/v36.0/sobjects/Account/@{refAccount.id}" || s.Body["Name"] != "@{refAccount.Name}" {
+		t.Fatalf("Unexpected update %+v", s)
+	}
+	if s := req.CompositeRequest[2]; s.Method != "GET" || s.Url != "/services/data/v36.0/query/?q=SELECT+Id+FROM+Account" {
+		t.Fatalf("Unexpected query %+v", s)
+	}
+
+	if account.Id != "001A" {
+		t.Fatalf("Expected the inserted account to get its id, got %q", account.Id)
+	}
+	if apiErrors, ok := resp.Result("refParent").Err().(ApiErrors); !ok || apiErrors[0].ErrorCode != "INVALID_FIELD" {
+		t.Fatalf("Expected the update to fail with INVALID_FIELD, got %v", resp.Result("refParent").Err())
+	}
+	if resp.Err() == nil {
+		t.Fatal("Expected the response to report the failed subrequest")
+	}
+
+	page := &queryPage{}
+	if err := resp.Result("refQuery").Decode(page); err != nil || page.TotalSize != 1 {
+		t.Fatalf("Unexpected query result %+v: %v", page, err)
+	}
+
+	rolledBack := &testCompositeAccount{BillingCity: "Paris"}
+	resp, err = forceApi.Composite((&CompositeRequest{AllOrNone: true}).
+		Insert("refAccount", rolledBack).
+		Update("refParent", Reference("refAccount", "id"), parent).
+		Query("refQuery", "SELECT Id FROM Account"))
+	if err != nil {
+		t.Fatalf("Composite failed: %v", err)
+	}
+	if !req.AllOrNone || rolledBack.Id != "" {
+		t.Fatalf("Expected the rolled back account to get no id, got %q", rolledBack.Id)
+	}
+}
+
+func TestCompositeInvalid(t *testing.T) {
+	org := newFakeOrg(t)
+	forceApi := org.New(t)
+
+	if _, err := forceApi.Composite(&CompositeRequest{}); err == nil {
+		t.Fatal("Expected an error for a request without subrequests")
+	}
+	if _, err := forceApi.Composite((&CompositeRequest{}).Insert("ref Account", &sobjects.Account{})); err == nil {
+		t.Fatal("Expected an error for an invalid reference id")
+	}
+
+	req := &CompositeRequest{}
+	for i := 0; i < 26; i++ {
+		req.Query("q"+string(rune('a'+i)), "SELECT Id FROM Account")
+	}
+	if _, err := forceApi.Composite(req); err == nil {
+		t.Fatal("Expected an error for more than 25 subrequests")
+	}
+}
+
+func TestCompositeBatch(t *testing.T) {
+	org := newFakeOrg(t)
+	var req struct {
+		HaltOnError   bool `json:"haltOnError"`
+		BatchRequests []struct {
+			Method    string                 `json:"method"`
+			Url       string                 `json:"url"`
+			RichInput map[string]interface{} `json:"richInput"`
+		} `json:"batchRequests"`
+	}
+	org.HandleJSON("/services/data/v36.0/composite/batch", func(r *http.Request) interface{} {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Unable to decode request: %v", err)
+		}
+		return map[string]interface{}{
+			"hasErrors": false,
+			"results": []interface{}{
+				map[string]interface{}{"statusCode": 201, "result": map[string]interface{}{"id": "001B", "success": true}},
+				map[string]interface{}{"statusCode": 204, "result": nil},
+			},
+		}
+	})
+	forceApi := org.New(t)
+
+	account := &testCompositeAccount{BillingCity: "Lyon"}
+	resp, err := forceApi.CompositeBatch((&CompositeBatchRequest{HaltOnError: true}).
+		Insert(account).
+		Delete("001C", &sobjects.Account{}))
+	if err != nil {
+		t.Fatalf("CompositeBatch failed: %v", err)
+	}
+
+	if !req.HaltOnError || len(req.BatchRequests) != 2 {
+		t.Fatalf("Unexpected request %+v", req)
+	}
+	if s := req.BatchRequests[0]; s.Url != "v36.0/sobjects/Account" || s.RichInput["BillingCity"] != "Lyon" {
+		t.Fatalf("Unexpected insert %+v", s)
+	}
+	if s := req.BatchRequests[1]; s.Method != "DELETE" || s.Url != "v36.0/sobjects/Account/001C" {
+		t.Fatalf("Unexpected delete %+v", s)
+	}
+	if resp.HasErrors || len(resp.Results) != 2 || resp.Results[1].HttpStatusCode != 204 || account.Id != "001B" {
+		t.Fatalf("Unexpected response %+v, account id %q", resp, account.Id)
+	}
+}
+
+func TestCompositeGraph(t *testing.T) {
+	org := newFakeOrg(t)
+	var req struct {
+		Graphs []struct {
+			GraphId          string                     `json:"graphId"`
+			CompositeRequest []*testCompositeSubrequest `json:"compositeRequest"`
+		} `json:"graphs"`
+	}
+	org.HandleJSON("/services/data/v36.0/composite/graph", func(r *http.Request) interface{} {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Unable to decode request: %v", err)
+		}
+		return map[string]interface{}{
+			"graphs": []interface{}{
+				map[string]interface{}{
+					"graphId":      "g1",
+					"isSuccessful": true,
+					"graphResponse": map[string]interface{}{
+						"compositeResponse": []interface{}{
+							map[string]interface{}{"referenceId": "refAccount", "httpStatusCode": 201, "body": map[string]interface{}{"id": "001D"}},
+						},
+					},
+				},
+				map[string]interface{}{
+					"graphId":      "g2",
+					"isSuccessful": false,
+					"graphResponse": map[string]interface{}{
+						"compositeResponse": []interface{}{
+							map[string]interface{}{"referenceId": "refAccount", "httpStatusCode": 400, "body": []interface{}{map[string]interface{}{"errorCode": "PROCESSING_HALTED"}}},
+						},
+					},
+				},
+			},
+		}
+	})
+	forceApi := org.New(t)
+
+	first, second := &testCompositeAccount{}, &testCompositeAccount{}
+	resp, err := forceApi.CompositeGraph(
+		&CompositeGraph{Id: "g1", Request: (&CompositeRequest{}).Insert("refAccount", first)},
+		&CompositeGraph{Id: "g2", Request: (&CompositeRequest{}).Insert("refAccount", second)},
+	)
+	if err != nil {
+		t.Fatalf("CompositeGraph failed: %v", err)
+	}
+
+	if len(req.Graphs) != 2 || req.Graphs[1].GraphId != "g2" || req.Graphs[1].CompositeRequest[0].ReferenceId != "refAccount" {
+		t.Fatalf("Unexpected request %+v", req)
+	}
+	if first.Id != "001D" || second.Id != "" {
+		t.Fatalf("Expected only the successful graph to set ids, got %q and %q", first.Id, second.Id)
+	}
+	if g := resp.Graph("g2"); g == nil || g.IsSuccessful || g.Response.Err() == nil {
+		t.Fatalf("Expected graph g2 to fail, got %+v", g)
+	}
+}
@@ -32,7 +32,7 @@ func (e ApiErrors) String() string {
 func (e ApiErrors) Validate() bool {
 	if len(e) != 0 {
 		for _, err := range e {
-			if len(err.ErrorCode) > 0 {
+			if err != nil && len(err.ErrorCode) > 0 {
 				return true
 			}
 		}
@@ -0,0 +1,99 @@
+package force
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+// Leading operation types of a query plan.
+const (
+	LeadingOperationIndex     = "Index"
+	LeadingOperationOther     = "Other"
+	LeadingOperationSharing   = "Sharing"
+	LeadingOperationTableScan = "TableScan"
+)
+
+// QueryExplanation lists the plans force.com considered for a query.
+type QueryExplanation struct {
+	// Plans are ordered from the lowest relative cost, i.e. the plan
+	// force.com would use, to the highest.
+	Plans       []*QueryPlan `json:"plans"`
+	SourceQuery string       `json:"sourceQuery"`
+}
+
+// QueryPlan is a way of running a query.
+type QueryPlan struct {
+	// Cardinality is the estimated number of records the leading operation
+	// returns.
+	Cardinality int64 `json:"cardinality"`
+
+	// Fields are the indexed fields the leading operation uses, if any.
+	Fields               []string `json:"fields"`
+	LeadingOperationType string   `json:"leadingOperationType"`
+
+	// RelativeCost compares the plan to the selectivity threshold: a query
+	// whose best plan costs more than 1 is not selective.
+	RelativeCost       float64          `json:"relativeCost"`
+	SObjectCardinality int64            `json:"sobjectCardinality"`
+	SObjectType        string           `json:"sobjectType"`
+	Notes              []*QueryPlanNote `json:"notes"`
+}
+
+// QueryPlanNote explains why a plan could not use an index, e.g. because a
+// filtered field is not indexed.
+type QueryPlanNote struct {
+	Description   string   `json:"description"`
+	Fields        []string `json:"fields"`
+	TableEnumOrId string   `json:"tableEnumOrId"`
+}
+
+// Selective reports whether the plan is cheap enough for force.com to run the
+// query against large objects without a full table scan.
+func (plan *QueryPlan) Selective() bool {
+	return plan.RelativeCost <= 1 && plan.LeadingOperationType != LeadingOperationTableScan
+}
+
+// Best returns the plan force.com would use, or nil if there is none.
+func (explanation *QueryExplanation) Best() *QueryPlan {
+	var best *QueryPlan
+	for _, plan := range explanation.Plans {
+		if best == nil || plan.RelativeCost < best.RelativeCost {
+			best = plan
+		}
+	}
+
+	return best
+}
+
+// Selective reports whether the query has a selective plan.
+func (explanation *QueryExplanation) Selective() bool {
+	best := explanation.Best()
+	return best != nil && best.Selective()
+}
+
+// ExplainQuery returns the query plans for query, which may also be the id of
+// a report or list view, without running it.
+func (forceApi *ForceApi) ExplainQuery(query string) (*QueryExplanation, error) {
+	return forceApi.ExplainQueryContext(context.Background(), query)
+}
+
+// ExplainQueryContext is like ExplainQuery but the request is bound to ctx.
+func (forceApi *ForceApi) ExplainQueryContext(ctx context.Context, query string) (*QueryExplanation, error) {
+	if query == "" {
+		return nil, errors.New("force: empty query to explain")
+	}
+
+	uri := forceApi.resource(queryKey)
+
+	params := url.Values{
+		"explain": {query},
+	}
+
+	explanation := &QueryExplanation{}
+	if err := forceApi.GetContext(ctx, uri, params, explanation); err != nil {
+		return nil, err
+	}
+
+	return explanation, nil
+}
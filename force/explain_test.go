@@ -0,0 +1,71 @@
+package force
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestExplainQuery(t *testing.T) {
+	org := newFakeOrg(t)
+	var explain string
+	org.HandleJSON("/services/data/v36.0/query/", func(r *http.Request) interface{} {
+		explain = r.URL.Query().Get("explain")
+		return map[string]interface{}{
+			"sourceQuery": explain,
+			"plans": []interface{}{
+				map[string]interface{}{
+					"cardinality":          2000000,
+					"fields":               []string{},
+					"leadingOperationType": "TableScan",
+					"relativeCost":         2.8,
+					"sobjectCardinality":   40000000,
+					"sobjectType":          "Shipment__c",
+					"notes": []interface{}{
+						map[string]interface{}{
+							"description":   "Not considering filter for optimization because unindexed",
+							"fields":        []string{"Status__c"},
+							"tableEnumOrId": "Shipment__c",
+						},
+					},
+				},
+			},
+		}
+	})
+	forceApi := org.New(t)
+
+	query := "SELECT Id FROM Shipment__c WHERE Status__c = 'Open'"
+	explanation, err := forceApi.ExplainQuery(query)
+	if err != nil {
+		t.Fatalf("ExplainQuery failed: %v", err)
+	}
+	if explain != query || explanation.SourceQuery != query {
+		t.Fatalf("Expected %v to be explained, got %v", query, explain)
+	}
+
+	best := explanation.Best()
+	if best == nil || best.Cardinality != 2000000 || best.LeadingOperationType != LeadingOperationTableScan {
+		t.Fatalf("Unexpected plan %+v", best)
+	}
+	if len(best.Notes) != 1 || best.Notes[0].Fields[0] != "Status__c" {
+		t.Fatalf("Unexpected notes %+v", best.Notes)
+	}
+	if explanation.Selective() {
+		t.Fatal("Expected a table scan costing 2.8 not to be selective")
+	}
+}
+
+func TestQueryExplanationSelective(t *testing.T) {
+	explanation := &QueryExplanation{Plans: []*QueryPlan{
+		{LeadingOperationType: LeadingOperationTableScan, RelativeCost: 2.1},
+		{LeadingOperationType: LeadingOperationIndex, RelativeCost: 0.4, Fields: []string{"CreatedDate"}},
+	}}
+	if best := explanation.Best(); best.LeadingOperationType != LeadingOperationIndex {
+		t.Fatalf("Expected the index plan to be best, got %+v", best)
+	}
+	if !explanation.Selective() {
+		t.Fatal("Expected an index plan costing 0.4 to be selective")
+	}
+	if (&QueryExplanation{}).Selective() {
+		t.Fatal("Expected a query without plans not to be selective")
+	}
+}
@@ -18,48 +18,18 @@ const (
 	testEnvironment   = "production"
 )
 
+// Create authenticates with the username-password flow. It is equivalent to
+// New with WithApiVersion, WithConnectedApp, WithPassword, WithEnvironment and
+// WithLogger.
 func Create(version, clientId, clientSecret, userName, password, securityToken,
 	environment, prefix string, logger ForceApiLogger) (ForceApiInterface, error) {
-	oauth := &ForceOauth{
-		clientId:      clientId,
-		clientSecret:  clientSecret,
-		userName:      userName,
-		password:      password,
-		securityToken: securityToken,
-		environment:   environment,
-	}
-
-	forceApi := &ForceApi{
-		apiResources:           make(map[string]string),
-		apiSObjects:            make(map[string]*SObjectMetaData),
-		apiSObjectDescriptions: make(map[string]*SObjectDescription),
-		apiVersion:             version,
-		OAuth:                  oauth,
-	}
-
-	if nil != logger {
-		forceApi.TraceOn("prefix", logger)
-	}
-
-	// Init oauth
-	err := forceApi.OAuth.Authenticate()
-	if err != nil {
-		return nil, err
-	}
-
-	err = forceApi.getApiVersions()
-	if err != nil {
-		return nil, err
-	}
-
-	forceApi.apiVersion = "v" + forceApi.apiVersions[len(forceApi.apiVersions)-1].Version
-
-	// Init Api Resources
-	err = forceApi.getApiResources()
-	if err != nil {
-		return nil, err
-	}
-	err = forceApi.getApiSObjects()
+	forceApi, err := New(
+		WithApiVersion(version),
+		WithConnectedApp(clientId, clientSecret),
+		WithPassword(userName, password, securityToken),
+		WithEnvironment(environment),
+		WithLogger(prefix, logger),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -67,90 +37,45 @@ func Create(version, clientId, clientSecret, userName, password, securityToken,
 	return forceApi, nil
 }
 
+// CreateWithCode authenticates with the web server flow. It is equivalent to
+// New with WithApiVersion, WithConnectedApp, WithAuthorizationCode,
+// WithEnvironment and WithLogger.
 func CreateWithCode(version, clientId, clientSecret, redirectURI, code,
 	environment, prefix string, logger ForceApiLogger) (*ForceApi, *ForceOauth, error) {
-	oauth := &ForceOauth{
-		clientId:     clientId,
-		clientSecret: clientSecret,
-		environment:  environment,
-	}
-
-	forceApi := &ForceApi{
-		apiResources:           make(map[string]string),
-		apiSObjects:            make(map[string]*SObjectMetaData),
-		apiSObjectDescriptions: make(map[string]*SObjectDescription),
-		apiVersion:             version,
-		OAuth:                  oauth,
-	}
-
-	if nil != logger {
-		forceApi.TraceOn("prefix", logger)
-	}
-
-	// Init oauth
-	err := forceApi.OAuth.AuthenticateCode(code, redirectURI)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	err = forceApi.getApiVersions()
-	if err != nil {
-		return nil, nil, err
-	}
-
-	forceApi.apiVersion = "v" + forceApi.apiVersions[len(forceApi.apiVersions)-1].Version
-
-	// Init Api Resources
-	err = forceApi.getApiResources()
-	if err != nil {
-		return nil, nil, err
-	}
-	err = forceApi.getApiSObjects()
+	forceApi, err := New(
+		WithApiVersion(version),
+		WithConnectedApp(clientId, clientSecret),
+		WithAuthorizationCode(code, redirectURI),
+		WithEnvironment(environment),
+		WithLogger(prefix, logger),
+	)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return forceApi, oauth, nil
+	return forceApi, forceApi.OAuth, nil
 }
 
+// CreateWithAccessToken uses a token obtained elsewhere. It is equivalent to
+// New with WithApiVersion, WithConnectedApp, WithAccessToken,
+// WithRefreshToken and WithInstanceURL.
 func CreateWithAccessToken(version, clientId, clientSecret, accessToken, refreshToken, instanceUrl string) (*ForceApi, error) {
-	oauth := &ForceOauth{
-		clientId:     clientId,
-		clientSecret: clientSecret,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		InstanceUrl:  instanceUrl,
-	}
-
-	forceApi := &ForceApi{
-		apiResources:           make(map[string]string),
-		apiSObjects:            make(map[string]*SObjectMetaData),
-		apiSObjectDescriptions: make(map[string]*SObjectDescription),
-		apiVersion:             version,
-		OAuth:                  oauth,
-	}
-
-	// We need to check for oauth correctness here, since we are not generating the token ourselves.
-	if err := forceApi.OAuth.Validate(); err != nil {
-		return nil, err
-	}
-
-	// Init Api Resources
-	err := forceApi.getApiResources()
-	if err != nil {
-		return nil, err
-	}
-	err = forceApi.getApiSObjects()
-	if err != nil {
-		return nil, err
-	}
-
-	return forceApi, nil
+	return New(
+		WithApiVersion(version),
+		WithConnectedApp(clientId, clientSecret),
+		WithAccessToken(accessToken),
+		WithRefreshToken(refreshToken),
+		WithInstanceURL(instanceUrl),
+	)
 }
 
 func (forceApi *ForceApi) PopulateSessionToken() error {
 	var i interface{}
-	return forceApi.Get(forceApi.OAuth.Id, nil, i)
+	forceApi.OAuth.mu.RLock()
+	id := forceApi.OAuth.Id
+	forceApi.OAuth.mu.RUnlock()
+
+	return forceApi.Get(id, nil, i)
 }
 
 // Used when running tests.
@@ -199,42 +124,17 @@ func (forceApi *ForceApi) trace(name string, value interface{}, format string) {
 		forceApi.logger.Printf(logMsg, forceApi.logPrefix, name, value)
 	}
 }
-func CreateWithRefreshToken(version, clientId, clientSecret, accessToken, refreshToken, instanceUrl string)  (*ForceApi, error) {
-	oauth := &ForceOauth{
-		clientId:     clientId,
-		clientSecret: clientSecret,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		InstanceUrl:  instanceUrl,
-	}
 
-	forceApi := &ForceApi{
-		apiResources:           make(map[string]string),
-		apiSObjects:            make(map[string]*SObjectMetaData),
-		apiSObjectDescriptions: make(map[string]*SObjectDescription),
-		apiVersion:             version,
-		OAuth:                  oauth,
-	}
-
-	// obtain access token
-	if err := forceApi.RefreshToken(); err != nil {
-		return nil, err
-	}
-
-	// We need to check for oath correctness here, since we are not generating the token ourselves.
-	if err := forceApi.OAuth.Validate(); err != nil {
-		return nil, err
-	}
-
-	// Init Api Resources
-	err := forceApi.getApiResources()
-	if err != nil {
-		return nil, err
-	}
-	err = forceApi.getApiSObjects()
-	if err != nil {
-		return nil, err
-	}
-
-	return forceApi, nil
-}
\ No newline at end of file
+// CreateWithRefreshToken obtains a fresh access token from instanceUrl with
+// refreshToken; accessToken is ignored. It is equivalent to New with
+// WithApiVersion, WithConnectedApp, WithRefreshToken, WithInstanceURL and
+// WithLoginURL.
+func CreateWithRefreshToken(version, clientId, clientSecret, accessToken, refreshToken, instanceUrl string) (*ForceApi, error) {
+	return New(
+		WithApiVersion(version),
+		WithConnectedApp(clientId, clientSecret),
+		WithRefreshToken(refreshToken),
+		WithInstanceURL(instanceUrl),
+		WithLoginURL(instanceUrl),
+	)
+}
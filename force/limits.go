@@ -1,5 +1,27 @@
 package force
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	limitInfoHeader = "Sforce-Limit-Info"
+
+	// apiUsageStaleAfter is how long a usage report keeps blocking requests
+	// under WithApiUsageLimit.
+	apiUsageStaleAfter = time.Hour
+)
+
+// ErrApiUsageExceeded is returned instead of sending a request once the org's
+// api usage has reached the limit set with WithApiUsageLimit.
+var ErrApiUsageExceeded = errors.New("force: api usage limit reached")
+
 type Limits map[string]Limit
 
 type Limit struct {
@@ -8,10 +30,163 @@ type Limit struct {
 }
 
 func (forceApi *ForceApi) GetLimits() (limits *Limits, err error) {
-	uri := forceApi.apiResources[limitsKey]
+	return forceApi.GetLimitsContext(context.Background())
+}
+
+// GetLimitsContext is like GetLimits but the request is bound to ctx.
+func (forceApi *ForceApi) GetLimitsContext(ctx context.Context) (limits *Limits, err error) {
+	uri := forceApi.resource(limitsKey)
 
 	limits = &Limits{}
-	err = forceApi.Get(uri, nil, limits)
+	err = forceApi.GetContext(ctx, uri, nil, limits)
 
 	return
 }
+
+// ApiUsage is the org's consumption of its daily api request allocation as
+// reported in the Sforce-Limit-Info header of a response.
+type ApiUsage struct {
+	Used       int64
+	Max        int64
+	ReportedAt time.Time
+}
+
+// Remaining returns the number of api requests left in the allocation.
+func (u ApiUsage) Remaining() int64 {
+	return u.Max - u.Used
+}
+
+// Fraction returns the used share of the allocation, between 0 and 1.
+func (u ApiUsage) Fraction() float64 {
+	if u.Max <= 0 {
+		return 0
+	}
+
+	return float64(u.Used) / float64(u.Max)
+}
+
+// ApiUsage returns the api usage reported by the latest response, which costs
+// no request unlike GetLimits. ok is false until a response has reported it.
+func (forceApi *ForceApi) ApiUsage() (usage ApiUsage, ok bool) {
+	return forceApi.usage.latest()
+}
+
+// WithApiUsageCallback calls fn whenever a response reports that api usage
+// has reached threshold, a fraction of the daily allocation such as 0.8. fn
+// is called once each time usage crosses the threshold, not for every
+// response above it, and must not block.
+func WithApiUsageCallback(threshold float64, fn func(ApiUsage)) Option {
+	return func(forceApi *ForceApi) error {
+		if threshold <= 0 || threshold > 1 {
+			return fmt.Errorf("force: api usage threshold %v is not within (0, 1]", threshold)
+		}
+		if fn == nil {
+			return errors.New("force: nil api usage callback")
+		}
+
+		forceApi.usage.callbacks = append(forceApi.usage.callbacks, apiUsageCallback{threshold, fn})
+		return nil
+	}
+}
+
+// WithApiUsageLimit keeps the ForceApi from exhausting the daily allocation,
+// which would also lock out every other integration of the org: once a
+// response reports usage at or above limit, a fraction of the allocation,
+// further requests fail with ErrApiUsageExceeded without being sent. As the
+// allocation is a rolling 24 hour window, a report older than an hour stops
+// blocking requests so the next response can report the current usage.
+func WithApiUsageLimit(limit float64) Option {
+	return func(forceApi *ForceApi) error {
+		if limit <= 0 || limit > 1 {
+			return fmt.Errorf("force: api usage limit %v is not within (0, 1]", limit)
+		}
+
+		forceApi.usage.limit = limit
+		return nil
+	}
+}
+
+type apiUsageCallback struct {
+	threshold float64
+	fn        func(ApiUsage)
+}
+
+// apiUsageTracker keeps the latest api usage reported to a ForceApi.
+type apiUsageTracker struct {
+	limit     float64
+	callbacks []apiUsageCallback
+
+	mu       sync.Mutex
+	usage    ApiUsage
+	reported bool
+}
+
+func (t *apiUsageTracker) latest() (ApiUsage, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.usage, t.reported
+}
+
+// check returns ErrApiUsageExceeded if the latest usage is at or above the
+// configured limit.
+func (t *apiUsageTracker) check() error {
+	if t.limit == 0 {
+		return nil
+	}
+
+	usage, ok := t.latest()
+	if ok && usage.Fraction() >= t.limit && time.Since(usage.ReportedAt) < apiUsageStaleAfter {
+		return fmt.Errorf("%w (%v/%v)", ErrApiUsageExceeded, usage.Used, usage.Max)
+	}
+
+	return nil
+}
+
+// record stores the usage reported in a Sforce-Limit-Info header value, e.g.
+// "api-usage=25/15000", and runs the callbacks whose threshold it crossed.
+func (t *apiUsageTracker) record(header string) {
+	usage, ok := parseApiUsage(header)
+	if !ok {
+		return
+	}
+	usage.ReportedAt = time.Now()
+
+	t.mu.Lock()
+	previous, reported := t.usage, t.reported
+	t.usage, t.reported = usage, true
+	t.mu.Unlock()
+
+	for _, callback := range t.callbacks {
+		crossed := usage.Fraction() >= callback.threshold
+		if crossed && (!reported || previous.Fraction() < callback.threshold) {
+			callback.fn(usage)
+		}
+	}
+}
+
+func parseApiUsage(header string) (ApiUsage, bool) {
+	for _, part := range strings.Split(header, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found || name != "api-usage" {
+			continue
+		}
+
+		used, max, found := strings.Cut(value, "/")
+		if !found {
+			return ApiUsage{}, false
+		}
+		usedCount, err := strconv.ParseInt(used, 10, 64)
+		if err != nil {
+			return ApiUsage{}, false
+		}
+		maxCount, err := strconv.ParseInt(max, 10, 64)
+		if err != nil {
+			return ApiUsage{}, false
+		}
+
+		return ApiUsage{Used: usedCount, Max: maxCount}, true
+	}
+
+	return ApiUsage{}, false
+}
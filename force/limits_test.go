@@ -1,6 +1,10 @@
 package force
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
 	"testing"
 )
 
@@ -15,3 +19,62 @@ func TestLimits(t *testing.T) {
 
 	t.Log(limits)
 }
+
+func TestApiUsage(t *testing.T) {
+	org := newFakeOrg(t)
+
+	var used int32
+	org.mux.HandleFunc("/services/data/v36.0/query/", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&used, 1)
+		w.Header().Set(limitInfoHeader, fmt.Sprintf("api-usage=%d/5, per-app-api-usage=1/10(appName=test)", n))
+		w.Write([]byte(`{"totalSize": 0, "done": true}`))
+	})
+
+	var reports []ApiUsage
+	forceApi := org.New(t,
+		WithApiUsageCallback(0.6, func(usage ApiUsage) { reports = append(reports, usage) }),
+		WithApiUsageLimit(0.8))
+
+	if _, ok := forceApi.ApiUsage(); ok {
+		t.Fatal("Expected no usage before a response reported it")
+	}
+
+	var out map[string]interface{}
+	for i := 1; i <= 4; i++ {
+		if err := forceApi.Query("SELECT Id FROM Account", &out); err != nil {
+			t.Fatalf("Query %v failed: %v", i, err)
+		}
+	}
+
+	usage, ok := forceApi.ApiUsage()
+	if !ok || usage.Used != 4 || usage.Max != 5 || usage.Remaining() != 1 {
+		t.Fatalf("Expected usage 4/5, got %+v", usage)
+	}
+	if len(reports) != 1 || reports[0].Used != 3 {
+		t.Fatalf("Expected a single callback at 3/5, got %+v", reports)
+	}
+
+	err := forceApi.Query("SELECT Id FROM Account", &out)
+	if !errors.Is(err, ErrApiUsageExceeded) {
+		t.Fatalf("Expected the limit to block the query, got: %v", err)
+	}
+	if used != 4 {
+		t.Fatalf("Expected the blocked query not to be sent, got %v requests", used)
+	}
+}
+
+func TestParseApiUsage(t *testing.T) {
+	headers := map[string]*ApiUsage{
+		"api-usage=25/15000": {Used: 25, Max: 15000},
+		"per-app-api-usage=2/100(appName=a),api-usage=7/9": {Used: 7, Max: 9},
+		"per-app-api-usage=2/100(appName=a)":               nil,
+		"api-usage=lots":                                   nil,
+		"":                                                 nil,
+	}
+	for header, expected := range headers {
+		usage, ok := parseApiUsage(header)
+		if ok != (expected != nil) || (ok && usage != *expected) {
+			t.Fatalf("Expected %q to parse as %+v, got %+v, %v", header, expected, usage, ok)
+		}
+	}
+}
@@ -1,21 +1,45 @@
 package force
 
 import (
+	"context"
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
 	grantType    = "password"
-	loginUri     = "https://login.salesforce.com/services/oauth2/token"
-	testLoginUri = "https://test.salesforce.com/services/oauth2/token"
+	loginUrl     = "https://login.salesforce.com"
+	testLoginUrl = "https://test.salesforce.com"
+	tokenUri     = "/services/oauth2/token"
 
 	invalidSessionErrorCode = "INVALID_SESSION_ID"
+
+	redacted = "REDACTED"
+)
+
+// Fields of token requests and responses that are safe to trace. Every other
+// field, such as a client secret, an assertion or a token, is redacted.
+var (
+	traceableTokenParams = map[string]bool{
+		"grant_type":   true,
+		"client_id":    true,
+		"redirect_uri": true,
+		"username":     true,
+	}
+	traceableTokenFields = map[string]bool{
+		"instance_url": true,
+		"id":           true,
+		"issued_at":    true,
+		"token_type":   true,
+		"scope":        true,
+	}
 )
 
 type ForceOauth struct {
@@ -35,16 +59,119 @@ type ForceOauth struct {
 	password      string
 	securityToken string
 	environment   string
+
+	loginUrl          string
+	httpClient        *http.Client
+	userAgent         string
+	jwtKey            *rsa.PrivateKey
+	jwtAudience       string
+	clientCredentials bool
+	tokenSource       TokenSource
+	tokenStore        TokenStore
+	retryPolicy       *RetryPolicy
+
+	// expiry is when the current token is expected to expire, guarded by mu.
+	expiry time.Time
+
+	// tracer is the trace function of the ForceApi the ForceOauth belongs
+	// to, so OAuth messages go to its logger, if any.
+	tracer func(name string, value interface{}, format string)
+
+	// mu guards the exported token fields once the ForceOauth is in use.
+	// refreshMu makes concurrent refreshes of the same expired token
+	// collapse into one.
+	mu        sync.RWMutex
+	refreshMu sync.Mutex
 }
 
 func (oauth *ForceOauth) Validate() error {
-	if oauth == nil || len(oauth.InstanceUrl) == 0 || len(oauth.AccessToken) == 0 {
+	if oauth == nil || len(oauth.instanceUrl()) == 0 || len(oauth.accessToken()) == 0 {
 		return fmt.Errorf("Invalid Force Oauth Object: %#v", oauth)
 	}
 
 	return nil
 }
 
+func (oauth *ForceOauth) accessToken() string {
+	oauth.mu.RLock()
+	defer oauth.mu.RUnlock()
+
+	return oauth.AccessToken
+}
+
+func (oauth *ForceOauth) instanceUrl() string {
+	oauth.mu.RLock()
+	defer oauth.mu.RUnlock()
+
+	return oauth.InstanceUrl
+}
+
+// applyTokenResponse updates the token fields from the JSON response of a
+// token request.
+func (oauth *ForceOauth) applyTokenResponse(respBytes []byte) error {
+	oauth.mu.Lock()
+	defer oauth.mu.Unlock()
+
+	if err := json.Unmarshal(respBytes, oauth); err != nil {
+		return fmt.Errorf("Unable to unmarshal authentication response: %v", err)
+	}
+
+	return nil
+}
+
+// refreshExpired obtains a new access token after a request made with
+// staleToken was rejected. When several requests fail with the same token at
+// once, only the first one reauthenticates and the others reuse its result.
+func (oauth *ForceOauth) refreshExpired(ctx context.Context, staleToken string) error {
+	oauth.refreshMu.Lock()
+	defer oauth.refreshMu.Unlock()
+
+	if oauth.accessToken() != staleToken {
+		return nil
+	}
+
+	return oauth.reauthenticate(ctx)
+}
+
+// tokenUrl returns the token endpoint of the configured login host. A login
+// url set with WithLoginURL takes precedence over the environment.
+func (oauth *ForceOauth) tokenUrl() string {
+	return oauth.loginBaseUrl() + tokenUri
+}
+
+func (oauth *ForceOauth) loginBaseUrl() string {
+	if oauth.loginUrl != "" {
+		return strings.TrimRight(oauth.loginUrl, "/")
+	}
+	if oauth.environment == "sandbox" {
+		return testLoginUrl
+	}
+
+	return loginUrl
+}
+
+func (oauth *ForceOauth) client() *http.Client {
+	if oauth.httpClient != nil {
+		return oauth.httpClient
+	}
+
+	return http.DefaultClient
+}
+
+func (oauth *ForceOauth) getUserAgent() string {
+	if oauth.userAgent != "" {
+		return oauth.userAgent
+	}
+
+	return defaultUserAgent
+}
+
+func (oauth *ForceOauth) trace(name string, value interface{}, format string) {
+	if oauth.tracer != nil {
+		oauth.tracer(name, value, format)
+	}
+}
+
 func (oauth *ForceOauth) Expired(apiErrors ApiErrors) bool {
 	for _, err := range apiErrors {
 		if err.ErrorCode == invalidSessionErrorCode {
@@ -55,33 +182,75 @@ func (oauth *ForceOauth) Expired(apiErrors ApiErrors) bool {
 	return false
 }
 
+// reauthenticate obtains a new access token from the token source or with the
+// grant the ForceOauth was set up for: a refresh token when one was issued, otherwise a fresh JWT
+// bearer assertion, the client credentials flow or the username-password
+// flow.
+func (oauth *ForceOauth) reauthenticate(ctx context.Context) error {
+	switch {
+	case oauth.tokenSource != nil:
+		return oauth.tokenFromSource()
+	case oauth.RefreshToken != "":
+		return oauth.RefreshAccessTokenContext(ctx)
+	case oauth.jwtKey != nil:
+		return oauth.AuthenticateJWTContext(ctx)
+	case oauth.clientCredentials:
+		return oauth.AuthenticateClientCredentialsContext(ctx)
+	}
+
+	return oauth.AuthenticateContext(ctx)
+}
+
 func (oauth *ForceOauth) RefreshAccessToken() error {
-	log.Println("Refreshing access token")
+	return oauth.RefreshAccessTokenContext(context.Background())
+}
+
+// RefreshAccessTokenContext is like RefreshAccessToken but the token request
+// is bound to ctx.
+func (oauth *ForceOauth) RefreshAccessTokenContext(ctx context.Context) error {
+	oauth.trace("OAuth:", "Refreshing access token", "%s")
 	payload := url.Values{
 		"grant_type":    {"refresh_token"},
 		"client_id":     {oauth.clientId},
 		"client_secret": {oauth.clientSecret},
 		"refresh_token": {oauth.RefreshToken},
 	}
-	respBytes, err := oauth.AuthenticatePayload(payload)
+	respBytes, err := oauth.AuthenticatePayloadContext(ctx, payload)
 
 	if nil == err {
-		log.Println("Refreshed access token")
+		oauth.trace("OAuth:", "Refreshed access token", "%s")
 		var updatedOAuth ForceOauth
 		if err := json.Unmarshal(respBytes, &updatedOAuth); err != nil {
 			return fmt.Errorf("Unable to unmarshal authentication response: %v (%s)", err, string(respBytes))
 		}
 
+		oauth.mu.Lock()
 		oauth.AccessToken = updatedOAuth.AccessToken
 		oauth.IssuedAt = updatedOAuth.IssuedAt
+		if updatedOAuth.RefreshToken != "" {
+			// The old refresh token is revoked when the org rotates it.
+			oauth.RefreshToken = updatedOAuth.RefreshToken
+		}
+		if updatedOAuth.InstanceUrl != "" {
+			oauth.InstanceUrl = updatedOAuth.InstanceUrl
+		}
+		oauth.mu.Unlock()
+
+		return oauth.tokenIssued()
 	} else {
-		log.Println("Refreshing access token: " + err.Error())
+		oauth.trace("OAuth:", "Refreshing access token: "+err.Error(), "%s")
 	}
 
 	return err
 }
 
 func (oauth *ForceOauth) Authenticate() error {
+	return oauth.AuthenticateContext(context.Background())
+}
+
+// AuthenticateContext is like Authenticate but the token request is bound to
+// ctx.
+func (oauth *ForceOauth) AuthenticateContext(ctx context.Context) error {
 	payload := url.Values{
 		"grant_type":    {grantType},
 		"client_id":     {oauth.clientId},
@@ -90,117 +259,194 @@ func (oauth *ForceOauth) Authenticate() error {
 		"password":      {fmt.Sprintf("%v%v", oauth.password, oauth.securityToken)},
 	}
 
-	// Build Uri
-	uri := loginUri
-	if oauth.environment == "sandbox" {
-		uri = testLoginUri
+	respBytes, err := oauth.AuthenticatePayloadContext(ctx, payload)
+	if err != nil {
+		return err
 	}
 
-	// Build Body
-	body := strings.NewReader(payload.Encode())
-
-	// Build Request
-	req, err := http.NewRequest("POST", uri, body)
-	if err != nil {
-		return fmt.Errorf("Error creating authenitcation request: %v", err)
+	if err := oauth.applyTokenResponse(respBytes); err != nil {
+		return err
 	}
 
-	// Add Headers
-	req.Header.Set("User-Agent", userAgent)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", responseType)
+	return oauth.tokenIssued()
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("Error sending authentication request: %v", err)
-	}
-	defer resp.Body.Close()
+func (oauth *ForceOauth) AuthenticateCode(code string, redirectURI string) error {
+	return oauth.AuthenticateCodeContext(context.Background(), code, redirectURI)
+}
 
-	respBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("Error reading authentication response bytes: %v", err)
+// AuthenticateCodeContext is like AuthenticateCode but the token request is
+// bound to ctx.
+func (oauth *ForceOauth) AuthenticateCodeContext(ctx context.Context, code string, redirectURI string) error {
+	return oauth.AuthenticateCodePKCEContext(ctx, code, redirectURI, "")
+}
+
+// AuthenticateCodePKCE is like AuthenticateCode but also sends the PKCE code
+// verifier whose challenge was part of the authorize url. The client secret is
+// only sent when one is configured, so public clients can omit it.
+func (oauth *ForceOauth) AuthenticateCodePKCE(code, redirectURI, codeVerifier string) error {
+	return oauth.AuthenticateCodePKCEContext(context.Background(), code, redirectURI, codeVerifier)
+}
+
+// AuthenticateCodePKCEContext is like AuthenticateCodePKCE but the token
+// request is bound to ctx.
+func (oauth *ForceOauth) AuthenticateCodePKCEContext(ctx context.Context, code, redirectURI, codeVerifier string) error {
+	payload := url.Values{
+		"grant_type":   {"authorization_code"},
+		"client_id":    {oauth.clientId},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+	}
+	if oauth.clientSecret != "" {
+		payload.Set("client_secret", oauth.clientSecret)
 	}
+	if codeVerifier != "" {
+		payload.Set("code_verifier", codeVerifier)
+	}
+
+	respBytes, err := oauth.AuthenticatePayloadContext(ctx, payload)
 
-	// Attempt to parse response as a force.com api error
-	apiError := &ApiError{}
-	if err := json.Unmarshal(respBytes, apiError); err == nil {
-		// Check if api error is valid
-		if apiError.Validate() {
-			return apiError
+	if nil == err {
+		if err := oauth.applyTokenResponse(respBytes); err != nil {
+			return err
 		}
-	}
 
-	if err := json.Unmarshal(respBytes, oauth); err != nil {
-		return fmt.Errorf("Unable to unmarshal authentication response: %v", err)
+		return oauth.tokenIssued()
 	}
 
-	return nil
+	return err
 }
 
-func (oauth *ForceOauth) AuthenticateCode(code string, redirectURI string) error {
+// AuthenticateClientCredentials obtains an access token for the connected
+// app's run-as user with the client credentials flow. The login url must be
+// the org's My Domain.
+func (oauth *ForceOauth) AuthenticateClientCredentials() error {
+	return oauth.AuthenticateClientCredentialsContext(context.Background())
+}
+
+// AuthenticateClientCredentialsContext is like AuthenticateClientCredentials
+// but the token request is bound to ctx.
+func (oauth *ForceOauth) AuthenticateClientCredentialsContext(ctx context.Context) error {
 	payload := url.Values{
-		"grant_type":    {"authorization_code"},
+		"grant_type":    {"client_credentials"},
 		"client_id":     {oauth.clientId},
 		"client_secret": {oauth.clientSecret},
-		"code":          {code},
-		"redirect_uri":  {redirectURI},
 	}
 
-	respBytes, err := oauth.AuthenticatePayload(payload)
+	respBytes, err := oauth.AuthenticatePayloadContext(ctx, payload)
+	if err != nil {
+		return err
+	}
 
-	if nil == err {
-		if err := json.Unmarshal(respBytes, oauth); err != nil {
-			return fmt.Errorf("Unable to unmarshal authentication response: %v", err)
-		}
+	if err := oauth.applyTokenResponse(respBytes); err != nil {
+		return err
 	}
 
-	return nil
+	return oauth.tokenIssued()
 }
 
 func (oauth *ForceOauth) AuthenticatePayload(payload url.Values) ([]byte, error) {
-	// Build Uri
-	uri := loginUri
-	if oauth.environment == "sandbox" {
-		uri = testLoginUri
-	}
+	return oauth.AuthenticatePayloadContext(context.Background(), payload)
+}
 
-	// Build Body
-	body := strings.NewReader(payload.Encode())
+// AuthenticatePayloadContext is like AuthenticatePayload but the token request
+// is bound to ctx.
+func (oauth *ForceOauth) AuthenticatePayloadContext(ctx context.Context, payload url.Values) ([]byte, error) {
+	oauth.trace("Token Request:", oauth.tokenUrl()+" "+redactTokenPayload(payload), "%s")
 
-	// Build Request
-	req, err := http.NewRequest("POST", uri, body)
+	respBytes, err := oauth.postToken(ctx, payload)
 	if err != nil {
-		return nil, fmt.Errorf("Error creating authenitcation request: %v", err)
+		return nil, err
 	}
 
-	log.Printf("body: %v, %+v", uri, payload.Encode())
+	oauth.trace("Token Response:", redactTokenResponse(respBytes), "%s")
+
+	return respBytes, nil
+}
+
+// postToken sends payload to the token endpoint and returns the response
+// body. Token requests are retried on transient failures according to the
+// retry policy; replaying them is safe since a failed grant has no effect.
+func (oauth *ForceOauth) postToken(ctx context.Context, payload url.Values) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		// Build Request
+		req, err := http.NewRequestWithContext(ctx, "POST", oauth.tokenUrl(), strings.NewReader(payload.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("Error creating authenitcation request: %v", err)
+		}
 
-	// Add Headers
-	req.Header.Set("User-Agent", userAgent)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", responseType)
+		// Add Headers
+		req.Header.Set("User-Agent", oauth.getUserAgent())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", responseType)
+
+		resp, err := oauth.client().Do(req)
+		if err != nil {
+			retry, waitErr := oauth.retryPolicy.retry(ctx, attempt, true, nil, nil, err)
+			if retry {
+				continue
+			}
+			if waitErr != nil {
+				err = waitErr
+			}
+			return nil, fmt.Errorf("Error sending authentication request: %w", err)
+		}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("Error sending authentication request: %v", err)
+		respBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading authentication response bytes: %v", err)
+		}
+
+		retry, err := oauth.retryPolicy.retry(ctx, attempt, true, resp, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Error sending authentication request: %w", err)
+		}
+		if retry {
+			continue
+		}
+
+		// Attempt to parse response as a force.com api error
+		apiError := &ApiError{}
+		if err := json.Unmarshal(respBytes, apiError); err == nil {
+			// Check if api error is valid
+			if apiError.Validate() {
+				return nil, apiError
+			}
+		}
+
+		return respBytes, nil
 	}
-	defer resp.Body.Close()
+}
 
-	respBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("Error reading authentication response bytes: %v", err)
+func redactTokenPayload(payload url.Values) string {
+	traced := url.Values{}
+	for key, values := range payload {
+		if traceableTokenParams[key] {
+			traced[key] = values
+		} else {
+			traced.Set(key, redacted)
+		}
 	}
 
-	log.Printf("respBytes: %+v", string(respBytes))
+	return traced.Encode()
+}
 
-	// Attempt to parse response as a force.com api error
-	apiError := &ApiError{}
-	if err := json.Unmarshal(respBytes, apiError); err == nil {
-		// Check if api error is valid
-		if apiError.Validate() {
-			return nil, apiError
+func redactTokenResponse(respBytes []byte) string {
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(respBytes, &fields); err != nil {
+		return redacted
+	}
+	for key := range fields {
+		if !traceableTokenFields[key] {
+			fields[key] = redacted
 		}
 	}
 
-	return respBytes, nil
+	traced, err := json.Marshal(fields)
+	if err != nil {
+		return redacted
+	}
+
+	return string(traced)
 }
@@ -0,0 +1,157 @@
+package force
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	jwtBearerGrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+	// Salesforce rejects assertions that expire more than three minutes
+	// in the future.
+	jwtLifetime = 3 * time.Minute
+)
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Issuer     string `json:"iss"`
+	Subject    string `json:"sub"`
+	Audience   string `json:"aud"`
+	Expiration int64  `json:"exp"`
+}
+
+// ParseRSAPrivateKey parses a PEM encoded RSA private key in either PKCS#1
+// ("RSA PRIVATE KEY") or PKCS#8 ("PRIVATE KEY") form, as used to sign JWT
+// bearer assertions.
+func ParseRSAPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("force: no PEM block found in private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("force: unable to parse private key: %v", err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("force: private key is a %T, not an RSA key", parsed)
+	}
+
+	return key, nil
+}
+
+// WithJWTBearer authenticates with the OAuth 2.0 JWT bearer flow, signing an
+// assertion for userName with key. The connected app set with
+// WithConnectedApp must have the matching certificate uploaded and userName
+// must be pre-authorized. A new token is minted whenever the current one
+// expires.
+func WithJWTBearer(userName string, key *rsa.PrivateKey) Option {
+	return func(forceApi *ForceApi) error {
+		if key == nil {
+			return errors.New("force: nil JWT signing key")
+		}
+
+		forceApi.OAuth.userName = userName
+		forceApi.OAuth.jwtKey = key
+		return nil
+	}
+}
+
+// WithJWTAudience overrides the audience of JWT bearer assertions. It
+// defaults to login.salesforce.com, or test.salesforce.com in the sandbox
+// environment; Experience Cloud sites use their site url.
+func WithJWTAudience(audience string) Option {
+	return func(forceApi *ForceApi) error {
+		forceApi.OAuth.jwtAudience = audience
+		return nil
+	}
+}
+
+// AuthenticateJWT obtains an access token with the JWT bearer flow.
+func (oauth *ForceOauth) AuthenticateJWT() error {
+	return oauth.AuthenticateJWTContext(context.Background())
+}
+
+// AuthenticateJWTContext is like AuthenticateJWT but the token request is
+// bound to ctx.
+func (oauth *ForceOauth) AuthenticateJWTContext(ctx context.Context) error {
+	assertion, err := oauth.jwtAssertion(time.Now())
+	if err != nil {
+		return err
+	}
+
+	payload := url.Values{
+		"grant_type": {jwtBearerGrantType},
+		"assertion":  {assertion},
+	}
+
+	respBytes, err := oauth.AuthenticatePayloadContext(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	if err := oauth.applyTokenResponse(respBytes); err != nil {
+		return err
+	}
+
+	return oauth.tokenIssued()
+}
+
+func (oauth *ForceOauth) jwtAssertion(now time.Time) (string, error) {
+	if oauth.jwtKey == nil {
+		return "", errors.New("force: no JWT signing key configured")
+	}
+
+	audience := oauth.jwtAudience
+	if audience == "" {
+		audience = loginUrl
+		if oauth.environment == "sandbox" {
+			audience = testLoginUrl
+		}
+	}
+
+	header, err := json.Marshal(jwtHeader{Algorithm: "RS256"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(jwtClaims{
+		Issuer:     oauth.clientId,
+		Subject:    oauth.userName,
+		Audience:   audience,
+		Expiration: now.Add(jwtLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, oauth.jwtKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("force: unable to sign JWT assertion: %v", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,160 @@
+package force
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func generateTestKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate RSA key: %v", err)
+	}
+
+	return key
+}
+
+func TestParseRSAPrivateKey(t *testing.T) {
+	key := generateTestKey(t)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Unable to marshal PKCS#8 key: %v", err)
+	}
+
+	blocks := []*pem.Block{
+		{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		{Type: "PRIVATE KEY", Bytes: pkcs8},
+	}
+	for _, block := range blocks {
+		parsed, err := ParseRSAPrivateKey(pem.EncodeToMemory(block))
+		if err != nil {
+			t.Fatalf("Unable to parse %v: %v", block.Type, err)
+		}
+		if !parsed.Equal(key) {
+			t.Fatalf("Parsed %v does not match the original key", block.Type)
+		}
+	}
+
+	if _, err := ParseRSAPrivateKey([]byte("not a key")); err == nil {
+		t.Fatal("Expected an error for input without a PEM block")
+	}
+}
+
+// verifyTestAssertion checks the signature of a JWT bearer assertion and
+// returns its claims.
+func verifyTestAssertion(t *testing.T, key *rsa.PrivateKey, assertion string) *jwtClaims {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Malformed assertion: %v", assertion)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("Malformed signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Fatalf("Invalid signature: %v", err)
+	}
+
+	claimBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("Malformed claims: %v", err)
+	}
+	claims := &jwtClaims{}
+	if err := json.Unmarshal(claimBytes, claims); err != nil {
+		t.Fatalf("Malformed claims: %v", err)
+	}
+
+	return claims
+}
+
+func TestJWTBearer(t *testing.T) {
+	key := generateTestKey(t)
+	org := newFakeOrg(t)
+	org.OnToken = func(r *http.Request) *ApiError {
+		if r.FormValue("grant_type") != jwtBearerGrantType {
+			return &ApiError{ErrorName: "unsupported_grant_type"}
+		}
+
+		claims := verifyTestAssertion(t, key, r.FormValue("assertion"))
+		if claims.Issuer != "client" || claims.Subject != "integration@example.com" || claims.Audience != testLoginUrl {
+			return &ApiError{ErrorName: "invalid_grant", ErrorDescription: "bad claims"}
+		}
+		return nil
+	}
+
+	// Only the second token is accepted, so the first query has to mint a
+	// new one.
+	queries := 0
+	org.HandleJSON("/services/data/v36.0/query/", func(r *http.Request) interface{} {
+		queries++
+		if r.Header.Get("Authorization") != "Bearer token2" {
+			return ApiErrors{{ErrorCode: invalidSessionErrorCode, Message: "Session expired or invalid"}}
+		}
+		return map[string]interface{}{"totalSize": 0, "done": true, "records": []interface{}{}}
+	})
+
+	forceApi, err := New(
+		WithLoginURL(org.URL),
+		WithEnvironment("sandbox"),
+		WithConnectedApp("client", ""),
+		WithJWTBearer("integration@example.com", key),
+	)
+	if err != nil {
+		t.Fatalf("Unable to create ForceApi with JWT bearer flow: %v", err)
+	}
+
+	var out map[string]interface{}
+	if err := forceApi.Query("SELECT Id FROM Account", &out); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if org.tokenRequests != 2 || queries != 2 {
+		t.Fatalf("Expected 2 token requests and 2 queries, got %v and %v", org.tokenRequests, queries)
+	}
+}
+
+func TestJWTBearerTraceRedactsSecrets(t *testing.T) {
+	var std bytes.Buffer
+	log.SetOutput(&std)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	key := generateTestKey(t)
+	org := newFakeOrg(t)
+	var assertion string
+	org.OnToken = func(r *http.Request) *ApiError {
+		assertion = r.FormValue("assertion")
+		return nil
+	}
+	logger := &recordingLogger{}
+
+	if _, err := New(WithLoginURL(org.URL), WithConnectedApp("client", ""), WithJWTBearer("integration@example.com", key), WithLogger("", logger)); err != nil {
+		t.Fatalf("Unable to create ForceApi with JWT bearer flow: %v", err)
+	}
+
+	// Only token requests are checked; TraceOn traces the headers of API
+	// requests as they are.
+	traced := logger.Prefixed("Token ")
+	if !strings.Contains(traced, "Token Request:") || !strings.Contains(traced, "grant_type=") {
+		t.Fatalf("Expected the token request to be traced, got %q", traced)
+	}
+	if assertion == "" || strings.Contains(traced, assertion) || strings.Contains(traced, "token1") {
+		t.Fatalf("Expected the assertion and the access token to be redacted, got %q", traced)
+	}
+	if std.Len() != 0 {
+		t.Fatalf("Expected nothing on the standard logger, got %q", std.String())
+	}
+}
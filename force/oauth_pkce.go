@@ -0,0 +1,179 @@
+package force
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	authorizeUri = "/services/oauth2/authorize"
+
+	pkceChallengeMethod = "S256"
+)
+
+// ErrStateMismatch is returned when the state of an authorization callback
+// does not match the state sent in the authorize url.
+var ErrStateMismatch = errors.New("force: oauth state mismatch")
+
+// PKCE holds a Proof Key for Code Exchange verifier and its S256 challenge.
+type PKCE struct {
+	Verifier  string
+	Challenge string
+	Method    string
+}
+
+// NewPKCE generates a random code verifier and its challenge.
+func NewPKCE() (*PKCE, error) {
+	verifier, err := randomUrlSafeString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PKCE{
+		Verifier:  verifier,
+		Challenge: pkceChallenge(verifier),
+		Method:    pkceChallengeMethod,
+	}, nil
+}
+
+func pkceChallenge(verifier string) string {
+	digest := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+// NewState generates a random value for the state parameter of an authorize
+// url.
+func NewState() (string, error) {
+	return randomUrlSafeString(24)
+}
+
+func randomUrlSafeString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("force: unable to read random bytes: %v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// VerifyState reports ErrStateMismatch unless the state returned to the
+// callback equals the expected one. The comparison is constant time.
+func VerifyState(expected, got string) error {
+	if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(got)) != 1 {
+		return ErrStateMismatch
+	}
+
+	return nil
+}
+
+// AuthCodeFlow drives the OAuth web server flow with PKCE. Start builds the
+// authorize url the user is sent to, and Exchange turns the callback into an
+// authenticated ForceApi. The flow itself holds no per-user state, so one
+// AuthCodeFlow can serve many concurrent logins.
+type AuthCodeFlow struct {
+	RedirectURI string
+	Scopes      []string
+
+	opts  []Option
+	oauth *ForceOauth
+}
+
+// AuthCodeRequest is a started authorization. Keep it (e.g. in the user's
+// session) until the callback arrives.
+type AuthCodeRequest struct {
+	URL          string
+	State        string
+	CodeVerifier string
+}
+
+// NewAuthCodeFlow creates a web server flow that redirects to redirectURI.
+// opts must include WithConnectedApp; the client secret may be empty for
+// public clients. The same opts are used to create the ForceApi in Exchange.
+func NewAuthCodeFlow(redirectURI string, opts ...Option) (*AuthCodeFlow, error) {
+	forceApi, err := newForceApi(opts)
+	if err != nil {
+		return nil, err
+	}
+	if forceApi.OAuth.clientId == "" {
+		return nil, errors.New("force: the web server flow requires a client id")
+	}
+
+	return &AuthCodeFlow{
+		RedirectURI: redirectURI,
+		opts:        opts,
+		oauth:       forceApi.OAuth,
+	}, nil
+}
+
+// Start generates a state and PKCE verifier and builds the authorize url
+// carrying them.
+func (flow *AuthCodeFlow) Start() (*AuthCodeRequest, error) {
+	state, err := NewState()
+	if err != nil {
+		return nil, err
+	}
+	pkce, err := NewPKCE()
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthCodeRequest{
+		URL:          flow.AuthorizeURL(state, pkce),
+		State:        state,
+		CodeVerifier: pkce.Verifier,
+	}, nil
+}
+
+// AuthorizeURL builds the authorize url for state and, when pkce is not nil,
+// its code challenge.
+func (flow *AuthCodeFlow) AuthorizeURL(state string, pkce *PKCE) string {
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {flow.oauth.clientId},
+		"redirect_uri":  {flow.RedirectURI},
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	if pkce != nil {
+		params.Set("code_challenge", pkce.Challenge)
+		params.Set("code_challenge_method", pkce.Method)
+	}
+	if len(flow.Scopes) > 0 {
+		params.Set("scope", strings.Join(flow.Scopes, " "))
+	}
+
+	return flow.oauth.loginBaseUrl() + authorizeUri + "?" + params.Encode()
+}
+
+// Exchange verifies the state of the callback query against req, redeems the
+// authorization code with req's code verifier and returns the resulting
+// ForceApi. An error reported by the authorization server is returned as an
+// *ApiError.
+func (flow *AuthCodeFlow) Exchange(ctx context.Context, req *AuthCodeRequest, callback url.Values) (*ForceApi, error) {
+	if name := callback.Get("error"); name != "" {
+		return nil, &ApiError{ErrorName: name, ErrorDescription: callback.Get("error_description")}
+	}
+	if err := VerifyState(req.State, callback.Get("state")); err != nil {
+		return nil, err
+	}
+
+	code := callback.Get("code")
+	if code == "" {
+		return nil, errors.New("force: authorization callback has no code")
+	}
+
+	opts := append(append([]Option{}, flow.opts...),
+		WithAuthorizationCode(code, flow.RedirectURI),
+		WithCodeVerifier(req.CodeVerifier),
+	)
+
+	return NewContext(ctx, opts...)
+}
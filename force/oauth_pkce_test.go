@@ -0,0 +1,118 @@
+package force
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAuthCodeFlow(t *testing.T) {
+	org := newFakeOrg(t)
+
+	flow, err := NewAuthCodeFlow("http://localhost:8080/callback",
+		WithLoginURL(org.URL),
+		WithConnectedApp("client", ""),
+	)
+	if err != nil {
+		t.Fatalf("Unable to create flow: %v", err)
+	}
+	flow.Scopes = []string{"api", "refresh_token"}
+
+	req, err := flow.Start()
+	if err != nil {
+		t.Fatalf("Unable to start flow: %v", err)
+	}
+
+	authorize, err := url.Parse(req.URL)
+	if err != nil {
+		t.Fatalf("Invalid authorize url: %v", err)
+	}
+	if !strings.HasPrefix(req.URL, org.URL+authorizeUri+"?") {
+		t.Fatalf("Unexpected authorize url: %v", req.URL)
+	}
+	query := authorize.Query()
+	if query.Get("state") != req.State || query.Get("client_id") != "client" || query.Get("scope") != "api refresh_token" {
+		t.Fatalf("Unexpected authorize parameters: %v", query)
+	}
+	challenge := query.Get("code_challenge")
+	if challenge != pkceChallenge(req.CodeVerifier) || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("Unexpected code challenge: %v", query)
+	}
+
+	org.OnToken = func(r *http.Request) *ApiError {
+		if r.FormValue("code") != "the-code" || pkceChallenge(r.FormValue("code_verifier")) != challenge {
+			return &ApiError{ErrorName: "invalid_grant"}
+		}
+		if _, ok := r.PostForm["client_secret"]; ok {
+			return &ApiError{ErrorName: "invalid_client", ErrorDescription: "public clients send no secret"}
+		}
+		return nil
+	}
+
+	forged := url.Values{"code": {"the-code"}, "state": {"forged"}}
+	if _, err := flow.Exchange(context.Background(), req, forged); err != ErrStateMismatch {
+		t.Fatalf("Expected state mismatch, got: %v", err)
+	}
+
+	denied := url.Values{"error": {"access_denied"}, "state": {req.State}}
+	if _, err := flow.Exchange(context.Background(), req, denied); err == nil {
+		t.Fatal("Expected the authorization error to be returned")
+	}
+
+	callback := url.Values{"code": {"the-code"}, "state": {req.State}}
+	forceApi, err := flow.Exchange(context.Background(), req, callback)
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if err := forceApi.OAuth.Validate(); err != nil {
+		t.Fatalf("Oauth object is invalid: %v", err)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	org := newFakeOrg(t)
+	org.OnToken = func(r *http.Request) *ApiError {
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_secret") != "secret" {
+			return &ApiError{ErrorName: "unsupported_grant_type"}
+		}
+		return nil
+	}
+
+	_, err := New(
+		WithLoginURL(org.URL),
+		WithConnectedApp("client", "secret"),
+		WithClientCredentials(),
+	)
+	if err != nil {
+		t.Fatalf("Unable to create ForceApi with client credentials: %v", err)
+	}
+}
+
+func TestOAuthTraceRedactsSecrets(t *testing.T) {
+	org := newFakeOrg(t)
+	grants := map[string][]Option{
+		"authorization_code": {WithAuthorizationCode("the-code", "http://localhost:8080/callback"), WithCodeVerifier("the-verifier")},
+		"client_credentials": {WithClientCredentials()},
+		"password":           {WithPassword("user", "the-password", "the-security-token")},
+		"refresh_token":      {WithRefreshToken("the-refresh-token")},
+	}
+	for grant, opts := range grants {
+		logger := &recordingLogger{}
+		opts = append([]Option{WithLoginURL(org.URL), WithConnectedApp("client", "the-secret"), WithLogger("", logger)}, opts...)
+		if _, err := New(opts...); err != nil {
+			t.Fatalf("Unable to create ForceApi with %v: %v", grant, err)
+		}
+
+		traced := logger.Prefixed("Token ")
+		if !strings.Contains(traced, "grant_type="+grant) {
+			t.Fatalf("Expected the %v request to be traced, got %q", grant, traced)
+		}
+		for _, secret := range []string{"the-code", "the-verifier", "the-secret", "the-refresh-token", "the-password", "the-security-token", `:"token`} {
+			if strings.Contains(traced, secret) {
+				t.Fatalf("Expected %v to be redacted from the %v trace, got %q", secret, grant, traced)
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package force
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Option configures a ForceApi created by New.
+type Option func(*ForceApi) error
+
+// WithHTTPClient makes the ForceApi and its OAuth flows send every request
+// through client instead of http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(forceApi *ForceApi) error {
+		if client == nil {
+			return errors.New("force: nil http client")
+		}
+
+		forceApi.httpClient = client
+		return nil
+	}
+}
+
+// WithRoundTripper sends every request through transport, e.g. to route
+// traffic through a proxy or to record it in tests. Any client set with
+// WithHTTPClient keeps its other settings.
+func WithRoundTripper(transport http.RoundTripper) Option {
+	return func(forceApi *ForceApi) error {
+		if transport == nil {
+			return errors.New("force: nil round tripper")
+		}
+
+		client := &http.Client{}
+		if forceApi.httpClient != nil {
+			*client = *forceApi.httpClient
+		}
+		client.Transport = transport
+
+		forceApi.httpClient = client
+		return nil
+	}
+}
+
+// WithLoginURL overrides the host used for OAuth, e.g. a My Domain
+// (https://example.my.salesforce.com), a community url or a local test
+// server. It takes precedence over WithEnvironment.
+func WithLoginURL(loginUrl string) Option {
+	return func(forceApi *ForceApi) error {
+		u, err := url.Parse(loginUrl)
+		if err != nil {
+			return fmt.Errorf("force: invalid login url %q: %v", loginUrl, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("force: invalid login url %q: scheme and host are required", loginUrl)
+		}
+
+		forceApi.OAuth.loginUrl = loginUrl
+		return nil
+	}
+}
+
+// WithEnvironment selects the default login host: "sandbox" uses
+// test.salesforce.com, anything else uses login.salesforce.com.
+func WithEnvironment(environment string) Option {
+	return func(forceApi *ForceApi) error {
+		forceApi.OAuth.environment = environment
+		return nil
+	}
+}
+
+// apiVersionPattern matches API versions such as "v36.0" or "36.0".
+var apiVersionPattern = regexp.MustCompile(`^v?[0-9]+\.[0-9]+$`)
+
+// WithApiVersion pins the API version, e.g. "v36.0" or "36.0", instead of
+// negotiating the latest version the org supports. New fails if the org does
+// not support it. An empty version leaves the version to be negotiated.
+func WithApiVersion(version string) Option {
+	return func(forceApi *ForceApi) error {
+		if version == "" {
+			return nil
+		}
+		if !apiVersionPattern.MatchString(version) {
+			return fmt.Errorf("force: invalid api version %q", version)
+		}
+		forceApi.apiVersion = "v" + strings.TrimPrefix(version, "v")
+		return nil
+	}
+}
+
+// WithLogger turns on tracing as TraceOn does, for the OAuth flows as well;
+// token requests and responses are traced with their secrets redacted. A nil
+// logger leaves tracing off.
+func WithLogger(prefix string, logger ForceApiLogger) Option {
+	return func(forceApi *ForceApi) error {
+		if logger != nil {
+			forceApi.TraceOn(prefix, logger)
+		}
+		return nil
+	}
+}
+
+// WithUserAgent replaces the default go-force User-Agent header.
+func WithUserAgent(userAgent string) Option {
+	return func(forceApi *ForceApi) error {
+		forceApi.userAgent = userAgent
+		return nil
+	}
+}
+
+// WithConnectedApp sets the consumer key and secret of the connected app
+// used by every OAuth flow.
+func WithConnectedApp(clientId, clientSecret string) Option {
+	return func(forceApi *ForceApi) error {
+		forceApi.OAuth.clientId = clientId
+		forceApi.OAuth.clientSecret = clientSecret
+		return nil
+	}
+}
+
+// WithPassword authenticates with the username-password flow.
+func WithPassword(userName, password, securityToken string) Option {
+	return func(forceApi *ForceApi) error {
+		forceApi.OAuth.userName = userName
+		forceApi.OAuth.password = password
+		forceApi.OAuth.securityToken = securityToken
+		return nil
+	}
+}
+
+// WithAuthorizationCode authenticates by exchanging the code returned to
+// redirectURI by the web server flow.
+func WithAuthorizationCode(code, redirectURI string) Option {
+	return func(forceApi *ForceApi) error {
+		forceApi.authCode = code
+		forceApi.authRedirectURI = redirectURI
+		return nil
+	}
+}
+
+// WithCodeVerifier sends the PKCE code verifier along with the code set by
+// WithAuthorizationCode.
+func WithCodeVerifier(codeVerifier string) Option {
+	return func(forceApi *ForceApi) error {
+		forceApi.authCodeVerifier = codeVerifier
+		return nil
+	}
+}
+
+// WithClientCredentials authenticates as the connected app's run-as user with
+// the client credentials flow. It requires WithConnectedApp and a My Domain
+// set with WithLoginURL.
+func WithClientCredentials() Option {
+	return func(forceApi *ForceApi) error {
+		forceApi.OAuth.clientCredentials = true
+		return nil
+	}
+}
+
+// WithAccessToken uses an access token obtained elsewhere. It must be
+// combined with WithInstanceURL.
+func WithAccessToken(accessToken string) Option {
+	return func(forceApi *ForceApi) error {
+		forceApi.OAuth.AccessToken = accessToken
+		return nil
+	}
+}
+
+// WithRefreshToken sets the refresh token used to obtain a new access token
+// when the current one expires. Without WithAccessToken it is used to obtain
+// the first access token as well.
+func WithRefreshToken(refreshToken string) Option {
+	return func(forceApi *ForceApi) error {
+		forceApi.OAuth.RefreshToken = refreshToken
+		return nil
+	}
+}
+
+// WithInstanceURL sets the instance url (e.g. https://na1.salesforce.com) for
+// an access token obtained elsewhere.
+func WithInstanceURL(instanceUrl string) Option {
+	return func(forceApi *ForceApi) error {
+		forceApi.OAuth.InstanceUrl = instanceUrl
+		return nil
+	}
+}
+
+// New creates a ForceApi configured by opts, authenticates it and loads the
+// org's resources and sobjects.
+//
+// The credentials decide the OAuth flow: an authorization code is exchanged
+// first, then a token source is asked, then a valid token from the token store
+// or an access token is used as is, then a refresh token is
+// redeemed, then a JWT bearer assertion is signed, then the client
+// credentials flow is used, and finally the username-password flow is used.
+func New(opts ...Option) (*ForceApi, error) {
+	return NewContext(context.Background(), opts...)
+}
+
+// NewContext is like New but every request made while creating the ForceApi
+// is bound to ctx.
+func NewContext(ctx context.Context, opts ...Option) (*ForceApi, error) {
+	forceApi, err := newForceApi(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := forceApi.authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := forceApi.negotiateApiVersion(ctx); err != nil {
+		return nil, err
+	}
+
+	// Init Api Resources
+	if err := forceApi.getApiResources(ctx); err != nil {
+		return nil, err
+	}
+	if err := forceApi.getApiSObjects(ctx); err != nil {
+		return nil, err
+	}
+
+	return forceApi, nil
+}
+
+// negotiateApiVersion picks the latest version the org supports, or checks
+// that it supports the pinned one.
+func (forceApi *ForceApi) negotiateApiVersion(ctx context.Context) error {
+	if err := forceApi.getApiVersions(ctx); err != nil {
+		return err
+	}
+	if len(forceApi.apiVersions) == 0 {
+		return errors.New("force: no api versions available")
+	}
+
+	latest := "v" + forceApi.apiVersions[len(forceApi.apiVersions)-1].Version
+	if forceApi.apiVersion == "" {
+		forceApi.apiVersion = latest
+		return nil
+	}
+
+	for _, version := range forceApi.apiVersions {
+		if "v"+version.Version == forceApi.apiVersion {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("force: api version %v is not supported by the org, whose latest version is %v", forceApi.apiVersion, latest)
+}
+
+// newForceApi applies opts to an unauthenticated ForceApi.
+func newForceApi(opts []Option) (*ForceApi, error) {
+	forceApi := &ForceApi{
+		apiResources:           make(map[string]string),
+		apiSObjects:            make(map[string]*SObjectMetaData),
+		apiSObjectDescriptions: make(map[string]*SObjectDescription),
+		OAuth:                  &ForceOauth{},
+	}
+
+	for _, opt := range opts {
+		if err := opt(forceApi); err != nil {
+			return nil, err
+		}
+	}
+
+	forceApi.OAuth.httpClient = forceApi.httpClient
+	forceApi.OAuth.userAgent = forceApi.userAgent
+	forceApi.OAuth.tracer = forceApi.trace
+
+	return forceApi, nil
+}
+
+func (forceApi *ForceApi) authenticate(ctx context.Context) error {
+	oauth := forceApi.OAuth
+
+	if forceApi.authCode == "" && oauth.tokenSource == nil {
+		found, err := oauth.loadStoredToken()
+		if err != nil {
+			return err
+		}
+		if found {
+			return oauth.Validate()
+		}
+	}
+
+	switch {
+	case forceApi.authCode != "":
+		return oauth.AuthenticateCodePKCEContext(ctx, forceApi.authCode, forceApi.authRedirectURI, forceApi.authCodeVerifier)
+	case oauth.tokenSource != nil:
+		return oauth.tokenFromSource()
+	case oauth.AccessToken != "":
+		// We need to check for oauth correctness here, since we are not generating the token ourselves.
+		return oauth.Validate()
+	case oauth.RefreshToken != "":
+		if err := oauth.RefreshAccessTokenContext(ctx); err != nil {
+			return err
+		}
+		return oauth.Validate()
+	case oauth.jwtKey != nil:
+		return oauth.AuthenticateJWTContext(ctx)
+	case oauth.clientCredentials:
+		return oauth.AuthenticateClientCredentialsContext(ctx)
+	case oauth.userName != "":
+		return oauth.AuthenticateContext(ctx)
+	}
+
+	return errors.New("force: no credentials configured")
+}
@@ -0,0 +1,236 @@
+package force
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeOrg is a minimal local stand-in for force.com that supports the
+// requests made while creating a ForceApi.
+type fakeOrg struct {
+	*httptest.Server
+	mux *http.ServeMux
+
+	// OnToken, if set, inspects each token request and may return an error
+	// response instead of a token.
+	OnToken func(r *http.Request) *ApiError
+
+	tokenRequests int32
+}
+
+func newFakeOrg(t *testing.T) *fakeOrg {
+	org := &fakeOrg{mux: http.NewServeMux()}
+	org.Server = httptest.NewServer(org.mux)
+	t.Cleanup(org.Close)
+
+	org.HandleJSON(tokenUri, func(r *http.Request) interface{} {
+		n := atomic.AddInt32(&org.tokenRequests, 1)
+		if org.OnToken != nil {
+			if apiErr := org.OnToken(r); apiErr != nil {
+				return apiErr
+			}
+		}
+		token := map[string]string{
+			"access_token": fmt.Sprintf("token%d", n),
+			"instance_url": org.URL,
+			"id":           org.URL + "/id/00D/005",
+			"token_type":   "Bearer",
+			"issued_at":    fmt.Sprint(time.Now().UnixMilli()),
+		}
+		// Refresh tokens are rotated as connected apps may be set up to do.
+		if r.FormValue("grant_type") == "refresh_token" {
+			token["refresh_token"] = fmt.Sprintf("refresh%d", n)
+		}
+		return token
+	})
+	org.HandleJSON(versionsUri, func(r *http.Request) interface{} {
+		return []*Version{{Version: "35.0"}, {Version: "36.0"}}
+	})
+	org.HandleJSON("/services/data/v36.0/", func(r *http.Request) interface{} {
+		return map[string]string{
+			compositeKey: "/services/data/v36.0/composite",
+			sObjectsKey:  "/services/data/v36.0/sobjects/",
+			queryKey:     "/services/data/v36.0/query/",
+			queryAllKey:  "/services/data/v36.0/queryAll/",
+			limitsKey:    "/services/data/v36.0/limits/",
+			searchKey:    "/services/data/v36.0/search/",
+
+			parameterizedSearchKey: "/services/data/v36.0/parameterizedSearch/",
+		}
+	})
+	org.HandleJSON("/services/data/v36.0/sobjects/", func(r *http.Request) interface{} {
+		return &SObjectApiResponse{
+			MaxBatchSize: 200,
+			SObjects: []*SObjectMetaData{{
+				Name: "Account",
+				URLs: map[string]string{
+					sObjectKey:         "/services/data/v36.0/sobjects/Account",
+					sObjectDescribeKey: "/services/data/v36.0/sobjects/Account/describe",
+					rowTemplateKey:     "/services/data/v36.0/sobjects/Account/{ID}",
+				},
+			}},
+		}
+	})
+
+	return org
+}
+
+// HandleJSON registers a handler that writes the JSON encoding of the value
+// returned by fn.
+func (org *fakeOrg) HandleJSON(pattern string, fn func(r *http.Request) interface{}) {
+	org.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", jsonContentType)
+		json.NewEncoder(w).Encode(fn(r))
+	})
+}
+
+// New returns a ForceApi authenticated against the fake org.
+func (org *fakeOrg) New(t *testing.T, opts ...Option) *ForceApi {
+	opts = append([]Option{
+		WithLoginURL(org.URL),
+		WithConnectedApp("client", "secret"),
+		WithPassword("user", "password", "token"),
+	}, opts...)
+
+	forceApi, err := New(opts...)
+	if err != nil {
+		t.Fatalf("Unable to create ForceApi against fake org: %v", err)
+	}
+
+	return forceApi
+}
+
+type countingTransport struct {
+	requests int32
+	agents   []string
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&c.requests, 1)
+	c.agents = append(c.agents, req.Header.Get("User-Agent"))
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewWithOptions(t *testing.T) {
+	org := newFakeOrg(t)
+	transport := &countingTransport{}
+
+	forceApi := org.New(t, WithRoundTripper(transport), WithUserAgent("my-agent/1.0"))
+
+	if forceApi.apiVersion != "v36.0" {
+		t.Fatalf("Expected negotiated version v36.0, got %v", forceApi.apiVersion)
+	}
+	if forceApi.GetInstanceURL() != org.URL {
+		t.Fatalf("Expected instance url %v, got %v", org.URL, forceApi.GetInstanceURL())
+	}
+	if _, ok := forceApi.apiSObjects["Account"]; !ok {
+		t.Fatal("Expected Account sobject metadata to be loaded")
+	}
+
+	// token, versions, resources and sobjects
+	if transport.requests != 4 {
+		t.Fatalf("Expected 4 requests through the custom transport, got %v", transport.requests)
+	}
+	for _, agent := range transport.agents {
+		if agent != "my-agent/1.0" {
+			t.Fatalf("Expected custom user agent, got %q", agent)
+		}
+	}
+}
+
+func TestNewWithPinnedVersion(t *testing.T) {
+	org := newFakeOrg(t)
+	org.HandleJSON("/services/data/v35.0/", func(r *http.Request) interface{} {
+		return map[string]string{sObjectsKey: "/services/data/v36.0/sobjects/"}
+	})
+
+	forceApi := org.New(t, WithApiVersion("35.0"))
+	if forceApi.ApiVersion() != "v35.0" {
+		t.Fatalf("Expected pinned version v35.0, got %v", forceApi.ApiVersion())
+	}
+	if uri := forceApi.asyncJobUri("750J"); uri != "/services/async/35.0/job/750J" {
+		t.Fatalf("Expected bulk uris to use the pinned version, got %v", uri)
+	}
+}
+
+func TestNewWithUnsupportedVersion(t *testing.T) {
+	org := newFakeOrg(t)
+
+	_, err := New(WithLoginURL(org.URL), WithPassword("user", "password", "token"), WithApiVersion("v99.0"))
+	if err == nil || !strings.Contains(err.Error(), "v99.0 is not supported") {
+		t.Fatalf("Expected an unsupported version error, got %v", err)
+	}
+
+	if _, err := New(WithApiVersion("latest")); err == nil {
+		t.Fatal("Expected an error for an invalid version")
+	}
+}
+
+func TestNewWithAccessToken(t *testing.T) {
+	org := newFakeOrg(t)
+
+	_, err := New(WithAccessToken("token"), WithInstanceURL(org.URL))
+	if err != nil {
+		t.Fatalf("Unable to create ForceApi with access token: %v", err)
+	}
+	if org.tokenRequests != 0 {
+		t.Fatalf("Expected no token requests, got %v", org.tokenRequests)
+	}
+}
+
+func TestNewWithoutCredentials(t *testing.T) {
+	if _, err := New(); err == nil {
+		t.Fatal("Expected an error when no credentials are configured")
+	}
+	if _, err := New(WithLoginURL("login.salesforce.com")); err == nil {
+		t.Fatal("Expected an error for a login url without a scheme")
+	}
+}
+
+// recordingLogger is a ForceApiLogger that keeps every line logged.
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.lines, "")
+}
+
+// Prefixed returns the lines logged that start with prefix.
+func (l *recordingLogger) Prefixed(prefix string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var lines string
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, prefix) {
+			lines += line
+		}
+	}
+	return lines
+}
+
+func TestNewWithLoggerTracesRefresh(t *testing.T) {
+	org := newFakeOrg(t)
+	logger := &recordingLogger{}
+
+	org.New(t, WithRefreshToken("refresh"), WithLogger("[force]", logger))
+	if !strings.Contains(logger.String(), "[force] OAuth: Refreshed access token") {
+		t.Fatalf("Expected the refresh to be traced, got %q", logger.String())
+	}
+}
@@ -1,15 +1,23 @@
 package force
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"reflect"
 	"strings"
+
+	"github.com/nimajalali/go-force/forcejson"
+	"github.com/nimajalali/go-force/sobjects"
 )
 
 const (
 	BaseQueryString = "SELECT %v FROM %v"
 )
 
+// BuildQuery writes fields, table and constraints into a query as they are.
+// Use Select to build queries from values that need escaping.
 func BuildQuery(fields, table string, constraints []string, constraintJoin string) string {
 	query := fmt.Sprintf(BaseQueryString, fields, table)
 	if len(constraints) > 0 {
@@ -41,14 +49,21 @@ func (forceApi *ForceApi) GetFields(table string, allowCustomFields bool) (field
 
 // Use the Query resource to execute a SOQL query that returns all the results in a single response,
 // or if needed, returns part of the results and an identifier used to retrieve the remaining results.
+// If out holds sobjects.ChildRecords fields, the response is decoded by the force tags of out so that child
+// relationship subqueries fill them; otherwise it is decoded by its json tags.
 func (forceApi *ForceApi) Query(query string, out interface{}) (err error) {
-	uri := forceApi.apiResources[queryKey]
+	return forceApi.QueryContext(context.Background(), query, out)
+}
+
+// QueryContext is like Query but the request is bound to ctx.
+func (forceApi *ForceApi) QueryContext(ctx context.Context, query string, out interface{}) (err error) {
+	uri := forceApi.resource(queryKey)
 
 	params := url.Values{
 		"q": {query},
 	}
 
-	err = forceApi.Get(uri, params, out)
+	err = forceApi.getRecords(ctx, uri, params, out)
 
 	return
 }
@@ -57,19 +72,77 @@ func (forceApi *ForceApi) Query(query string, out interface{}) (err error) {
 // been deleted because of a merge or delete. Use QueryAll rather than Query, because the Query resource
 // will automatically filter out items that have been deleted.
 func (forceApi *ForceApi) QueryAll(query string, out interface{}) (err error) {
-	uri := forceApi.apiResources[queryAllKey]
+	return forceApi.QueryAllContext(context.Background(), query, out)
+}
+
+// QueryAllContext is like QueryAll but the request is bound to ctx.
+func (forceApi *ForceApi) QueryAllContext(ctx context.Context, query string, out interface{}) (err error) {
+	uri := forceApi.resource(queryAllKey)
 
 	params := url.Values{
 		"q": {query},
 	}
 
-	err = forceApi.Get(uri, params, out)
+	err = forceApi.getRecords(ctx, uri, params, out)
 
 	return
 }
 
 func (forceApi *ForceApi) QueryNext(uri string, out interface{}) (err error) {
-	err = forceApi.Get(uri, nil, out)
+	return forceApi.QueryNextContext(context.Background(), uri, out)
+}
+
+// QueryNextContext is like QueryNext but the request is bound to ctx.
+func (forceApi *ForceApi) QueryNextContext(ctx context.Context, uri string, out interface{}) (err error) {
+	err = forceApi.getRecords(ctx, uri, nil, out)
 
 	return
 }
+
+// getRecords is like GetContext but unmarshals the response with forcejson
+// when out holds sobjects.ChildRecords, whose fields are tagged json:"-".
+func (forceApi *ForceApi) getRecords(ctx context.Context, uri string, params url.Values, out interface{}) error {
+	if out == nil || !holdsChildRecords(reflect.TypeOf(out), map[reflect.Type]bool{}) {
+		return forceApi.GetContext(ctx, uri, params, out)
+	}
+
+	var data json.RawMessage
+	if err := forceApi.GetContext(ctx, uri, params, &data); err != nil {
+		return err
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+
+	if err := forcejson.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("Unable to unmarshal response to object: %v", err)
+	}
+
+	return nil
+}
+
+// childRecordsPkg is the package of sobjects.ChildRecords.
+var childRecordsPkg = reflect.TypeOf(sobjects.ChildRecords[struct{}]{}).PkgPath()
+
+// holdsChildRecords reports whether t or the types it is made of is a
+// sobjects.ChildRecords.
+func holdsChildRecords(t reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	if t.PkgPath() == childRecordsPkg && strings.HasPrefix(t.Name(), "ChildRecords[") {
+		return true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if holdsChildRecords(t.Field(i).Type, seen) {
+			return true
+		}
+	}
+
+	return false
+}
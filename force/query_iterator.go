@@ -0,0 +1,214 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+
+	"github.com/nimajalali/go-force/forcejson"
+)
+
+const queryOptionsHeader = "Sforce-Query-Options"
+
+// QueryOption configures a QueryIterator.
+type QueryOption func(*QueryIterator) error
+
+// QueryBatchSize asks force.com to return pages of n records, between 200 and
+// 2000. force.com treats it as a hint and may return larger or smaller pages.
+func QueryBatchSize(n int) QueryOption {
+	return func(it *QueryIterator) error {
+		if n < 200 || n > 2000 {
+			return fmt.Errorf("force: query batch size %v is not between 200 and 2000", n)
+		}
+
+		it.header = http.Header{queryOptionsHeader: {fmt.Sprintf("batchSize=%d", n)}}
+		return nil
+	}
+}
+
+// QueryIncludeDeleted uses the QueryAll resource, so the results include
+// records that have been deleted because of a merge or delete.
+func QueryIncludeDeleted() QueryOption {
+	return func(it *QueryIterator) error {
+		it.resourceKey = queryAllKey
+		return nil
+	}
+}
+
+// QueryIterator walks the records matched by a SOQL query one at a time,
+// fetching the next page of results only once the current one is used up.
+//
+//	it := forceApi.QueryIterator("SELECT Id, Name FROM Account")
+//	for it.Next() {
+//		account := &sobjects.Account{}
+//		if err := it.Decode(account); err != nil {
+//			return err
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+//
+// A QueryIterator is not safe for concurrent use.
+type QueryIterator struct {
+	forceApi    *ForceApi
+	ctx         context.Context
+	query       string
+	resourceKey string
+	header      http.Header
+
+	started        bool
+	nextRecordsUri string
+	totalSize      int
+	records        []json.RawMessage
+	index          int
+	err            error
+}
+
+type queryPage struct {
+	Done           bool              `json:"done"`
+	TotalSize      int               `json:"totalSize"`
+	NextRecordsUrl string            `json:"nextRecordsUrl"`
+	Records        []json.RawMessage `json:"records"`
+}
+
+// QueryIterator returns an iterator over the records matched by query.
+func (forceApi *ForceApi) QueryIterator(query string, opts ...QueryOption) *QueryIterator {
+	return forceApi.QueryIteratorContext(context.Background(), query, opts...)
+}
+
+// QueryIteratorContext is like QueryIterator but every page is requested with
+// ctx.
+func (forceApi *ForceApi) QueryIteratorContext(ctx context.Context, query string, opts ...QueryOption) *QueryIterator {
+	it := &QueryIterator{
+		forceApi:    forceApi,
+		ctx:         ctx,
+		query:       query,
+		resourceKey: queryKey,
+		index:       -1,
+	}
+	for _, opt := range opts {
+		if err := opt(it); err != nil {
+			it.err = err
+			break
+		}
+	}
+
+	return it
+}
+
+// Next advances to the next record, fetching the next page of results if
+// needed. It returns false when there are no more records or an error
+// occurred; Err tells the two apart.
+func (it *QueryIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.index++
+	for it.index >= len(it.records) {
+		if it.started && it.nextRecordsUri == "" {
+			it.records = nil
+			return false
+		}
+		if !it.fetch() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fetch replaces the current page with the next one.
+func (it *QueryIterator) fetch() bool {
+	r := &apiRequest{
+		method:      "GET",
+		path:        it.nextRecordsUri,
+		header:      it.header,
+		contentType: jsonContentType,
+	}
+	if !it.started {
+		r.path = it.forceApi.resource(it.resourceKey)
+		r.params = url.Values{"q": {it.query}}
+	}
+
+	page := &queryPage{}
+	if err := it.forceApi.do(it.ctx, r, page); err != nil {
+		it.err = err
+		return false
+	}
+
+	if !it.started {
+		it.totalSize = page.TotalSize
+		it.started = true
+	}
+	it.nextRecordsUri = ""
+	if !page.Done {
+		if page.NextRecordsUrl == "" {
+			it.err = errors.New("force: query page is not done but has no nextRecordsUrl")
+			return false
+		}
+		it.nextRecordsUri = page.NextRecordsUrl
+	}
+	it.records = page.Records
+	it.index = 0
+
+	return true
+}
+
+// Decode unmarshals the current record into out, usually a pointer to an
+// SObject struct.
+func (it *QueryIterator) Decode(out interface{}) error {
+	if it.index < 0 || it.index >= len(it.records) {
+		return errors.New("force: Decode called without a current record")
+	}
+
+	if err := forcejson.Unmarshal(it.records[it.index], out); err != nil {
+		return fmt.Errorf("Unable to unmarshal record: %v", err)
+	}
+
+	return nil
+}
+
+// TotalSize returns the total number of records matched by the query, as
+// reported with the first page. It is 0 until Next has been called.
+func (it *QueryIterator) TotalSize() int {
+	return it.totalSize
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *QueryIterator) Err() error {
+	return it.err
+}
+
+// Records returns an iter.Seq2 over the remaining records of it decoded into
+// T. An error, either fetching a page or decoding a record, is yielded once
+// with the zero T and ends the sequence.
+//
+//	for account, err := range force.Records[sobjects.Account](it) {
+//		...
+//	}
+func Records[T any](it *QueryIterator) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for it.Next() {
+			var record T
+			if err := it.Decode(&record); err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if !yield(record, nil) {
+				return
+			}
+		}
+
+		if err := it.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
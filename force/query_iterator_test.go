@@ -0,0 +1,127 @@
+package force
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+// HandleQuery serves the given pages of records from the query resource at
+// path, linking them with nextRecordsUrl, and returns the requests it got.
+func (org *fakeOrg) HandleQuery(path string, pages ...[]interface{}) *[]*http.Request {
+	total := 0
+	for _, page := range pages {
+		total += len(page)
+	}
+
+	var requests []*http.Request
+	org.HandleJSON(path, func(r *http.Request) interface{} {
+		requests = append(requests, r)
+
+		n := 0
+		if locator := strings.TrimPrefix(r.URL.Path, path); locator != "" {
+			fmt.Sscanf(locator, "01gD0000002HU6K-%d", &n)
+		}
+
+		page := map[string]interface{}{
+			"totalSize": total,
+			"done":      n == len(pages)-1,
+			"records":   pages[n],
+		}
+		if n < len(pages)-1 {
+			page["nextRecordsUrl"] = fmt.Sprintf("%v01gD0000002HU6K-%d", path, n+1)
+		}
+		return page
+	})
+
+	return &requests
+}
+
+func TestQueryIterator(t *testing.T) {
+	org := newFakeOrg(t)
+	requests := org.HandleQuery("/services/data/v36.0/query/",
+		[]interface{}{
+			map[string]interface{}{"attributes": map[string]string{"type": "Account"}, "Id": "001A", "Name": "Alpha"},
+			map[string]interface{}{"attributes": map[string]string{"type": "Account"}, "Id": "001B", "Name": "Beta"},
+		},
+		[]interface{}{
+			map[string]interface{}{"attributes": map[string]string{"type": "Account"}, "Id": "001C", "Name": "Gamma"},
+		},
+	)
+	forceApi := org.New(t)
+
+	it := forceApi.QueryIterator("SELECT Id, Name FROM Account", QueryBatchSize(200))
+	var names []string
+	for it.Next() {
+		account := &sobjects.Account{}
+		if err := it.Decode(account); err != nil {
+			t.Fatalf("Unable to decode record: %v", err)
+		}
+		names = append(names, account.Name)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Iteration failed: %v", err)
+	}
+
+	if strings.Join(names, ",") != "Alpha,Beta,Gamma" {
+		t.Fatalf("Expected all three accounts, got %v", names)
+	}
+	if it.TotalSize() != 3 {
+		t.Fatalf("Expected total size 3, got %v", it.TotalSize())
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("Expected 2 page requests, got %v", len(*requests))
+	}
+	for _, r := range *requests {
+		if r.Header.Get(queryOptionsHeader) != "batchSize=200" {
+			t.Fatalf("Expected the batch size header, got %q", r.Header.Get(queryOptionsHeader))
+		}
+	}
+}
+
+func TestQueryIteratorRecords(t *testing.T) {
+	org := newFakeOrg(t)
+	org.HandleQuery("/services/data/v36.0/queryAll/",
+		[]interface{}{map[string]interface{}{"Id": "001A", "IsDeleted": true}},
+		[]interface{}{},
+	)
+	forceApi := org.New(t)
+
+	var accounts []sobjects.Account
+	it := forceApi.QueryIterator("SELECT Id, IsDeleted FROM Account", QueryIncludeDeleted())
+	for account, err := range Records[sobjects.Account](it) {
+		if err != nil {
+			t.Fatalf("Iteration failed: %v", err)
+		}
+		accounts = append(accounts, account)
+	}
+
+	if len(accounts) != 1 || !accounts[0].IsDeleted {
+		t.Fatalf("Expected the deleted account, got %+v", accounts)
+	}
+}
+
+func TestQueryIteratorError(t *testing.T) {
+	org := newFakeOrg(t)
+	org.HandleJSON("/services/data/v36.0/query/", func(r *http.Request) interface{} {
+		return ApiErrors{{ErrorCode: "MALFORMED_QUERY", Message: "unexpected token"}}
+	})
+	forceApi := org.New(t)
+
+	it := forceApi.QueryIterator("SELECT FROM Account")
+	if it.Next() {
+		t.Fatal("Expected no records")
+	}
+	var apiErrors ApiErrors
+	if !errors.As(it.Err(), &apiErrors) || apiErrors[0].ErrorCode != "MALFORMED_QUERY" {
+		t.Fatalf("Expected the api error, got: %v", it.Err())
+	}
+
+	if err := forceApi.QueryIterator("SELECT Id FROM Account", QueryBatchSize(10)).Err(); err == nil {
+		t.Fatal("Expected an invalid batch size to be rejected")
+	}
+}
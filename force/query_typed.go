@@ -0,0 +1,48 @@
+package force
+
+import (
+	"context"
+	"iter"
+)
+
+// QueryAll runs soql and returns every matched record decoded into T,
+// following nextRecordsUrl until the last page. It replaces declaring a
+// BaseQuery and Records wrapper struct for each type:
+//
+//	accounts, err := force.QueryAll[sobjects.Account](ctx, forceApi, "SELECT Id, Name FROM Account")
+//
+// T may also be a pointer type such as *sobjects.User. Pass
+// QueryIncludeDeleted to include deleted and merged records like the QueryAll
+// method does.
+func QueryAll[T SObject](ctx context.Context, api ForceApiInterface, soql string, opts ...QueryOption) ([]T, error) {
+	it := api.QueryIteratorContext(ctx, soql, opts...)
+
+	var records []T
+	for record, err := range Records[T](it) {
+		if err != nil {
+			return nil, err
+		}
+		if records == nil {
+			records = make([]T, 0, it.TotalSize())
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// QueryStream is like QueryAll but yields the records one at a time, only
+// fetching a page once the previous one has been consumed, so that large
+// results need not fit in memory. Stopping the loop early stops fetching.
+//
+//	for account, err := range force.QueryStream[sobjects.Account](ctx, forceApi, soql) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func QueryStream[T SObject](ctx context.Context, api ForceApiInterface, soql string, opts ...QueryOption) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		Records[T](api.QueryIteratorContext(ctx, soql, opts...))(yield)
+	}
+}
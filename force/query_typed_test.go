@@ -0,0 +1,53 @@
+package force
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+func TestQueryAllTyped(t *testing.T) {
+	org := newFakeOrg(t)
+	org.HandleQuery("/services/data/v36.0/query/",
+		[]interface{}{
+			map[string]interface{}{"Id": "005A", "Username": "alpha@example.com"},
+			map[string]interface{}{"Id": "005B", "Username": "beta@example.com"},
+		},
+		[]interface{}{
+			map[string]interface{}{"Id": "005C", "Username": "gamma@example.com"},
+		},
+	)
+	forceApi := org.New(t)
+
+	users, err := QueryAll[*sobjects.User](context.Background(), forceApi, "SELECT Id, Username FROM User")
+	if err != nil {
+		t.Fatalf("QueryAll failed: %v", err)
+	}
+	if len(users) != 3 || users[2].Username != "gamma@example.com" {
+		t.Fatalf("Expected three users across both pages, got %+v", users)
+	}
+}
+
+func TestQueryStreamStopsEarly(t *testing.T) {
+	org := newFakeOrg(t)
+	requests := org.HandleQuery("/services/data/v36.0/queryAll/",
+		[]interface{}{map[string]interface{}{"Id": "001A", "IsDeleted": true}},
+		[]interface{}{map[string]interface{}{"Id": "001B"}},
+	)
+	forceApi := org.New(t)
+
+	for account, err := range QueryStream[sobjects.Account](context.Background(), forceApi, "SELECT Id FROM Account", QueryIncludeDeleted()) {
+		if err != nil {
+			t.Fatalf("QueryStream failed: %v", err)
+		}
+		if account.Id != "001A" || !account.IsDeleted {
+			t.Fatalf("Expected the deleted account first, got %+v", account)
+		}
+		break
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("Expected the second page not to be fetched, got %v requests", len(*requests))
+	}
+}
@@ -0,0 +1,142 @@
+package force
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"reflect"
+	"strings"
+
+	"github.com/nimajalali/go-force/forcejson"
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+// SelectStruct is like the SelectStruct function, but first checks the parent
+// and child relationships declared by v against the description of its
+// sobject, so that a misspelt or inaccessible relationship is reported here
+// rather than as a MALFORMED_QUERY error from force.com.
+func (forceApi *ForceApi) SelectStruct(v SObject) (*QueryBuilder, error) {
+	return forceApi.SelectStructContext(context.Background(), v)
+}
+
+// SelectStructContext is like SelectStruct but the describe request is bound
+// to ctx.
+func (forceApi *ForceApi) SelectStructContext(ctx context.Context, v SObject) (*QueryBuilder, error) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errNotStructForSelect
+	}
+
+	w := newStructFieldsWalker(0)
+	if err := w.walk(t, "", 0); err != nil {
+		return nil, err
+	}
+
+	desc, err := forceApi.DescribeSObjectContext(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range w.parentRelationships {
+		if !hasParentRelationship(desc, name) {
+			return nil, fmt.Errorf("force: %v has no parent relationship %v", desc.Name, name)
+		}
+	}
+	for _, name := range w.childRelationships {
+		if !hasChildRelationship(desc, name) {
+			return nil, fmt.Errorf("force: %v has no child relationship %v", desc.Name, name)
+		}
+	}
+
+	b := (&QueryBuilder{fields: w.fields}).From(v.ApiName())
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	return b, nil
+}
+
+func hasParentRelationship(desc *SObjectDescription, name string) bool {
+	for _, field := range desc.Fields {
+		if field.RelationshipName != "" && strings.EqualFold(field.RelationshipName, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasChildRelationship(desc *SObjectDescription, name string) bool {
+	for _, relationship := range desc.ChildRelationsips {
+		if relationship.RelationshipName != "" && strings.EqualFold(relationship.RelationshipName, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadChildren fetches the records of children that did not fit in the
+// first page returned with the parent record and appends them, leaving
+// children done.
+func LoadChildren[T any](ctx context.Context, api ForceApiInterface, children *sobjects.ChildRecords[T]) error {
+	for !children.Done && children.NextRecordsUri != "" {
+		page, err := nextChildPage[T](ctx, api, children.NextRecordsUri)
+		if err != nil {
+			return err
+		}
+
+		children.Records = append(children.Records, page.Records...)
+		children.Done = page.Done
+		children.NextRecordsUri = page.NextRecordsUri
+	}
+
+	return nil
+}
+
+// Children yields the records of children, fetching the pages after the
+// first one only as the loop reaches them. children itself is not changed.
+func Children[T any](ctx context.Context, api ForceApiInterface, children *sobjects.ChildRecords[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		page := children
+		for {
+			for _, record := range page.Records {
+				if !yield(record, nil) {
+					return
+				}
+			}
+			if page.Done || page.NextRecordsUri == "" {
+				return
+			}
+
+			var err error
+			if page, err = nextChildPage[T](ctx, api, page.NextRecordsUri); err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+		}
+	}
+}
+
+func nextChildPage[T any](ctx context.Context, api ForceApiInterface, uri string) (*sobjects.ChildRecords[T], error) {
+	page := &queryPage{}
+	if err := api.QueryNextContext(ctx, uri, page); err != nil {
+		return nil, err
+	}
+
+	children := &sobjects.ChildRecords[T]{Records: make([]T, len(page.Records))}
+	children.Done = page.Done
+	children.TotalSize = float64(page.TotalSize)
+	children.NextRecordsUri = page.NextRecordsUrl
+	for i, record := range page.Records {
+		if err := forcejson.Unmarshal(record, &children.Records[i]); err != nil {
+			return nil, fmt.Errorf("Unable to unmarshal record: %v", err)
+		}
+	}
+
+	return children, nil
+}
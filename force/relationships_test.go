@@ -0,0 +1,159 @@
+package force
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+type testAccountWithOpportunities struct {
+	sobjects.Account
+	Owner         *sobjects.User                               `force:"Owner,omitempty" json:"-"`
+	Opportunities sobjects.ChildRecords[*sobjects.Opportunity] `force:"Opportunities" json:"-"`
+}
+
+type testAccountWithTypo struct {
+	sobjects.Account
+	Oportunities sobjects.ChildRecords[sobjects.Opportunity] `json:"-"`
+}
+
+func newRelationshipsOrg(t *testing.T) *fakeOrg {
+	org := newFakeOrg(t)
+	org.HandleQuery("/services/data/v36.0/query/", []interface{}{
+		map[string]interface{}{
+			"Id":    "001A",
+			"Owner": map[string]interface{}{"Name": "Ann"},
+			"Opportunities": map[string]interface{}{
+				"totalSize":      3,
+				"done":           false,
+				"nextRecordsUrl": "/services/data/v36.0/query/01gChild-2000",
+				"records": []interface{}{
+					map[string]interface{}{"Id": "006A", "Name": "First"},
+					map[string]interface{}{"Id": "006B", "Name": "Second"},
+				},
+			},
+		},
+		map[string]interface{}{"Id": "001B", "Owner": nil, "Opportunities": nil},
+	})
+	org.HandleJSON("/services/data/v36.0/query/01gChild-2000", func(r *http.Request) interface{} {
+		return map[string]interface{}{
+			"totalSize": 3,
+			"done":      true,
+			"records":   []interface{}{map[string]interface{}{"Id": "006C", "Name": "Third"}},
+		}
+	})
+	org.HandleJSON("/services/data/v36.0/sobjects/Account/describe", func(r *http.Request) interface{} {
+		return &SObjectDescription{
+			Name:              "Account",
+			Fields:            []*SObjectField{{Name: "OwnerId", RelationshipName: "Owner"}},
+			ChildRelationsips: []*ChildRelationship{{ChildSObject: "Opportunity", RelationshipName: "Opportunities"}},
+		}
+	})
+
+	return org
+}
+
+func TestRelationshipDecoding(t *testing.T) {
+	org := newRelationshipsOrg(t)
+	forceApi := org.New(t)
+	ctx := context.Background()
+
+	query, err := forceApi.SelectStruct(&testAccountWithOpportunities{})
+	if err != nil {
+		t.Fatalf("SelectStruct failed: %v", err)
+	}
+	soql, err := query.Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if !strings.Contains(soql, ", Owner.Id, ") || !strings.Contains(soql, ", (SELECT Id, IsDeleted, Name, ") ||
+		!strings.HasSuffix(soql, " FROM Opportunities) FROM Account") {
+		t.Fatalf("Unexpected query %v", soql)
+	}
+
+	accounts, err := QueryAll[*testAccountWithOpportunities](ctx, forceApi, soql)
+	if err != nil {
+		t.Fatalf("QueryAll failed: %v", err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("Expected 2 accounts, got %v", len(accounts))
+	}
+
+	first := accounts[0]
+	if first.Owner == nil || first.Owner.Name != "Ann" {
+		t.Fatalf("Expected the owner to be decoded, got %+v", first.Owner)
+	}
+	if accounts[1].Owner != nil || len(accounts[1].Opportunities.Records) != 0 {
+		t.Fatalf("Expected no owner or opportunities, got %+v", accounts[1])
+	}
+
+	var names []string
+	for opportunity, err := range Children(ctx, forceApi, &first.Opportunities) {
+		if err != nil {
+			t.Fatalf("Children failed: %v", err)
+		}
+		names = append(names, opportunity.Name)
+	}
+	if strings.Join(names, ",") != "First,Second,Third" || len(first.Opportunities.Records) != 2 {
+		t.Fatalf("Expected Children to page lazily without changing the collection, got %v", names)
+	}
+
+	if err := LoadChildren(ctx, forceApi, &first.Opportunities); err != nil {
+		t.Fatalf("LoadChildren failed: %v", err)
+	}
+	if len(first.Opportunities.Records) != 3 || !first.Opportunities.Done || first.Opportunities.Records[2].Id != "006C" {
+		t.Fatalf("Expected all three opportunities, got %+v", first.Opportunities)
+	}
+}
+
+func TestSelectStructUnknownRelationship(t *testing.T) {
+	org := newRelationshipsOrg(t)
+	forceApi := org.New(t)
+
+	_, err := forceApi.SelectStruct(&testAccountWithTypo{})
+	if err == nil || !strings.Contains(err.Error(), "no child relationship Oportunities") {
+		t.Fatalf("Expected an unknown child relationship error, got: %v", err)
+	}
+}
+
+func TestQueryDecodesRelationships(t *testing.T) {
+	org := newRelationshipsOrg(t)
+	forceApi := org.New(t)
+
+	out := &struct {
+		sobjects.BaseQuery
+		Records []*testAccountWithOpportunities `force:"records"`
+	}{}
+	if err := forceApi.Query("SELECT Id, Owner.Name, (SELECT Id, Name FROM Opportunities) FROM Account", out); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(out.Records) != 2 || out.Records[0].Owner == nil || out.Records[0].Owner.Name != "Ann" {
+		t.Fatalf("Expected the accounts with their owner, got %+v", out.Records)
+	}
+	if opportunities := out.Records[0].Opportunities; len(opportunities.Records) != 2 || opportunities.Records[1].Name != "Second" || opportunities.NextRecordsUri == "" {
+		t.Fatalf("Expected the first page of opportunities, got %+v", opportunities)
+	}
+}
+
+func TestQueryDecodesJSONTags(t *testing.T) {
+	org := newRelationshipsOrg(t)
+	forceApi := org.New(t)
+
+	out := &struct {
+		Records []struct {
+			AccountId string `json:"Id"`
+			Owner     *struct {
+				OwnerName string `json:"Name"`
+			} `json:"Owner"`
+		} `json:"records"`
+	}{}
+	if err := forceApi.Query("SELECT Id, Owner.Name FROM Account", out); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(out.Records) != 2 || out.Records[0].AccountId != "001A" || out.Records[0].Owner == nil || out.Records[0].Owner.OwnerName != "Ann" {
+		t.Fatalf("Expected the records decoded by their json tags, got %+v", out.Records)
+	}
+}
@@ -0,0 +1,360 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/nimajalali/go-force/forcejson"
+)
+
+const (
+	// maxReplicationSpan is how far back force.com reports changes.
+	maxReplicationSpan = 30 * 24 * time.Hour
+
+	// exceededIdLimitCode is the error of an updated span with more than
+	// 600,000 ids.
+	exceededIdLimitCode = "EXCEEDED_ID_LIMIT"
+)
+
+// Layouts of the dates of the replication resources.
+var replicationDateLayouts = []string{
+	"2006-01-02T15:04:05.000-0700",
+	"2006-01-02T15:04:05-0700",
+	time.RFC3339Nano,
+}
+
+// UpdatedRecords lists the records of an sobject created or updated in a
+// span of time. LatestDateCovered is the end of the span the list covers,
+// which may be before the end asked for when recent changes are not yet
+// available.
+type UpdatedRecords struct {
+	Ids               []string
+	LatestDateCovered time.Time
+}
+
+// DeletedRecords lists the records of an sobject deleted in a span of time.
+// EarliestDateAvailable is the date of the oldest deletion still kept.
+type DeletedRecords struct {
+	DeletedRecords        []DeletedRecord
+	EarliestDateAvailable time.Time
+	LatestDateCovered     time.Time
+}
+
+// DeletedRecord is a deleted record and the time it was deleted.
+type DeletedRecord struct {
+	Id          string
+	DeletedDate time.Time
+}
+
+// GetUpdated returns the ids of the records of the sobject table created or
+// updated between start and end, which must be within the last 30 days.
+// Seconds are ignored.
+func (forceApi *ForceApi) GetUpdated(table string, start, end time.Time) (*UpdatedRecords, error) {
+	return forceApi.GetUpdatedContext(context.Background(), table, start, end)
+}
+
+// GetUpdatedContext is like GetUpdated but the request is bound to ctx.
+func (forceApi *ForceApi) GetUpdatedContext(ctx context.Context, table string, start, end time.Time) (*UpdatedRecords, error) {
+	resp := &struct {
+		Ids               []string `json:"ids"`
+		LatestDateCovered string   `json:"latestDateCovered"`
+	}{}
+	if err := forceApi.getReplication(ctx, table, "updated", start, end, resp); err != nil {
+		return nil, err
+	}
+
+	updated := &UpdatedRecords{Ids: resp.Ids}
+	var err error
+	if updated.LatestDateCovered, err = parseReplicationDate(resp.LatestDateCovered); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// GetDeleted returns the records of the sobject table deleted between start
+// and end, which must be within the last 30 days. Seconds are ignored.
+func (forceApi *ForceApi) GetDeleted(table string, start, end time.Time) (*DeletedRecords, error) {
+	return forceApi.GetDeletedContext(context.Background(), table, start, end)
+}
+
+// GetDeletedContext is like GetDeleted but the request is bound to ctx.
+func (forceApi *ForceApi) GetDeletedContext(ctx context.Context, table string, start, end time.Time) (*DeletedRecords, error) {
+	resp := &struct {
+		DeletedRecords []struct {
+			Id          string `json:"id"`
+			DeletedDate string `json:"deletedDate"`
+		} `json:"deletedRecords"`
+		EarliestDateAvailable string `json:"earliestDateAvailable"`
+		LatestDateCovered     string `json:"latestDateCovered"`
+	}{}
+	if err := forceApi.getReplication(ctx, table, "deleted", start, end, resp); err != nil {
+		return nil, err
+	}
+
+	deleted := &DeletedRecords{DeletedRecords: make([]DeletedRecord, len(resp.DeletedRecords))}
+	var err error
+	for i, record := range resp.DeletedRecords {
+		deleted.DeletedRecords[i].Id = record.Id
+		if deleted.DeletedRecords[i].DeletedDate, err = parseReplicationDate(record.DeletedDate); err != nil {
+			return nil, err
+		}
+	}
+	if deleted.EarliestDateAvailable, err = parseReplicationDate(resp.EarliestDateAvailable); err != nil {
+		return nil, err
+	}
+	if deleted.LatestDateCovered, err = parseReplicationDate(resp.LatestDateCovered); err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+func (forceApi *ForceApi) getReplication(ctx context.Context, table, kind string, start, end time.Time, out interface{}) error {
+	if !end.After(start) {
+		return fmt.Errorf("force: %v records span ends at %v, before it starts", kind, end)
+	}
+
+	uri, err := forceApi.sObjectUrl(table, sObjectKey)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{
+		"start": {start.UTC().Format(soqlDateTimeFormat)},
+		"end":   {end.UTC().Format(soqlDateTimeFormat)},
+	}
+	return forceApi.GetContext(ctx, uri+"/"+kind+"/", params, out)
+}
+
+func parseReplicationDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range replicationDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("force: %q is not a date", s)
+}
+
+// CheckpointStore persists the checkpoints of a Replicator: for every
+// sobject, the time up to which its changes have been replicated. Load
+// returns the zero time and no error when nothing has been saved for table.
+type CheckpointStore interface {
+	Load(table string) (time.Time, error)
+	Save(table string, checkpoint time.Time) error
+}
+
+// MemoryCheckpointStore keeps checkpoints in memory. It is safe for
+// concurrent use.
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]time.Time
+}
+
+// NewMemoryCheckpointStore returns an empty MemoryCheckpointStore.
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: make(map[string]time.Time)}
+}
+
+func (s *MemoryCheckpointStore) Load(table string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.checkpoints[table], nil
+}
+
+func (s *MemoryCheckpointStore) Save(table string, checkpoint time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkpoints[table] = checkpoint
+	return nil
+}
+
+// ReplicationHandler receives the changes a Replicator finds. An error
+// stops the sync before its checkpoint moves, so the same changes are found
+// again by the next one; handlers must cope with seeing a change twice.
+type ReplicationHandler interface {
+	// Updated is called with every batch of records created or updated.
+	Updated(batch *ReplicationBatch) error
+	// Deleted is called with the records deleted.
+	Deleted(table string, records []DeletedRecord) error
+}
+
+// ReplicationBatch is a batch of records of an sobject created or updated
+// since the last sync.
+type ReplicationBatch struct {
+	Table   string
+	Ids     []string
+	records []json.RawMessage
+}
+
+// Decode unmarshals the records of the batch into out, a pointer to a slice
+// such as *[]*sobjects.Account, in the order of Ids. Records deleted since
+// they were reported as updated are left nil or zero.
+func (batch *ReplicationBatch) Decode(out interface{}) error {
+	data, err := json.Marshal(batch.records)
+	if err != nil {
+		return err
+	}
+	if err := forcejson.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("Unable to unmarshal records: %v", err)
+	}
+
+	return nil
+}
+
+// ReplicatorOption configures a Replicator.
+type ReplicatorOption func(*Replicator) error
+
+// ReplicatorBatchSize sets how many updated records are retrieved and passed
+// to a handler at a time, at most and by default 2000.
+func ReplicatorBatchSize(n int) ReplicatorOption {
+	return func(r *Replicator) error {
+		if n <= 0 || n > maxCollectionRetrieveSize {
+			return fmt.Errorf("force: replicator batch size %v is not between 1 and %v", n, maxCollectionRetrieveSize)
+		}
+		r.batchSize = n
+		return nil
+	}
+}
+
+// Replicator replicates the changes of sobjects incrementally, keeping a
+// checkpoint per sobject in a CheckpointStore:
+//
+//	r, err := forceApi.NewReplicator(store)
+//	...
+//	for _, table := range []string{"Account", "Contact"} {
+//		if err := r.Sync(table, fields[table], warehouse); err != nil {
+//			return err
+//		}
+//	}
+//
+// A Replicator is safe for concurrent use if its store is, as long as an
+// sobject is not synced twice at once.
+type Replicator struct {
+	forceApi  *ForceApi
+	store     CheckpointStore
+	batchSize int
+}
+
+// NewReplicator returns a Replicator keeping its checkpoints in store.
+func (forceApi *ForceApi) NewReplicator(store CheckpointStore, opts ...ReplicatorOption) (*Replicator, error) {
+	if store == nil {
+		return nil, errors.New("force: nil checkpoint store")
+	}
+
+	r := &Replicator{
+		forceApi:  forceApi,
+		store:     store,
+		batchSize: maxCollectionRetrieveSize,
+	}
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Sync passes the changes of the sobject table since its checkpoint to
+// handler: the records created or updated, retrieved with fields in batches,
+// then those deleted. The checkpoint moves to the end of the changes handled
+// once handler has taken them.
+//
+// An sobject without a checkpoint is synced from the oldest changes the org
+// keeps, 30 days back. A checkpoint older than that fails, as changes have
+// been lost and the sobject must be loaded again. Spans with too many updated
+// records for a single call are synced in parts.
+func (r *Replicator) Sync(table string, fields []string, handler ReplicationHandler) error {
+	return r.SyncContext(context.Background(), table, fields, handler)
+}
+
+// SyncContext is like Sync but every request is bound to ctx.
+func (r *Replicator) SyncContext(ctx context.Context, table string, fields []string, handler ReplicationHandler) error {
+	start, err := r.store.Load(table)
+	if err != nil {
+		return fmt.Errorf("Unable to load checkpoint of %v: %v", table, err)
+	}
+
+	now := time.Now().UTC()
+	oldest := now.Add(-maxReplicationSpan)
+	if start.IsZero() {
+		// Leave a margin for the clocks of force.com and this host to differ.
+		start = oldest.Add(time.Hour)
+	} else if start.Before(oldest) {
+		return fmt.Errorf("force: checkpoint of %v at %v is older than the changes force.com keeps", table, start)
+	}
+
+	end := now
+	for {
+		updated, err := r.forceApi.GetUpdatedContext(ctx, table, start, end)
+		if isExceededIdLimit(err) && end.Sub(start) > 2*time.Minute {
+			end = start.Add(end.Sub(start) / 2)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		deleted, err := r.forceApi.GetDeletedContext(ctx, table, start, end)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < len(updated.Ids); i += r.batchSize {
+			batch := &ReplicationBatch{Table: table, Ids: updated.Ids[i:min(i+r.batchSize, len(updated.Ids))]}
+			if err := r.forceApi.RetrieveSObjectsContext(ctx, table, batch.Ids, fields, &batch.records); err != nil {
+				return err
+			}
+			if err := handler.Updated(batch); err != nil {
+				return err
+			}
+		}
+		if len(deleted.DeletedRecords) > 0 {
+			if err := handler.Deleted(table, deleted.DeletedRecords); err != nil {
+				return err
+			}
+		}
+
+		// Both lists are complete up to the earlier of their ends.
+		checkpoint := updated.LatestDateCovered
+		if deleted.LatestDateCovered.Before(checkpoint) {
+			checkpoint = deleted.LatestDateCovered
+		}
+		if !checkpoint.After(start) {
+			return nil
+		}
+		if err := r.store.Save(table, checkpoint); err != nil {
+			return fmt.Errorf("Unable to save checkpoint of %v: %v", table, err)
+		}
+
+		if !end.Before(now) {
+			return nil
+		}
+		start, end = checkpoint, now
+	}
+}
+
+func isExceededIdLimit(err error) bool {
+	apiErrors, ok := err.(ApiErrors)
+	if !ok {
+		return false
+	}
+	for _, apiError := range apiErrors {
+		if apiError != nil && apiError.ErrorCode == exceededIdLimitCode {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,228 @@
+package force
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testReplicationDateLayout = "2006-01-02T15:04:05.000-0700"
+
+func TestGetUpdatedAndDeleted(t *testing.T) {
+	org := newFakeOrg(t)
+	org.HandleJSON("/services/data/v36.0/sobjects/Account/updated/", func(r *http.Request) interface{} {
+		if r.URL.Query().Get("start") != "2024-03-01T10:00:00Z" || r.URL.Query().Get("end") != "2024-03-02T10:00:00Z" {
+			t.Errorf("Unexpected span %v", r.URL.RawQuery)
+		}
+		return map[string]interface{}{"ids": []string{"001A", "001B"}, "latestDateCovered": "2024-03-02T09:45:00.000+0000"}
+	})
+	org.HandleJSON("/services/data/v36.0/sobjects/Account/deleted/", func(r *http.Request) interface{} {
+		return map[string]interface{}{
+			"deletedRecords":        []map[string]string{{"id": "001C", "deletedDate": "2024-03-01T12:30:00.000+0000"}},
+			"earliestDateAvailable": "2024-02-15T00:00:00.000+0000",
+			"latestDateCovered":     "2024-03-02T10:00:00.000+0000",
+		}
+	})
+	forceApi := org.New(t)
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	updated, err := forceApi.GetUpdated("Account", start, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("GetUpdated failed: %v", err)
+	}
+	if len(updated.Ids) != 2 || !updated.LatestDateCovered.Equal(time.Date(2024, 3, 2, 9, 45, 0, 0, time.UTC)) {
+		t.Fatalf("Unexpected updated records %+v", updated)
+	}
+
+	deleted, err := forceApi.GetDeleted("Account", start, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("GetDeleted failed: %v", err)
+	}
+	if len(deleted.DeletedRecords) != 1 || deleted.DeletedRecords[0].Id != "001C" ||
+		!deleted.DeletedRecords[0].DeletedDate.Equal(time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)) ||
+		deleted.EarliestDateAvailable.Day() != 15 {
+		t.Fatalf("Unexpected deleted records %+v", deleted)
+	}
+
+	if _, err := forceApi.GetUpdated("Account", start, start); err == nil {
+		t.Fatal("Expected an error for an empty span")
+	}
+	if _, err := forceApi.GetDeleted("Contact", start, start.Add(time.Hour)); err == nil {
+		t.Fatal("Expected an error for an unknown sobject")
+	}
+}
+
+type testReplicationHandler struct {
+	batches [][]*testCompositeAccount
+	deleted []DeletedRecord
+	err     error
+}
+
+func (h *testReplicationHandler) Updated(batch *ReplicationBatch) error {
+	var accounts []*testCompositeAccount
+	if err := batch.Decode(&accounts); err != nil {
+		return err
+	}
+	h.batches = append(h.batches, accounts)
+	return h.err
+}
+
+func (h *testReplicationHandler) Deleted(table string, records []DeletedRecord) error {
+	h.deleted = append(h.deleted, records...)
+	return h.err
+}
+
+func TestReplicatorSync(t *testing.T) {
+	org := newFakeOrg(t)
+	covered := time.Now().UTC().Add(-time.Minute).Truncate(time.Minute)
+	org.HandleJSON("/services/data/v36.0/sobjects/Account/updated/", func(r *http.Request) interface{} {
+		return map[string]interface{}{"ids": []string{"001A", "001B", "001C"}, "latestDateCovered": covered.Format(testReplicationDateLayout)}
+	})
+	org.HandleJSON("/services/data/v36.0/sobjects/Account/deleted/", func(r *http.Request) interface{} {
+		return map[string]interface{}{
+			"deletedRecords":    []map[string]string{{"id": "001D", "deletedDate": covered.Format(testReplicationDateLayout)}},
+			"latestDateCovered": covered.Add(-time.Minute).Format(testReplicationDateLayout),
+		}
+	})
+	org.HandleJSON("/services/data/v36.0/composite/sobjects/Account", func(r *http.Request) interface{} {
+		req := struct {
+			Ids []string `json:"ids"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		records := []interface{}{}
+		for _, id := range req.Ids {
+			if id == "001B" {
+				records = append(records, nil)
+				continue
+			}
+			records = append(records, map[string]string{"Id": id, "BillingCity": "Oslo"})
+		}
+		return records
+	})
+	forceApi := org.New(t)
+
+	store := NewMemoryCheckpointStore()
+	start := time.Now().UTC().Add(-2 * time.Hour)
+	store.Save("Account", start)
+
+	r, err := forceApi.NewReplicator(store, ReplicatorBatchSize(2))
+	if err != nil {
+		t.Fatalf("NewReplicator failed: %v", err)
+	}
+
+	// A failing handler leaves the checkpoint where it was.
+	failing := &testReplicationHandler{err: errors.New("warehouse down")}
+	if err := r.Sync("Account", []string{"Id", "BillingCity"}, failing); err == nil {
+		t.Fatal("Expected the handler error")
+	}
+	if checkpoint, _ := store.Load("Account"); !checkpoint.Equal(start) {
+		t.Fatalf("Expected the checkpoint to stay at %v, got %v", start, checkpoint)
+	}
+
+	handler := &testReplicationHandler{}
+	if err := r.Sync("Account", []string{"Id", "BillingCity"}, handler); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(handler.batches) != 2 || len(handler.batches[0]) != 2 || handler.batches[0][1] != nil || handler.batches[1][0].Id != "001C" {
+		t.Fatalf("Unexpected batches %+v", handler.batches)
+	}
+	if len(handler.deleted) != 1 || handler.deleted[0].Id != "001D" {
+		t.Fatalf("Unexpected deleted records %+v", handler.deleted)
+	}
+	if checkpoint, _ := store.Load("Account"); !checkpoint.Equal(covered.Add(-time.Minute)) {
+		t.Fatalf("Expected the checkpoint to move to the earlier covered date, got %v", checkpoint)
+	}
+}
+
+func TestReplicatorSplitsLargeSpans(t *testing.T) {
+	org := newFakeOrg(t)
+	var mu sync.Mutex
+	calls := 0
+	handleSpan := func(pattern string, fn func(end string) interface{}) {
+		org.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			start, _ := time.Parse(soqlDateTimeFormat, r.URL.Query().Get("start"))
+			end, _ := time.Parse(soqlDateTimeFormat, r.URL.Query().Get("end"))
+			w.Header().Set("Content-Type", jsonContentType)
+			if strings.HasSuffix(pattern, "/updated/") {
+				mu.Lock()
+				calls++
+				mu.Unlock()
+				if end.Sub(start) > 12*time.Hour {
+					w.WriteHeader(http.StatusBadRequest)
+					io.WriteString(w, `[{"errorCode":"EXCEEDED_ID_LIMIT","message":"too many ids"}]`)
+					return
+				}
+			}
+			json.NewEncoder(w).Encode(fn(end.Format(testReplicationDateLayout)))
+		})
+	}
+	handleSpan("/services/data/v36.0/sobjects/Account/updated/", func(end string) interface{} {
+		return map[string]interface{}{"ids": []string{}, "latestDateCovered": end}
+	})
+	handleSpan("/services/data/v36.0/sobjects/Account/deleted/", func(end string) interface{} {
+		return map[string]interface{}{"deletedRecords": []interface{}{}, "latestDateCovered": end}
+	})
+	forceApi := org.New(t)
+
+	store := NewMemoryCheckpointStore()
+	r, err := forceApi.NewReplicator(store)
+	if err != nil {
+		t.Fatalf("NewReplicator failed: %v", err)
+	}
+	if err := r.Sync("Account", []string{"Id"}, &testReplicationHandler{}); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	checkpoint, _ := store.Load("Account")
+	if time.Since(checkpoint) > time.Minute || calls < 60 {
+		t.Fatalf("Expected 30 days to be synced in parts up to now, got %v after %v calls", checkpoint, calls)
+	}
+
+	store.Save("Account", time.Now().Add(-31*24*time.Hour))
+	if err := r.Sync("Account", []string{"Id"}, &testReplicationHandler{}); err == nil {
+		t.Fatal("Expected an error for an expired checkpoint")
+	}
+}
+
+func TestReplicatorSyncRetrieveError(t *testing.T) {
+	org := newFakeOrg(t)
+	covered := time.Now().UTC().Add(-time.Minute).Truncate(time.Minute)
+	org.HandleJSON("/services/data/v36.0/sobjects/Account/updated/", func(r *http.Request) interface{} {
+		return map[string]interface{}{"ids": []string{"001A"}, "latestDateCovered": covered.Format(testReplicationDateLayout)}
+	})
+	org.HandleJSON("/services/data/v36.0/sobjects/Account/deleted/", func(r *http.Request) interface{} {
+		return map[string]interface{}{"deletedRecords": []interface{}{}, "latestDateCovered": covered.Format(testReplicationDateLayout)}
+	})
+	org.mux.HandleFunc("/services/data/v36.0/composite/sobjects/Account", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", jsonContentType)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `[{"errorCode":"INVALID_FIELD","message":"No such column 'Nope' on entity 'Account'"}]`)
+	})
+	forceApi := org.New(t)
+
+	store := NewMemoryCheckpointStore()
+	start := time.Now().UTC().Add(-2 * time.Hour)
+	store.Save("Account", start)
+
+	r, err := forceApi.NewReplicator(store)
+	if err != nil {
+		t.Fatalf("NewReplicator failed: %v", err)
+	}
+
+	handler := &testReplicationHandler{}
+	err = r.Sync("Account", []string{"Id", "Nope"}, handler)
+	if apiErrors, ok := err.(ApiErrors); !ok || apiErrors[0].ErrorCode != "INVALID_FIELD" {
+		t.Fatalf("Expected the retrieve error, got %v", err)
+	}
+	if len(handler.batches) != 0 {
+		t.Fatalf("Expected no batches to be handled, got %+v", handler.batches)
+	}
+	if checkpoint, _ := store.Load("Account"); !checkpoint.Equal(start) {
+		t.Fatalf("Expected the checkpoint to stay at %v, got %v", start, checkpoint)
+	}
+}
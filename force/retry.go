@@ -0,0 +1,161 @@
+package force
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Error codes reported by force.com for requests that were rejected without
+// being processed and may succeed when sent again.
+var retryableErrorCodes = map[string]bool{
+	"REQUEST_LIMIT_EXCEEDED": true,
+	"UNABLE_TO_LOCK_ROW":     true,
+	"SERVER_UNAVAILABLE":     true,
+}
+
+// RetryPolicy controls how requests failing with transient errors are
+// retried. Transient errors are network errors, HTTP 429 and 5xx responses,
+// and the REQUEST_LIMIT_EXCEEDED, UNABLE_TO_LOCK_ROW and SERVER_UNAVAILABLE
+// error codes.
+//
+// A request that may have been processed before failing, i.e. after a network
+// error or a 5xx response without a retryable error code, is only sent again
+// if it is idempotent (GET, HEAD, PUT, DELETE, OPTIONS and OAuth token
+// requests) or RetryNonIdempotent is set. Requests rejected with HTTP 429 or a
+// retryable error code were not processed and are always retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first retry. It doubles with each
+	// further retry up to MaxBackoff. The actual delay is jittered between
+	// half and all of it. A Retry-After header sent by the server is used
+	// instead when present.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// RetryNonIdempotent allows POST and PATCH requests to be replayed after
+	// failures that may have happened after they were processed.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy is a reasonable policy for most integrations.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  30 * time.Second,
+}
+
+// WithRetryPolicy retries REST, bulk and OAuth requests failing with transient
+// errors according to policy. Without it no request is retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(forceApi *ForceApi) error {
+		if policy.MinBackoff < 0 || policy.MaxBackoff < policy.MinBackoff {
+			return errors.New("force: invalid retry backoff")
+		}
+
+		forceApi.retryPolicy = &policy
+		forceApi.OAuth.retryPolicy = &policy
+		return nil
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+		return true
+	}
+
+	return false
+}
+
+// retry decides whether an attempt that failed with err, or with resp and
+// apiErrors, is sent again and if so sleeps until it is due. It only returns
+// an error when ctx is done while sleeping.
+func (policy *RetryPolicy) retry(ctx context.Context, attempt int, idempotent bool, resp *http.Response, apiErrors ApiErrors, err error) (bool, error) {
+	if policy == nil || attempt >= policy.MaxAttempts || ctx.Err() != nil {
+		return false, nil
+	}
+
+	processed := true
+	switch {
+	case err != nil:
+		// The request may or may not have reached the server.
+	case resp.StatusCode == http.StatusTooManyRequests:
+		processed = false
+	case hasRetryableErrorCode(apiErrors):
+		processed = false
+	case resp.StatusCode >= http.StatusInternalServerError:
+	default:
+		return false, nil
+	}
+
+	if processed && !idempotent && !policy.RetryNonIdempotent {
+		return false, nil
+	}
+
+	if err := sleepContext(ctx, policy.backoff(attempt, resp)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func hasRetryableErrorCode(apiErrors ApiErrors) bool {
+	for _, apiError := range apiErrors {
+		if retryableErrorCodes[apiError.ErrorCode] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff returns the delay before retrying after attempt.
+func (policy *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if delay, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return delay
+		}
+	}
+
+	delay := policy.MinBackoff
+	for i := 1; i < attempt && delay < policy.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxBackoff {
+		delay = policy.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
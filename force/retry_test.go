@@ -0,0 +1,133 @@
+package force
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  time.Millisecond,
+	MaxBackoff:  time.Millisecond,
+}
+
+func TestRetryTransientFailure(t *testing.T) {
+	org := newFakeOrg(t)
+
+	var requests int32
+	org.mux.HandleFunc("/services/data/v36.0/query/", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"totalSize": 0, "done": true}`))
+	})
+
+	forceApi := org.New(t, WithRetryPolicy(testRetryPolicy))
+
+	var out map[string]interface{}
+	if err := forceApi.Query("SELECT Id FROM Account", &out); err != nil {
+		t.Fatalf("Expected the query to succeed after retrying, got: %v", err)
+	}
+	if requests != 3 {
+		t.Fatalf("Expected 3 attempts, got %v", requests)
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	org := newFakeOrg(t)
+
+	var requests int32
+	var status int32 = http.StatusInternalServerError
+	org.mux.HandleFunc("/services/data/v36.0/sobjects/Account/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if s := atomic.LoadInt32(&status); s != 0 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(int(s))
+			w.Write([]byte(`[{"errorCode": "UNKNOWN_EXCEPTION", "message": "boom"}]`))
+			return
+		}
+	})
+
+	forceApi := org.New(t, WithRetryPolicy(testRetryPolicy))
+
+	// A POST that may have been processed is not sent again.
+	if err := forceApi.Post("/services/data/v36.0/sobjects/Account/", nil, map[string]string{}, nil); err == nil {
+		t.Fatal("Expected the POST to fail")
+	}
+	if requests != 1 {
+		t.Fatalf("Expected a single attempt, got %v", requests)
+	}
+
+	// A POST rejected for exceeding the rate limit is.
+	atomic.StoreInt32(&requests, 0)
+	atomic.StoreInt32(&status, http.StatusTooManyRequests)
+	if err := forceApi.Post("/services/data/v36.0/sobjects/Account/", nil, map[string]string{}, nil); err == nil {
+		t.Fatal("Expected the POST to fail")
+	}
+	if requests != 3 {
+		t.Fatalf("Expected 3 attempts, got %v", requests)
+	}
+}
+
+// flakyTransport fails the first request to the token endpoint with a
+// network error.
+type flakyTransport struct {
+	failed int32
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == tokenUri && atomic.CompareAndSwapInt32(&f.failed, 0, 1) {
+		return nil, errors.New("connection reset")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRetryAuthentication(t *testing.T) {
+	org := newFakeOrg(t)
+
+	// Token requests are replayed after network errors even though they are
+	// POSTs.
+	org.New(t, WithRoundTripper(&flakyTransport{}), WithRetryPolicy(testRetryPolicy))
+	if org.tokenRequests != 1 {
+		t.Fatalf("Expected a single token request to arrive, got %v", org.tokenRequests)
+	}
+
+	// Without a retry policy the network error fails authentication.
+	_, err := New(WithLoginURL(org.URL), WithConnectedApp("client", "secret"),
+		WithPassword("user", "password", "token"), WithRoundTripper(&flakyTransport{}))
+	if err == nil {
+		t.Fatal("Expected authentication to fail without retries")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if delay, ok := retryAfter("2"); !ok || delay != 2*time.Second {
+		t.Fatalf("Expected 2s, got %v, %v", delay, ok)
+	}
+	if delay, ok := retryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)); !ok || delay != 0 {
+		t.Fatalf("Expected no delay for a past date, got %v, %v", delay, ok)
+	}
+	if _, ok := retryAfter("soon"); ok {
+		t.Fatal("Expected an invalid Retry-After to be ignored")
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 10, MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	for attempt, max := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		9: time.Second,
+	} {
+		delay := policy.backoff(attempt, nil)
+		if delay < max/2 || delay > max {
+			t.Fatalf("Expected backoff after attempt %v within [%v, %v], got %v", attempt, max/2, max, delay)
+		}
+	}
+}
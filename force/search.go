@@ -0,0 +1,253 @@
+package force
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/nimajalali/go-force/forcejson"
+)
+
+var (
+	soslEscaper = strings.NewReplacer(
+		`\`, `\\`, `?`, `\?`, `&`, `\&`, `|`, `\|`, `!`, `\!`, `{`, `\{`, `}`, `\}`,
+		`[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`, `^`, `\^`, `~`, `\~`, `*`, `\*`,
+		`:`, `\:`, `"`, `\"`, `'`, `\'`, `+`, `\+`, `-`, `\-`,
+	)
+
+	sObjectTypesMu sync.RWMutex
+	sObjectTypes   = map[string]reflect.Type{}
+)
+
+// EscapeSOSL escapes the SOSL reserved characters in a search term, so that
+// user input placed in FIND {...} is searched for literally.
+func EscapeSOSL(term string) string {
+	return soslEscaper.Replace(term)
+}
+
+// RegisterSObject registers the type of prototype, e.g. &sobjects.Account{},
+// for decoding search records of its ApiName with SearchRecord.SObject.
+func RegisterSObject(prototype SObject) {
+	t := reflect.TypeOf(prototype)
+
+	sObjectTypesMu.Lock()
+	defer sObjectTypesMu.Unlock()
+
+	sObjectTypes[prototype.ApiName()] = t
+}
+
+// newRegisteredSObject returns a new value of the type registered for the
+// sobject name.
+func newRegisteredSObject(name string) (SObject, bool) {
+	sObjectTypesMu.RLock()
+	t, ok := sObjectTypes[name]
+	sObjectTypesMu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	if t.Kind() == reflect.Ptr {
+		return reflect.New(t.Elem()).Interface().(SObject), true
+	}
+
+	return reflect.New(t).Elem().Interface().(SObject), true
+}
+
+// SearchResult holds the records found by a search, most relevant first.
+type SearchResult struct {
+	Records []*SearchRecord
+
+	// ByType groups the records by their sobject type, e.g. "Account".
+	ByType map[string][]*SearchRecord
+}
+
+// SearchRecord is a record found by a search.
+type SearchRecord struct {
+	Type string
+	Id   string
+
+	// Snippet is the highlighted excerpt of the matching text, if snippets
+	// were requested.
+	Snippet string
+
+	raw json.RawMessage
+}
+
+// Decode unmarshals the record into out, usually a pointer to an SObject
+// struct of the record's type.
+func (r *SearchRecord) Decode(out interface{}) error {
+	if err := forcejson.Unmarshal(r.raw, out); err != nil {
+		return fmt.Errorf("Unable to unmarshal search record: %v", err)
+	}
+
+	return nil
+}
+
+// SObject decodes the record into a new value of the SObject struct
+// registered for its type with RegisterSObject.
+func (r *SearchRecord) SObject() (SObject, error) {
+	sobject, ok := newRegisteredSObject(r.Type)
+	if !ok {
+		return nil, fmt.Errorf("force: no SObject registered for %v", r.Type)
+	}
+	if reflect.TypeOf(sobject).Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("force: SObject registered for %v is not a pointer", r.Type)
+	}
+
+	if err := r.Decode(sobject); err != nil {
+		return nil, err
+	}
+
+	return sobject, nil
+}
+
+type searchRecordHeader struct {
+	Attributes struct {
+		Type string `json:"type"`
+	} `json:"attributes"`
+	Id      string `json:"Id"`
+	Snippet struct {
+		Text string `json:"text"`
+	} `json:"snippet"`
+}
+
+// UnmarshalJSON reads search responses both in the current shape and in the
+// bare array returned by api versions before 37.0.
+func (result *SearchResult) UnmarshalJSON(data []byte) error {
+	var records []json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return err
+		}
+	} else {
+		response := struct {
+			SearchRecords []json.RawMessage `json:"searchRecords"`
+		}{}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return err
+		}
+		records = response.SearchRecords
+	}
+
+	result.Records = make([]*SearchRecord, len(records))
+	result.ByType = make(map[string][]*SearchRecord)
+	for i, raw := range records {
+		header := &searchRecordHeader{}
+		if err := json.Unmarshal(raw, header); err != nil {
+			return err
+		}
+		if header.Attributes.Type == "" {
+			return fmt.Errorf("force: search record %v has no sobject type", i)
+		}
+
+		record := &SearchRecord{
+			Type:    header.Attributes.Type,
+			Id:      header.Id,
+			Snippet: header.Snippet.Text,
+			raw:     raw,
+		}
+		result.Records[i] = record
+		result.ByType[record.Type] = append(result.ByType[record.Type], record)
+	}
+
+	return nil
+}
+
+// Search runs a SOSL search such as
+//
+//	FIND {Acme*} IN NAME FIELDS RETURNING Account(Id, Name), Contact(Id, Email)
+//
+// Escape search terms taken from user input with EscapeSOSL.
+func (forceApi *ForceApi) Search(sosl string) (*SearchResult, error) {
+	return forceApi.SearchContext(context.Background(), sosl)
+}
+
+// SearchContext is like Search but the request is bound to ctx.
+func (forceApi *ForceApi) SearchContext(ctx context.Context, sosl string) (*SearchResult, error) {
+	uri := forceApi.resource(searchKey)
+	if uri == "" {
+		return nil, errors.New("force: search resource is not available")
+	}
+
+	result := &SearchResult{}
+	if err := forceApi.GetContext(ctx, uri, url.Values{"q": {sosl}}, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ParameterizedSearchRequest is a search expressed as parameters instead of
+// SOSL. Q is searched for as given, so it needs no escaping.
+type ParameterizedSearchRequest struct {
+	Q string `json:"q"`
+
+	// In is the scope of fields searched: ALL, NAME, EMAIL, PHONE or
+	// SIDEBAR.
+	In string `json:"in,omitempty"`
+
+	// Fields are returned for every sobject without fields of its own.
+	Fields   []string        `json:"fields,omitempty"`
+	SObjects []SearchSObject `json:"sobjects,omitempty"`
+
+	OverallLimit int `json:"overallLimit,omitempty"`
+
+	// DefaultLimit caps the records of each sobject without a limit of its
+	// own.
+	DefaultLimit int `json:"defaultLimit,omitempty"`
+
+	Division        string         `json:"division,omitempty"`
+	Snippet         *SearchSnippet `json:"snippet,omitempty"`
+	SpellCorrection *bool          `json:"spellCorrection,omitempty"`
+	UpdateTracking  bool           `json:"updateTracking,omitempty"`
+	UpdateViewStat  bool           `json:"updateViewStat,omitempty"`
+}
+
+// SearchSObject selects an sobject to search and what to return of it.
+type SearchSObject struct {
+	Name   string   `json:"name"`
+	Fields []string `json:"fields,omitempty"`
+
+	// Where filters the records with a SOQL condition; build it from
+	// values with BuildCondition.
+	Where   string `json:"where,omitempty"`
+	OrderBy string `json:"orderBy,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+// SearchSnippet requests highlighted excerpts of the matching text with at
+// most TargetLength characters.
+type SearchSnippet struct {
+	TargetLength int `json:"targetLength"`
+}
+
+// ParameterizedSearch runs the search described by req.
+func (forceApi *ForceApi) ParameterizedSearch(req *ParameterizedSearchRequest) (*SearchResult, error) {
+	return forceApi.ParameterizedSearchContext(context.Background(), req)
+}
+
+// ParameterizedSearchContext is like ParameterizedSearch but the request is
+// bound to ctx.
+func (forceApi *ForceApi) ParameterizedSearchContext(ctx context.Context, req *ParameterizedSearchRequest) (*SearchResult, error) {
+	if req == nil || req.Q == "" {
+		return nil, errors.New("force: parameterized search without q")
+	}
+
+	uri := forceApi.resource(parameterizedSearchKey)
+	if uri == "" {
+		return nil, errors.New("force: parameterizedSearch resource is not available")
+	}
+
+	result := &SearchResult{}
+	if err := forceApi.PostContext(ctx, uri, nil, req, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
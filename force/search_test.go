@@ -0,0 +1,125 @@
+package force
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+func TestSearch(t *testing.T) {
+	org := newFakeOrg(t)
+	var sosl string
+	org.HandleJSON("/services/data/v36.0/search/", func(r *http.Request) interface{} {
+		sosl = r.URL.Query().Get("q")
+		return map[string]interface{}{
+			"searchRecords": []interface{}{
+				map[string]interface{}{
+					"attributes": map[string]interface{}{"type": "Account"},
+					"Id":         "001A",
+					"Name":       "Acme",
+				},
+				map[string]interface{}{
+					"attributes": map[string]interface{}{"type": "Contact"},
+					"Id":         "003A",
+				},
+				map[string]interface{}{
+					"attributes": map[string]interface{}{"type": "Account"},
+					"Id":         "001B",
+					"Name":       "Acme Widgets",
+				},
+			},
+		}
+	})
+	forceApi := org.New(t)
+
+	RegisterSObject(&sobjects.Account{})
+
+	query := "FIND {" + EscapeSOSL("Acme (US)") + "} RETURNING Account(Id, Name), Contact(Id)"
+	result, err := forceApi.Search(query)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if sosl != `FIND {Acme \(US\)} RETURNING Account(Id, Name), Contact(Id)` {
+		t.Fatalf("Unexpected search %v", sosl)
+	}
+	if len(result.Records) != 3 || len(result.ByType["Account"]) != 2 || len(result.ByType["Contact"]) != 1 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+
+	sobject, err := result.ByType["Account"][1].SObject()
+	if err != nil {
+		t.Fatalf("SObject failed: %v", err)
+	}
+	if account, ok := sobject.(*sobjects.Account); !ok || account.Id != "001B" || account.Name != "Acme Widgets" {
+		t.Fatalf("Unexpected account %#v", sobject)
+	}
+
+	if _, err := result.ByType["Contact"][0].SObject(); err == nil {
+		t.Fatal("Expected an error for an unregistered sobject type")
+	}
+}
+
+func TestParameterizedSearch(t *testing.T) {
+	org := newFakeOrg(t)
+	var req ParameterizedSearchRequest
+	org.HandleJSON("/services/data/v36.0/parameterizedSearch/", func(r *http.Request) interface{} {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Unable to decode request: %v", err)
+		}
+		// Versions before 37.0 return a bare array.
+		return []interface{}{
+			map[string]interface{}{
+				"attributes": map[string]interface{}{"type": "Account"},
+				"Id":         "001A",
+				"snippet":    map[string]interface{}{"text": "<mark>Acme</mark> Corp"},
+			},
+		}
+	})
+	forceApi := org.New(t)
+
+	where, err := BuildCondition(Eq("BillingCountry", "US"))
+	if err != nil {
+		t.Fatalf("BuildCondition failed: %v", err)
+	}
+	result, err := forceApi.ParameterizedSearch(&ParameterizedSearchRequest{
+		Q:        "Acme",
+		Fields:   []string{"Id"},
+		SObjects: []SearchSObject{{Name: "Account", Where: where, Limit: 5}},
+		Snippet:  &SearchSnippet{TargetLength: 60},
+	})
+	if err != nil {
+		t.Fatalf("ParameterizedSearch failed: %v", err)
+	}
+	if req.Q != "Acme" || len(req.SObjects) != 1 || req.SObjects[0].Where != "BillingCountry = 'US'" || req.Snippet.TargetLength != 60 {
+		t.Fatalf("Unexpected request %+v", req)
+	}
+	if len(result.Records) != 1 || result.Records[0].Snippet != "<mark>Acme</mark> Corp" {
+		t.Fatalf("Unexpected result %+v", result.Records)
+	}
+
+	if _, err := forceApi.ParameterizedSearch(&ParameterizedSearchRequest{}); err == nil {
+		t.Fatal("Expected an error for a search without q")
+	}
+}
+
+func TestSearchMalformed(t *testing.T) {
+	org := newFakeOrg(t)
+	org.mux.HandleFunc("/services/data/v36.0/search/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", jsonContentType)
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `[{"message":"bad sosl","errorCode":"MALFORMED_SEARCH"}]`)
+	})
+	forceApi := org.New(t)
+
+	result, err := forceApi.Search("FIND {Acme")
+	if apiErrors, ok := err.(ApiErrors); !ok || apiErrors[0].ErrorCode != "MALFORMED_SEARCH" {
+		t.Fatalf("Expected the search error, got %+v, %v", result, err)
+	}
+
+	if err := json.Unmarshal([]byte(`[{"Id":"001A"}]`), &SearchResult{}); err == nil {
+		t.Fatal("Expected an error for a record without a type")
+	}
+}
@@ -2,11 +2,14 @@ package force
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"net/http"
 	"net/url"
-	"time"
+	"reflect"
 )
 
 // Interface all standard and custom objects must implement. Needed for uri generation.
@@ -21,6 +24,7 @@ type SObjectResponse struct {
 	Id      string         `json:"id,omitempty"`
 	Errors  []SObjectError `json:"errors,omitempty"` //TODO: Not sure if ApiErrors is the right object
 	Success bool           `json:"success,omitempty"`
+	Created bool           `json:"created,omitempty"` // set by upserts
 }
 type SObjectError struct {
 	Message              string      `json:"message"`
@@ -31,9 +35,11 @@ type SObjectError struct {
 
 // Response recieved from force.com API after insert of an sobject.
 type CreateJobRequest struct {
-	Operation   string `json:"operation,omitempty"`
-	Object      string `json:"object,omitempty"`
-	ContentType string `json:"contentType,omitempty"`
+	Operation           string `json:"operation,omitempty"`
+	Object              string `json:"object,omitempty"`
+	ContentType         string `json:"contentType,omitempty"`
+	ExternalIdFieldName string `json:"externalIdFieldName,omitempty"`
+	ConcurrencyMode     string `json:"concurrencyMode,omitempty"`
 }
 
 // Response recieved from force.com API after insert of an sobject.
@@ -86,6 +92,7 @@ const (
 	BULK_UPDATE
 	BULK_UPSERT
 	BULK_DELETE
+	BULK_HARD_DELETE
 )
 
 func (b bulkMode) String() string {
@@ -98,24 +105,38 @@ func (b bulkMode) String() string {
 		return "upsert"
 	case BULK_DELETE:
 		return "delete"
+	case BULK_HARD_DELETE:
+		return "hardDelete"
 	}
 	return ""
 }
 
 func (forceAPI *ForceApi) DescribeSObjects() (map[string]*SObjectMetaData, error) {
-	if err := forceAPI.getApiSObjects(); err != nil {
+	return forceAPI.DescribeSObjectsContext(context.Background())
+}
+
+// DescribeSObjectsContext is like DescribeSObjects but the request is bound
+// to ctx.
+func (forceAPI *ForceApi) DescribeSObjectsContext(ctx context.Context) (map[string]*SObjectMetaData, error) {
+	if err := forceAPI.getApiSObjects(ctx); err != nil {
 		return nil, err
 	}
 
-	return forceAPI.apiSObjects, nil
+	return forceAPI.sObjectsCopy(), nil
 }
 
 func (forceApi *ForceApi) DescribeSObject(in SObject) (resp *SObjectDescription, err error) {
+	return forceApi.DescribeSObjectContext(context.Background(), in)
+}
+
+// DescribeSObjectContext is like DescribeSObject but the request is bound to
+// ctx.
+func (forceApi *ForceApi) DescribeSObjectContext(ctx context.Context, in SObject) (resp *SObjectDescription, err error) {
 	// Check cache
-	resp, ok := forceApi.apiSObjectDescriptions[in.ApiName()]
+	resp, ok := forceApi.cachedSObjectDescription(in.ApiName())
 	if !ok {
 		// Attempt retrieval from api
-		sObjectMetaData, ok := forceApi.apiSObjects[in.ApiName()]
+		sObjectMetaData, ok := forceApi.sObjectMetaData(in.ApiName())
 		if !ok {
 			err = fmt.Errorf("Unable to find metadata for object: %v", in.ApiName())
 			return
@@ -124,7 +145,7 @@ func (forceApi *ForceApi) DescribeSObject(in SObject) (resp *SObjectDescription,
 		uri := sObjectMetaData.URLs[sObjectDescribeKey]
 
 		resp = &SObjectDescription{}
-		err = forceApi.Get(uri, nil, resp)
+		err = forceApi.GetContext(ctx, uri, nil, resp)
 		if err != nil {
 			return
 		}
@@ -147,7 +168,7 @@ func (forceApi *ForceApi) DescribeSObject(in SObject) (resp *SObjectDescription,
 			resp.AllFields = allFields.String()
 		}
 
-		forceApi.apiSObjectDescriptions[in.ApiName()] = resp
+		forceApi.cacheSObjectDescription(in.ApiName(), resp)
 	}
 
 	return
@@ -155,141 +176,294 @@ func (forceApi *ForceApi) DescribeSObject(in SObject) (resp *SObjectDescription,
 
 // Get a list of all object types
 func (forceApi *ForceApi) GetSObjects() (map[string]*SObjectMetaData, error) {
-	return forceApi.apiSObjects, nil
+	return forceApi.sObjectsCopy(), nil
 }
 
 func (forceApi *ForceApi) GetSObject(id string, fields []string, out SObject) (err error) {
-	uri := strings.Replace(forceApi.apiSObjects[out.ApiName()].URLs[rowTemplateKey], idKey, id, 1)
+	return forceApi.GetSObjectContext(context.Background(), id, fields, out)
+}
+
+// GetSObjectContext is like GetSObject but the request is bound to ctx.
+func (forceApi *ForceApi) GetSObjectContext(ctx context.Context, id string, fields []string, out SObject) (err error) {
+	rowTemplate, err := forceApi.sObjectUrl(out.ApiName(), rowTemplateKey)
+	if err != nil {
+		return
+	}
+	uri := strings.Replace(rowTemplate, idKey, id, 1)
 
 	params := url.Values{}
 	if len(fields) > 0 {
 		params.Add("fields", strings.Join(fields, ","))
 	}
 
-	err = forceApi.Get(uri, params, out.(interface{}))
+	err = forceApi.GetContext(ctx, uri, params, out.(interface{}))
 
 	return
 }
 
-func (forceApi *ForceApi) BulkQuerySObjects(table string, query string) ([]*SObjectResponse, error) {
-	if _, ok := forceApi.apiSObjects[table]; ok {
+// BulkQuerySObjects runs query as a Bulk API 1.0 query job on the sobject
+// table and returns the records it matched. With BulkPKChunking the job is
+// split into a batch per chunk of record ids and their results are merged.
+// Use BulkQuerySObjectsInto to read the fields of the records.
+func (forceApi *ForceApi) BulkQuerySObjects(table string, query string, opts ...BulkOption) ([]*SObjectResponse, error) {
+	return forceApi.BulkQuerySObjectsContext(context.Background(), table, query, opts...)
+}
 
-		job, err := forceApi.createJob(table, "query", "CSV")
+// BulkQuerySObjectsContext is like BulkQuerySObjects but every request and
+// the wait between batch status polls are bound to ctx.
+func (forceApi *ForceApi) BulkQuerySObjectsContext(ctx context.Context, table string, query string, opts ...BulkOption) ([]*SObjectResponse, error) {
+	records, err := forceApi.bulkQueryRecords(ctx, table, query, opts)
+	if err != nil {
+		return nil, err
+	}
 
-		if nil != err {
-			return nil, err
+	results := make([]*SObjectResponse, len(records))
+	for i, record := range records {
+		if err := json.Unmarshal(record, &results[i]); err != nil {
+			return nil, fmt.Errorf("Unable to unmarshal record: %v", err)
 		}
+	}
 
-		defer forceApi.closeJob(job.ID)
+	return results, nil
+}
 
-		res, err := forceApi.createQueryBatch(job.ID, query)
+// BulkQuerySObjectsInto is like BulkQuerySObjects but decodes the records
+// into out, a pointer to a slice of SObject structs or pointers to them, as
+// RetrieveSObjects does.
+func (forceApi *ForceApi) BulkQuerySObjectsInto(table string, query string, out interface{}, opts ...BulkOption) error {
+	return forceApi.BulkQuerySObjectsIntoContext(context.Background(), table, query, out, opts...)
+}
 
-		if nil != err {
-			return nil, err
-		}
+// BulkQuerySObjectsIntoContext is like BulkQuerySObjectsInto but every
+// request and the wait between batch status polls are bound to ctx.
+func (forceApi *ForceApi) BulkQuerySObjectsIntoContext(ctx context.Context, table string, query string, out interface{}, opts ...BulkOption) error {
+	slice, err := recordSlice(out, "BulkQuerySObjectsInto")
+	if err != nil {
+		return err
+	}
 
-		batchID := res.ID
+	records, err := forceApi.bulkQueryRecords(ctx, table, query, opts)
+	if err != nil {
+		return err
+	}
 
-		for res.State != "Completed" {
-			time.Sleep(time.Second * time.Duration(2))
-			res, err = forceApi.getBatchStatus(job.ID, batchID)
+	decoded, err := appendRecords(reflect.MakeSlice(slice.Type(), 0, len(records)), records)
+	if err != nil {
+		return err
+	}
 
-			if nil != err {
-				return nil, err
-			}
+	slice.Set(decoded)
+	return nil
+}
 
-			if res.State == "Failed" {
-				return nil, errors.New("Failed import")
-			}
-		}
+// bulkQueryRecords runs a query job and returns the records of all its
+// batches.
+func (forceApi *ForceApi) bulkQueryRecords(ctx context.Context, table string, query string, opts []BulkOption) ([]json.RawMessage, error) {
+	if _, ok := forceApi.sObjectMetaData(table); !ok {
+		err := errors.New("Not found")
 
-		if res.State == "Completed" {
-			results, err := forceApi.getBatchResults(job.ID, batchID)
-			if nil != err {
-				return nil, err
-			}
+		return nil, err
+	}
 
-			return results, nil
-		}
+	options, err := newBulkOptions(opts)
+	if err != nil {
+		return nil, err
+	}
 
-		return nil, errors.New("Unknown error")
+	job, err := forceApi.createJob(ctx, table, "query", "JSON", options)
 
-	} else {
-		err := errors.New("Not found")
+	if nil != err {
+		return nil, err
+	}
 
+	if _, err := forceApi.createQueryBatch(ctx, job.ID, query); err != nil {
+		forceApi.abortJob(context.WithoutCancel(ctx), job.ID)
 		return nil, err
 	}
+
+	// No more batches are added; those queued are still processed.
+	if _, err := forceApi.closeJob(ctx, job.ID); err != nil {
+		forceApi.abortJob(context.WithoutCancel(ctx), job.ID)
+		return nil, err
+	}
+
+	batches, err := forceApi.waitForBatches(ctx, job.ID, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// A batch split by PK chunking is left NotProcessed; the batches of its
+	// chunks hold the results.
+	records := []json.RawMessage{}
+	for _, batch := range batches {
+		switch batch.State {
+		case bulkBatchFailed:
+			return nil, batchError(batch)
+		case bulkBatchCompleted:
+			batchResults, err := forceApi.getQueryBatchResults(ctx, job.ID, batch.ID)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, batchResults...)
+		}
+	}
+
+	return records, nil
+}
+
+// BulkInsertSObjects inserts the records in of the sobject table with a Bulk
+// API 1.0 job, and returns the result of every record in order. The records
+// are sent in as many batches as the batch size of the org and the limits of
+// 10,000 records and 10MB per batch require.
+func (forceApi *ForceApi) BulkInsertSObjects(table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error) {
+	return forceApi.BulkInsertSObjectsContext(context.Background(), table, in, opts...)
 }
 
-func (forceApi *ForceApi) BulkInsertSObjects(table string, in []SObject) ([]*SObjectResponse, error) {
-	return forceApi.bulkModifySObjects(BULK_INSERT, table, in)
+// BulkInsertSObjectsContext is like BulkInsertSObjects but every request and
+// the wait between batch status polls are bound to ctx.
+func (forceApi *ForceApi) BulkInsertSObjectsContext(ctx context.Context, table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error) {
+	return forceApi.bulkModifySObjects(ctx, BULK_INSERT, table, in, opts)
 }
 
-func (forceApi *ForceApi) BulkUpdateSObjects(table string, in []SObject) ([]*SObjectResponse, error) {
-	return forceApi.bulkModifySObjects(BULK_UPDATE, table, in)
+// BulkUpdateSObjects is like BulkInsertSObjects but updates the records.
+func (forceApi *ForceApi) BulkUpdateSObjects(table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error) {
+	return forceApi.BulkUpdateSObjectsContext(context.Background(), table, in, opts...)
 }
 
-//func (forceApi *ForceApi) BulkUpsertSObjects(table string, in []SObject) ([]*SObjectResponse, error) {
-//	return forceApi.bulkModifySObjects(BULK_UPSERT, table, in)
-//}
-//
-//func (forceApi *ForceApi) BulkDeleteSObjects(table string, in []SObject) ([]*SObjectResponse, error) {
-//	return forceApi.bulkModifySObjects(BULK_DELETE, table, in)
-//}
+// BulkUpdateSObjectsContext is like BulkUpdateSObjects but every request and
+// the wait between batch status polls are bound to ctx.
+func (forceApi *ForceApi) BulkUpdateSObjectsContext(ctx context.Context, table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error) {
+	return forceApi.bulkModifySObjects(ctx, BULK_UPDATE, table, in, opts)
+}
 
-func (forceApi *ForceApi) bulkModifySObjects(b bulkMode, table string, in []SObject) ([]*SObjectResponse, error) {
-	if _, ok := forceApi.apiSObjects[table]; !ok {
+// BulkUpsertSObjects is like BulkInsertSObjects but upserts the records,
+// matching them by the ExternalIdApiName of the first record unless
+// BulkExternalIdField names another field.
+func (forceApi *ForceApi) BulkUpsertSObjects(table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error) {
+	return forceApi.BulkUpsertSObjectsContext(context.Background(), table, in, opts...)
+}
+
+// BulkUpsertSObjectsContext is like BulkUpsertSObjects but every request and
+// the wait between batch status polls are bound to ctx.
+func (forceApi *ForceApi) BulkUpsertSObjectsContext(ctx context.Context, table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error) {
+	return forceApi.bulkModifySObjects(ctx, BULK_UPSERT, table, in, opts)
+}
+
+// BulkDeleteSObjects is like BulkInsertSObjects but deletes the records,
+// moving them to the recycle bin. Only their Id is sent.
+func (forceApi *ForceApi) BulkDeleteSObjects(table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error) {
+	return forceApi.BulkDeleteSObjectsContext(context.Background(), table, in, opts...)
+}
+
+// BulkDeleteSObjectsContext is like BulkDeleteSObjects but every request and
+// the wait between batch status polls are bound to ctx.
+func (forceApi *ForceApi) BulkDeleteSObjectsContext(ctx context.Context, table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error) {
+	return forceApi.bulkModifySObjects(ctx, BULK_DELETE, table, in, opts)
+}
+
+// BulkHardDeleteSObjects is like BulkDeleteSObjects but the records are
+// deleted permanently. The user needs the Bulk API Hard Delete permission.
+func (forceApi *ForceApi) BulkHardDeleteSObjects(table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error) {
+	return forceApi.BulkHardDeleteSObjectsContext(context.Background(), table, in, opts...)
+}
+
+// BulkHardDeleteSObjectsContext is like BulkHardDeleteSObjects but every
+// request and the wait between batch status polls are bound to ctx.
+func (forceApi *ForceApi) BulkHardDeleteSObjectsContext(ctx context.Context, table string, in []SObject, opts ...BulkOption) ([]*SObjectResponse, error) {
+	return forceApi.bulkModifySObjects(ctx, BULK_HARD_DELETE, table, in, opts)
+}
+
+func (forceApi *ForceApi) bulkModifySObjects(ctx context.Context, b bulkMode, table string, in []SObject, opts []BulkOption) ([]*SObjectResponse, error) {
+	if _, ok := forceApi.sObjectMetaData(table); !ok {
 		err := errors.New("Not found")
 
 		return nil, err
 	}
+	if len(in) == 0 {
+		return nil, errors.New("force: bulk job without records")
+	}
 
-	job, err := forceApi.createJob(table, b.String(), "JSON")
-
-	if nil != err {
+	if b == BULK_UPSERT {
+		opts = append([]BulkOption{BulkExternalIdField(in[0].ExternalIdApiName())}, opts...)
+	}
+	options, err := newBulkOptions(opts)
+	if err != nil {
 		return nil, err
 	}
+	if b == BULK_UPSERT && options.externalIdFieldName == "" {
+		return nil, fmt.Errorf("force: bulk upsert of %v without an external id field", table)
+	}
 
-	defer forceApi.closeJob(job.ID)
+	batches, err := splitBulkBatches(in, int(forceApi.maxBatchSize()), b == BULK_DELETE || b == BULK_HARD_DELETE)
+	if err != nil {
+		return nil, err
+	}
 
-	res, err := forceApi.createModifyBatch(job.ID, in)
+	job, err := forceApi.createJob(ctx, table, b.String(), "JSON", options)
 
 	if nil != err {
 		return nil, err
 	}
 
-	batchID := res.ID
-
-	for res.State != "Completed" {
-		time.Sleep(time.Second * time.Duration(2))
-		res, err = forceApi.getBatchStatus(job.ID, batchID)
+	batchIDs := make([]string, len(batches))
+	for i, batch := range batches {
+		res, err := forceApi.createModifyBatch(ctx, job.ID, batch)
 
 		if nil != err {
+			// Abort the batches already queued rather than load part of in.
+			forceApi.abortJob(context.WithoutCancel(ctx), job.ID)
 			return nil, err
 		}
 
-		if res.State == "Failed" {
-			return nil, errors.New(res.StateMessage)
-		}
+		batchIDs[i] = res.ID
+	}
+
+	if _, err := forceApi.closeJob(ctx, job.ID); err != nil {
+		// An open job would keep its queued batches waiting.
+		forceApi.abortJob(context.WithoutCancel(ctx), job.ID)
+		return nil, err
 	}
 
-	if res.State == "Completed" {
-		results, err := forceApi.getBatchResults(job.ID, batchID)
+	list, err := forceApi.waitForBatches(ctx, job.ID, options)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]*CreateBatchResponse, len(list))
+	for _, batch := range list {
+		states[batch.ID] = batch
+	}
+
+	// Merge the results in the order the records were given.
+	results := make([]*SObjectResponse, 0, len(in))
+	for _, batchID := range batchIDs {
+		batch, ok := states[batchID]
+		if !ok {
+			return nil, fmt.Errorf("force: bulk batch %v is missing from job %v", batchID, job.ID)
+		}
+		if batch.State == bulkBatchFailed {
+			return nil, batchError(batch)
+		}
+
+		batchResults, err := forceApi.getBatchResults(ctx, job.ID, batchID)
 		if nil != err {
 			return nil, err
 		}
-
-		return results, nil
+		results = append(results, batchResults...)
 	}
 
-	return nil, errors.New("Unknown error")
-
+	return results, nil
 }
 
-func (forceApi *ForceApi) createQueryBatch(jobID string, query string) (*CreateBatchResponse, error) {
+func (forceApi *ForceApi) createQueryBatch(ctx context.Context, jobID string, query string) (*CreateBatchResponse, error) {
 
+	// The query is sent as is, whatever the content type of the job.
 	jobResp := &CreateBatchResponse{}
-	err := forceApi.requestWithContentType("POST", "/services/async/37.0/job/" + jobID + "/batch", nil, query, jobResp, "text/csv")
+	err := forceApi.do(ctx, &apiRequest{
+		method:      "POST",
+		path:        forceApi.asyncJobUri(jobID) + "/batch",
+		body:        []byte(query),
+		contentType: jsonContentType,
+	}, jobResp)
 
 	if nil != err {
 		return nil, err
@@ -298,10 +472,10 @@ func (forceApi *ForceApi) createQueryBatch(jobID string, query string) (*CreateB
 	return jobResp, nil
 }
 
-func (forceApi *ForceApi) createModifyBatch(jobID string, in []SObject) (*CreateBatchResponse, error) {
+func (forceApi *ForceApi) createModifyBatch(ctx context.Context, jobID string, in []json.RawMessage) (*CreateBatchResponse, error) {
 
 	jobResp := &CreateBatchResponse{}
-	err := forceApi.Post("/services/async/37.0/job/" + jobID + "/batch", nil, in, jobResp)
+	err := forceApi.PostContext(ctx, forceApi.asyncJobUri(jobID) + "/batch", nil, in, jobResp)
 
 	if nil != err {
 		return nil, err
@@ -310,10 +484,10 @@ func (forceApi *ForceApi) createModifyBatch(jobID string, in []SObject) (*Create
 	return jobResp, nil
 }
 
-func (forceApi *ForceApi) getBatchStatus(jobID string, batchID string) (*CreateBatchResponse, error) {
+func (forceApi *ForceApi) getBatchStatus(ctx context.Context, jobID string, batchID string) (*CreateBatchResponse, error) {
 
 	jobResp := &CreateBatchResponse{}
-	err := forceApi.Get("/services/async/37.0/job/" + jobID + "/batch/" + batchID, nil, jobResp)
+	err := forceApi.GetContext(ctx, forceApi.asyncJobUri(jobID) + "/batch/" + batchID, nil, jobResp)
 
 	if nil != err {
 		return nil, err
@@ -322,10 +496,10 @@ func (forceApi *ForceApi) getBatchStatus(jobID string, batchID string) (*CreateB
 	return jobResp, nil
 }
 
-func (forceApi *ForceApi) getBatchResults(jobID string, batchID string) ([]*SObjectResponse, error) {
+func (forceApi *ForceApi) getBatchResults(ctx context.Context, jobID string, batchID string) ([]*SObjectResponse, error) {
 
 	jobResp := []*SObjectResponse{}
-	err := forceApi.Get("/services/async/37.0/job/" + jobID + "/batch/" + batchID + "/result", nil, &jobResp)
+	err := forceApi.GetContext(ctx, forceApi.asyncJobUri(jobID) + "/batch/" + batchID + "/result", nil, &jobResp)
 
 	if nil != err {
 		return nil, err
@@ -334,14 +508,61 @@ func (forceApi *ForceApi) getBatchResults(jobID string, batchID string) ([]*SObj
 	return jobResp, nil
 }
 
-func (forceApi *ForceApi) createJob(table string, operation string, contentType string) (*CreateJobResponse, error) {
+// getQueryBatchResults returns the records of every result of a completed
+// query batch.
+func (forceApi *ForceApi) getQueryBatchResults(ctx context.Context, jobID string, batchID string) ([]json.RawMessage, error) {
+
+	resultIDs := []string{}
+	err := forceApi.GetContext(ctx, forceApi.asyncJobUri(jobID) + "/batch/" + batchID + "/result", nil, &resultIDs)
+
+	if nil != err {
+		return nil, err
+	}
+
+	jobResp := []json.RawMessage{}
+	for _, resultID := range resultIDs {
+		records := []json.RawMessage{}
+		err := forceApi.GetContext(ctx, forceApi.asyncJobUri(jobID) + "/batch/" + batchID + "/result/" + resultID, nil, &records)
+
+		if nil != err {
+			return nil, err
+		}
+
+		jobResp = append(jobResp, records...)
+	}
+
+	return jobResp, nil
+}
+
+func (forceApi *ForceApi) createJob(ctx context.Context, table string, operation string, contentType string, options *bulkOptions) (*CreateJobResponse, error) {
 	req := &CreateJobRequest{
-		Operation:   operation,
-		Object:      table,
-		ContentType: contentType,
+		Operation:       operation,
+		Object:          table,
+		ContentType:     contentType,
+		ConcurrencyMode: options.concurrencyMode,
 	}
+	if operation == BULK_UPSERT.String() {
+		req.ExternalIdFieldName = options.externalIdFieldName
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshaling encoded payload: %v", err)
+	}
+
+	// PK chunking only applies to query jobs.
+	var header http.Header
+	if operation == "query" && options.pkChunkSize > 0 {
+		header = http.Header{pkChunkingHeader: {fmt.Sprintf("chunkSize=%d", options.pkChunkSize)}}
+	}
+
 	jobResp := &CreateJobResponse{}
-	err := forceApi.Post("/services/async/37.0/job", nil, req, jobResp)
+	err = forceApi.do(ctx, &apiRequest{
+		method:      "POST",
+		path:        forceApi.asyncJobUri(""),
+		header:      header,
+		body:        body,
+		contentType: jsonContentType,
+	}, jobResp)
 
 	if nil != err {
 		return nil, err
@@ -350,13 +571,28 @@ func (forceApi *ForceApi) createJob(table string, operation string, contentType
 	return jobResp, nil
 }
 
-func (forceApi *ForceApi) closeJob(jobID string) (*CreateJobResponse, error) {
+func (forceApi *ForceApi) closeJob(ctx context.Context, jobID string) (*CreateJobResponse, error) {
 	req := &CloseJobRequest{
 		State: "Closed",
 	}
 
 	jobResp := &CreateJobResponse{}
-	err := forceApi.Post("/services/async/37.0/job/" + jobID, nil, req, jobResp)
+	err := forceApi.PostContext(ctx, forceApi.asyncJobUri(jobID), nil, req, jobResp)
+
+	if nil != err {
+		return nil, err
+	}
+
+	return jobResp, nil
+}
+
+func (forceApi *ForceApi) abortJob(ctx context.Context, jobID string) (*CreateJobResponse, error) {
+	req := &CloseJobRequest{
+		State: "Aborted",
+	}
+
+	jobResp := &CreateJobResponse{}
+	err := forceApi.PostContext(ctx, forceApi.asyncJobUri(jobID), nil, req, jobResp)
 
 	if nil != err {
 		return nil, err
@@ -365,10 +601,10 @@ func (forceApi *ForceApi) closeJob(jobID string) (*CreateJobResponse, error) {
 	return jobResp, nil
 }
 
-func (forceApi *ForceApi) getJobStatus(jobID string) (*CreateJobResponse, error) {
+func (forceApi *ForceApi) getJobStatus(ctx context.Context, jobID string) (*CreateJobResponse, error) {
 
 	jobResp := &CreateJobResponse{}
-	err := forceApi.Get("/services/async/37.0/job/" + jobID, nil, jobResp)
+	err := forceApi.GetContext(ctx, forceApi.asyncJobUri(jobID), nil, jobResp)
 
 	if nil != err {
 		return nil, err
@@ -378,11 +614,16 @@ func (forceApi *ForceApi) getJobStatus(jobID string) (*CreateJobResponse, error)
 }
 
 func (forceApi *ForceApi) InsertSObject(in SObject) (resp *SObjectResponse, err error) {
-	if sObject, ok := forceApi.apiSObjects[in.ApiName()]; ok {
+	return forceApi.InsertSObjectContext(context.Background(), in)
+}
+
+// InsertSObjectContext is like InsertSObject but the request is bound to ctx.
+func (forceApi *ForceApi) InsertSObjectContext(ctx context.Context, in SObject) (resp *SObjectResponse, err error) {
+	if sObject, ok := forceApi.sObjectMetaData(in.ApiName()); ok {
 		uri := sObject.URLs[sObjectKey]
 
 		resp = &SObjectResponse{}
-		err = forceApi.Post(uri, nil, in.(interface{}), resp)
+		err = forceApi.PostContext(ctx, uri, nil, in.(interface{}), resp)
 	} else {
 		err = errors.New("Not found")
 	}
@@ -391,50 +632,95 @@ func (forceApi *ForceApi) InsertSObject(in SObject) (resp *SObjectResponse, err
 }
 
 func (forceApi *ForceApi) UpdateSObject(id string, in SObject) (err error) {
-	uri := strings.Replace(forceApi.apiSObjects[in.ApiName()].URLs[rowTemplateKey], idKey, id, 1)
+	return forceApi.UpdateSObjectContext(context.Background(), id, in)
+}
+
+// UpdateSObjectContext is like UpdateSObject but the request is bound to ctx.
+func (forceApi *ForceApi) UpdateSObjectContext(ctx context.Context, id string, in SObject) (err error) {
+	rowTemplate, err := forceApi.sObjectUrl(in.ApiName(), rowTemplateKey)
+	if err != nil {
+		return
+	}
+	uri := strings.Replace(rowTemplate, idKey, id, 1)
 
-	err = forceApi.Patch(uri, nil, in.(interface{}), nil)
+	err = forceApi.PatchContext(ctx, uri, nil, in.(interface{}), nil)
 
 	return
 }
 
 func (forceApi *ForceApi) DeleteSObject(id string, in SObject) (err error) {
-	uri := strings.Replace(forceApi.apiSObjects[in.ApiName()].URLs[rowTemplateKey], idKey, id, 1)
+	return forceApi.DeleteSObjectContext(context.Background(), id, in)
+}
+
+// DeleteSObjectContext is like DeleteSObject but the request is bound to ctx.
+func (forceApi *ForceApi) DeleteSObjectContext(ctx context.Context, id string, in SObject) (err error) {
+	rowTemplate, err := forceApi.sObjectUrl(in.ApiName(), rowTemplateKey)
+	if err != nil {
+		return
+	}
+	uri := strings.Replace(rowTemplate, idKey, id, 1)
 
-	err = forceApi.Delete(uri, nil)
+	err = forceApi.DeleteContext(ctx, uri, nil)
 
 	return
 }
 
 func (forceApi *ForceApi) GetSObjectByExternalId(id string, fields []string, out SObject) (err error) {
-	uri := fmt.Sprintf("%v/%v/%v", forceApi.apiSObjects[out.ApiName()].URLs[sObjectKey],
-		out.ExternalIdApiName(), id)
+	return forceApi.GetSObjectByExternalIdContext(context.Background(), id, fields, out)
+}
+
+// GetSObjectByExternalIdContext is like GetSObjectByExternalId but the request
+// is bound to ctx.
+func (forceApi *ForceApi) GetSObjectByExternalIdContext(ctx context.Context, id string, fields []string, out SObject) (err error) {
+	sObjectUrl, err := forceApi.sObjectUrl(out.ApiName(), sObjectKey)
+	if err != nil {
+		return
+	}
+	uri := fmt.Sprintf("%v/%v/%v", sObjectUrl, out.ExternalIdApiName(), id)
 
 	params := url.Values{}
 	if len(fields) > 0 {
 		params.Add("fields", strings.Join(fields, ","))
 	}
 
-	err = forceApi.Get(uri, params, out.(interface{}))
+	err = forceApi.GetContext(ctx, uri, params, out.(interface{}))
 
 	return
 }
 
 func (forceApi *ForceApi) UpsertSObjectByExternalId(id string, in SObject) (resp *SObjectResponse, err error) {
-	uri := fmt.Sprintf("%v/%v/%v", forceApi.apiSObjects[in.ApiName()].URLs[sObjectKey],
-		in.ExternalIdApiName(), id)
+	return forceApi.UpsertSObjectByExternalIdContext(context.Background(), id, in)
+}
+
+// UpsertSObjectByExternalIdContext is like UpsertSObjectByExternalId but the
+// request is bound to ctx.
+func (forceApi *ForceApi) UpsertSObjectByExternalIdContext(ctx context.Context, id string, in SObject) (resp *SObjectResponse, err error) {
+	sObjectUrl, err := forceApi.sObjectUrl(in.ApiName(), sObjectKey)
+	if err != nil {
+		return
+	}
+	uri := fmt.Sprintf("%v/%v/%v", sObjectUrl, in.ExternalIdApiName(), id)
 
 	resp = &SObjectResponse{}
-	err = forceApi.Patch(uri, nil, in.(interface{}), resp)
+	err = forceApi.PatchContext(ctx, uri, nil, in.(interface{}), resp)
 
 	return
 }
 
 func (forceApi *ForceApi) DeleteSObjectByExternalId(id string, in SObject) (err error) {
-	uri := fmt.Sprintf("%v/%v/%v", forceApi.apiSObjects[in.ApiName()].URLs[sObjectKey],
-		in.ExternalIdApiName(), id)
+	return forceApi.DeleteSObjectByExternalIdContext(context.Background(), id, in)
+}
+
+// DeleteSObjectByExternalIdContext is like DeleteSObjectByExternalId but the
+// request is bound to ctx.
+func (forceApi *ForceApi) DeleteSObjectByExternalIdContext(ctx context.Context, id string, in SObject) (err error) {
+	sObjectUrl, err := forceApi.sObjectUrl(in.ApiName(), sObjectKey)
+	if err != nil {
+		return
+	}
+	uri := fmt.Sprintf("%v/%v/%v", sObjectUrl, in.ExternalIdApiName(), id)
 
-	err = forceApi.Delete(uri, nil)
+	err = forceApi.DeleteContext(ctx, uri, nil)
 
 	return
 }
@@ -175,11 +175,10 @@ func TestBulkModifySObjects(t *testing.T) {
 	res := bulkInsertSObject(forceApi, t)
 	// test update
 	bulkUpdateSObject(forceApi, t, res)
-	// TODO complete tests!
-	//// test upsert
-	//res = bulkUpsertSObject(forceApi, t, res)
-	//// test delete
-	//bulkDeleteSObject(forceApi, t, res)
+	// test upsert
+	res = bulkUpsertSObject(forceApi, t, res)
+	// test delete
+	bulkDeleteSObject(forceApi, t, res)
 }
 
 func bulkInsertSObject(forceApi *ForceApi, t *testing.T) []*SObjectResponse {
@@ -222,44 +221,44 @@ func bulkUpdateSObject(forceApi *ForceApi, t *testing.T, res  []*SObjectResponse
 		t.Fatalf("Update SObject Account failed: %v", err)
 	}
 }
-//func bulkUpsertSObject(forceApi *ForceApi, t *testing.T, res  []*SObjectResponse) []*SObjectResponse {
-//	len := len(res)
-//	var accounts = make([]SObject, len + 1)
-//	for i := 0; i < len; i++ {
-//		// Need some random text for name field.
-//		someText := randomString(10)
-//
-//		acc := sobjects.Account{}
-//		acc.Id = res[i].Id
-//		acc.Name = someText + "_upsert"
-//		accounts[i] = acc
-//	}
-//	// Need some random text for name field.
-//	someText := randomString(10)
-//
-//	acc := sobjects.Account{}
-//	acc.Name = someText + "_upsert_insertion"
-//	accounts[len] = acc
-//
-//	upsertRes, err := forceApi.BulkUpsertSObjects(sobjects.Account{}.ApiName(), accounts)
-//
-//	if err != nil {
-//		t.Fatalf("Upsert SObject Account failed: %v", err)
-//	}
-//	return upsertRes
-//}
-//
-//func bulkDeleteSObject(forceApi *ForceApi, t *testing.T, res  []*SObjectResponse) {
-//	len := len(res)
-//	var accounts = make([]SObject, len)
-//	for i := 0; i < len; i++ {
-//		acc := sobjects.Account{}
-//		acc.Id = res[i].Id
-//		accounts[i] = acc
-//	}
-//	_, err := forceApi.BulkDeleteSObjects(sobjects.Account{}.ApiName(), accounts)
-//
-//	if err != nil {
-//		t.Fatalf("Delete SObject Account failed: %v", err)
-//	}
-//}
\ No newline at end of file
+func bulkUpsertSObject(forceApi *ForceApi, t *testing.T, res  []*SObjectResponse) []*SObjectResponse {
+	len := len(res)
+	var accounts = make([]SObject, len + 1)
+	for i := 0; i < len; i++ {
+		// Need some random text for name field.
+		someText := randomString(10)
+
+		acc := sobjects.Account{}
+		acc.Id = res[i].Id
+		acc.Name = someText + "_upsert"
+		accounts[i] = acc
+	}
+	// Need some random text for name field.
+	someText := randomString(10)
+
+	acc := sobjects.Account{}
+	acc.Name = someText + "_upsert_insertion"
+	accounts[len] = acc
+
+	upsertRes, err := forceApi.BulkUpsertSObjects(sobjects.Account{}.ApiName(), accounts, BulkExternalIdField("Id"))
+
+	if err != nil {
+		t.Fatalf("Upsert SObject Account failed: %v", err)
+	}
+	return upsertRes
+}
+
+func bulkDeleteSObject(forceApi *ForceApi, t *testing.T, res  []*SObjectResponse) {
+	len := len(res)
+	var accounts = make([]SObject, len)
+	for i := 0; i < len; i++ {
+		acc := sobjects.Account{}
+		acc.Id = res[i].Id
+		accounts[i] = acc
+	}
+	_, err := forceApi.BulkDeleteSObjects(sobjects.Account{}.ApiName(), accounts)
+
+	if err != nil {
+		t.Fatalf("Delete SObject Account failed: %v", err)
+	}
+}
\ No newline at end of file
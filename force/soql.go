@@ -0,0 +1,571 @@
+package force
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	soqlDateFormat     = "2006-01-02"
+	soqlDateTimeFormat = "2006-01-02T15:04:05Z"
+)
+
+var (
+	// A field path such as Name or Owner.Profile.Name, optionally wrapped in
+	// a function such as COUNT(Id), CALENDAR_YEAR(CreatedDate) or toLabel(Status).
+	soqlFieldPattern = regexp.MustCompile(`^(?:[A-Za-z][A-Za-z0-9_]*\((?:[A-Za-z][A-Za-z0-9_]*(?:\.[A-Za-z][A-Za-z0-9_]*)*)?\)|[A-Za-z][A-Za-z0-9_]*(?:\.[A-Za-z][A-Za-z0-9_]*)*)$`)
+	soqlNamePattern  = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+	soqlEscaper = strings.NewReplacer(
+		`\`, `\\`,
+		`'`, `\'`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+		"\b", `\b`,
+		"\f", `\f`,
+	)
+	soqlLikeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+)
+
+// EscapeSOQL escapes s for use inside a quoted SOQL string literal. Values
+// bound through the query builder are escaped already.
+func EscapeSOQL(s string) string {
+	return soqlEscaper.Replace(s)
+}
+
+// EscapeLike escapes the % and _ wildcards and backslashes in s, so that it
+// matches literally when used as part of a Like pattern.
+func EscapeLike(s string) string {
+	return soqlLikeEscaper.Replace(s)
+}
+
+// DateLiteral is a SOQL date literal such as TODAY or LAST_N_DAYS:30. It is
+// written into queries unquoted, so it must never hold untrusted input.
+type DateLiteral string
+
+const (
+	Yesterday         DateLiteral = "YESTERDAY"
+	Today             DateLiteral = "TODAY"
+	Tomorrow          DateLiteral = "TOMORROW"
+	LastWeek          DateLiteral = "LAST_WEEK"
+	ThisWeek          DateLiteral = "THIS_WEEK"
+	NextWeek          DateLiteral = "NEXT_WEEK"
+	LastMonth         DateLiteral = "LAST_MONTH"
+	ThisMonth         DateLiteral = "THIS_MONTH"
+	NextMonth         DateLiteral = "NEXT_MONTH"
+	Last90Days        DateLiteral = "LAST_90_DAYS"
+	Next90Days        DateLiteral = "NEXT_90_DAYS"
+	LastQuarter       DateLiteral = "LAST_QUARTER"
+	ThisQuarter       DateLiteral = "THIS_QUARTER"
+	NextQuarter       DateLiteral = "NEXT_QUARTER"
+	LastYear          DateLiteral = "LAST_YEAR"
+	ThisYear          DateLiteral = "THIS_YEAR"
+	NextYear          DateLiteral = "NEXT_YEAR"
+	LastFiscalQuarter DateLiteral = "LAST_FISCAL_QUARTER"
+	ThisFiscalQuarter DateLiteral = "THIS_FISCAL_QUARTER"
+	NextFiscalQuarter DateLiteral = "NEXT_FISCAL_QUARTER"
+	LastFiscalYear    DateLiteral = "LAST_FISCAL_YEAR"
+	ThisFiscalYear    DateLiteral = "THIS_FISCAL_YEAR"
+	NextFiscalYear    DateLiteral = "NEXT_FISCAL_YEAR"
+)
+
+func LastNDays(n int) DateLiteral   { return relativeDate("LAST_N_DAYS", n) }
+func NextNDays(n int) DateLiteral   { return relativeDate("NEXT_N_DAYS", n) }
+func NDaysAgo(n int) DateLiteral    { return relativeDate("N_DAYS_AGO", n) }
+func LastNWeeks(n int) DateLiteral  { return relativeDate("LAST_N_WEEKS", n) }
+func NextNWeeks(n int) DateLiteral  { return relativeDate("NEXT_N_WEEKS", n) }
+func LastNMonths(n int) DateLiteral { return relativeDate("LAST_N_MONTHS", n) }
+func NextNMonths(n int) DateLiteral { return relativeDate("NEXT_N_MONTHS", n) }
+func LastNYears(n int) DateLiteral  { return relativeDate("LAST_N_YEARS", n) }
+func NextNYears(n int) DateLiteral  { return relativeDate("NEXT_N_YEARS", n) }
+
+func relativeDate(name string, n int) DateLiteral {
+	return DateLiteral(name + ":" + strconv.Itoa(n))
+}
+
+// Date returns the date literal of t's calendar day, for comparing date
+// fields. A time.Time value bound directly is written as a UTC dateTime
+// literal instead.
+func Date(t time.Time) DateLiteral {
+	return DateLiteral(t.Format(soqlDateFormat))
+}
+
+// formatSOQLValue writes v as a SOQL literal.
+func formatSOQLValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return "'" + EscapeSOQL(v) + "'", nil
+	case DateLiteral:
+		return string(v), nil
+	case likePattern:
+		return v.soql(), nil
+	case time.Time:
+		return v.UTC().Format(soqlDateTimeFormat), nil
+	case *time.Time:
+		if v == nil {
+			return "null", nil
+		}
+		return v.UTC().Format(soqlDateTimeFormat), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	case reflect.String:
+		return "'" + EscapeSOQL(rv.String()) + "'", nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "null", nil
+		}
+		return formatSOQLValue(rv.Elem().Interface())
+	}
+
+	return "", fmt.Errorf("force: unable to write %T as a SOQL value", v)
+}
+
+func checkSOQLField(field string) error {
+	if !soqlFieldPattern.MatchString(field) {
+		return fmt.Errorf("force: invalid SOQL field %q", field)
+	}
+
+	return nil
+}
+
+// Condition is a WHERE or HAVING condition of a QueryBuilder.
+type Condition interface {
+	soql() (string, error)
+}
+
+// BuildCondition returns the SOQL of condition, e.g. for the where clause of
+// a SearchSObject.
+func BuildCondition(condition Condition) (string, error) {
+	if condition == nil {
+		return "", errors.New("force: nil condition")
+	}
+
+	return condition.soql()
+}
+
+type comparison struct {
+	field    string
+	operator string
+	value    interface{}
+}
+
+func (c comparison) soql() (string, error) {
+	if err := checkSOQLField(c.field); err != nil {
+		return "", err
+	}
+
+	value, err := formatSOQLValue(c.value)
+	if err != nil {
+		return "", err
+	}
+
+	return c.field + " " + c.operator + " " + value, nil
+}
+
+// Eq matches records whose field equals value. Strings are quoted and
+// escaped, time.Time values are written as dateTime literals, DateLiterals as
+// they are and nil as null.
+func Eq(field string, value interface{}) Condition { return comparison{field, "=", value} }
+func Ne(field string, value interface{}) Condition { return comparison{field, "!=", value} }
+func Lt(field string, value interface{}) Condition { return comparison{field, "<", value} }
+func Le(field string, value interface{}) Condition { return comparison{field, "<=", value} }
+func Gt(field string, value interface{}) Condition { return comparison{field, ">", value} }
+func Ge(field string, value interface{}) Condition { return comparison{field, ">=", value} }
+
+// Like matches string fields against pattern, in which % and _ are
+// wildcards and \%, \_ and \\ match a literal %, _ and backslash. Use EscapeLike
+// on parts of the pattern that must match literally.
+func Like(field, pattern string) Condition {
+	return comparison{field, "LIKE", likePattern(pattern)}
+}
+
+// likePattern is a Like pattern whose escaped wildcards survive formatting.
+type likePattern string
+
+func (p likePattern) soql() string {
+	var b strings.Builder
+	b.WriteString("'")
+	for i := 0; i < len(p); i++ {
+		if p[i] == '\\' && i+1 < len(p) && strings.IndexByte(`%_\`, p[i+1]) >= 0 {
+			b.WriteByte('\\')
+			b.WriteByte(p[i+1])
+			i++
+			continue
+		}
+		b.WriteString(EscapeSOQL(string(p[i : i+1])))
+	}
+	b.WriteString("'")
+
+	return b.String()
+}
+
+type setCondition struct {
+	field    string
+	operator string
+	values   []interface{}
+	query    *QueryBuilder
+}
+
+func (c setCondition) soql() (string, error) {
+	if err := checkSOQLField(c.field); err != nil {
+		return "", err
+	}
+
+	if c.query != nil {
+		query, err := c.query.Build()
+		if err != nil {
+			return "", err
+		}
+		return c.field + " " + c.operator + " (" + query + ")", nil
+	}
+
+	if len(c.values) == 0 {
+		return "", fmt.Errorf("force: empty %v list for %v", c.operator, c.field)
+	}
+
+	values := make([]string, len(c.values))
+	for i, v := range c.values {
+		value, err := formatSOQLValue(v)
+		if err != nil {
+			return "", err
+		}
+		values[i] = value
+	}
+
+	return c.field + " " + c.operator + " (" + strings.Join(values, ", ") + ")", nil
+}
+
+func anySlice[T any](values []T) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+
+	return out
+}
+
+// In matches records whose field equals one of values.
+func In[T any](field string, values []T) Condition {
+	return setCondition{field: field, operator: "IN", values: anySlice(values)}
+}
+
+// NotIn matches records whose field equals none of values.
+func NotIn[T any](field string, values []T) Condition {
+	return setCondition{field: field, operator: "NOT IN", values: anySlice(values)}
+}
+
+// InQuery matches records whose field is among the ids selected by query, a
+// semi-join such as Id IN (SELECT AccountId FROM Contact).
+func InQuery(field string, query *QueryBuilder) Condition {
+	return setCondition{field: field, operator: "IN", query: query}
+}
+
+// NotInQuery is the anti-join counterpart of InQuery.
+func NotInQuery(field string, query *QueryBuilder) Condition {
+	return setCondition{field: field, operator: "NOT IN", query: query}
+}
+
+// Includes matches multi-select picklist fields holding any of values. A
+// value may combine several picklist values with ";" to require all of them.
+func Includes(field string, values ...string) Condition {
+	return setCondition{field: field, operator: "INCLUDES", values: anySlice(values)}
+}
+
+// Excludes matches multi-select picklist fields holding none of values.
+func Excludes(field string, values ...string) Condition {
+	return setCondition{field: field, operator: "EXCLUDES", values: anySlice(values)}
+}
+
+type logicalCondition struct {
+	operator   string
+	conditions []Condition
+}
+
+func (c logicalCondition) soql() (string, error) {
+	if len(c.conditions) == 0 {
+		return "", fmt.Errorf("force: %v without conditions", c.operator)
+	}
+
+	parts := make([]string, len(c.conditions))
+	for i, condition := range c.conditions {
+		if condition == nil {
+			return "", fmt.Errorf("force: nil condition in %v", c.operator)
+		}
+
+		part, err := condition.soql()
+		if err != nil {
+			return "", err
+		}
+		if _, nested := condition.(logicalCondition); nested && len(c.conditions) > 1 {
+			part = "(" + part + ")"
+		}
+		parts[i] = part
+	}
+
+	return strings.Join(parts, " "+c.operator+" "), nil
+}
+
+// And matches records meeting all of conditions.
+func And(conditions ...Condition) Condition {
+	return logicalCondition{"AND", conditions}
+}
+
+// Or matches records meeting any of conditions.
+func Or(conditions ...Condition) Condition {
+	return logicalCondition{"OR", conditions}
+}
+
+type notCondition struct {
+	condition Condition
+}
+
+func (c notCondition) soql() (string, error) {
+	if c.condition == nil {
+		return "", errors.New("force: nil condition in NOT")
+	}
+
+	part, err := c.condition.soql()
+	if err != nil {
+		return "", err
+	}
+
+	return "NOT (" + part + ")", nil
+}
+
+// Not matches records not meeting condition.
+func Not(condition Condition) Condition {
+	return notCondition{condition}
+}
+
+// QueryBuilder builds SOQL queries whose values are escaped and formatted
+// according to their type, so that no input can change the shape of the
+// query:
+//
+//	soql, err := force.Select("Id", "Name").
+//		From("Account").
+//		Where(force.And(
+//			force.Eq("BillingCity", city),
+//			force.Gt("CreatedDate", force.LastNDays(30)),
+//		)).
+//		OrderBy("Name").
+//		Limit(100).
+//		Build()
+//
+// Field and object names are checked to be plain names, optionally wrapped in
+// a function such as COUNT(Id), and selected fields may carry an alias.
+// Errors surface from Build.
+type QueryBuilder struct {
+	fields    []string
+	from      string
+	where     Condition
+	groupBy   []string
+	having    Condition
+	orderBy   []string
+	limit     int
+	offset    int
+	forClause string
+	err       error
+}
+
+// Select starts a query selecting fields.
+func Select(fields ...string) *QueryBuilder {
+	return (&QueryBuilder{}).Select(fields...)
+}
+
+// Select adds fields to the SELECT list.
+func (b *QueryBuilder) Select(fields ...string) *QueryBuilder {
+	for _, field := range fields {
+		expression, alias, _ := strings.Cut(strings.TrimSpace(field), " ")
+		if err := checkSOQLField(expression); err != nil {
+			b.fail(err)
+		} else if alias != "" && !soqlNamePattern.MatchString(alias) {
+			b.fail(fmt.Errorf("force: invalid SOQL alias %q", alias))
+		}
+		b.fields = append(b.fields, strings.TrimSpace(field))
+	}
+
+	return b
+}
+
+// Subquery adds a parent-to-child relationship subquery, e.g. one built with
+// Select("Id", "Name").From("Contacts"), to the SELECT list.
+func (b *QueryBuilder) Subquery(query *QueryBuilder) *QueryBuilder {
+	soql, err := query.Build()
+	if err != nil {
+		b.fail(err)
+	}
+	b.fields = append(b.fields, "("+soql+")")
+
+	return b
+}
+
+// From sets the queried object, or the child relationship in a subquery.
+func (b *QueryBuilder) From(sobject string) *QueryBuilder {
+	if !soqlNamePattern.MatchString(sobject) {
+		b.fail(fmt.Errorf("force: invalid SOQL object name %q", sobject))
+	}
+	b.from = sobject
+
+	return b
+}
+
+// Where sets the WHERE condition. Calling it again combines the conditions
+// with AND.
+func (b *QueryBuilder) Where(condition Condition) *QueryBuilder {
+	if b.where != nil {
+		condition = And(b.where, condition)
+	}
+	b.where = condition
+
+	return b
+}
+
+// GroupBy adds fields to the GROUP BY clause.
+func (b *QueryBuilder) GroupBy(fields ...string) *QueryBuilder {
+	for _, field := range fields {
+		if err := checkSOQLField(field); err != nil {
+			b.fail(err)
+		}
+		b.groupBy = append(b.groupBy, field)
+	}
+
+	return b
+}
+
+// Having sets the HAVING condition of a grouped query.
+func (b *QueryBuilder) Having(condition Condition) *QueryBuilder {
+	b.having = condition
+
+	return b
+}
+
+// OrderBy adds field to the ORDER BY clause in ascending order.
+func (b *QueryBuilder) OrderBy(field string) *QueryBuilder {
+	return b.order(field, "ASC")
+}
+
+// OrderByDesc adds field to the ORDER BY clause in descending order.
+func (b *QueryBuilder) OrderByDesc(field string) *QueryBuilder {
+	return b.order(field, "DESC")
+}
+
+func (b *QueryBuilder) order(field, direction string) *QueryBuilder {
+	if err := checkSOQLField(field); err != nil {
+		b.fail(err)
+	}
+	b.orderBy = append(b.orderBy, field+" "+direction)
+
+	return b
+}
+
+// Limit caps the number of records returned.
+func (b *QueryBuilder) Limit(n int) *QueryBuilder {
+	if n <= 0 {
+		b.fail(fmt.Errorf("force: invalid SOQL limit %v", n))
+	}
+	b.limit = n
+
+	return b
+}
+
+// Offset skips the first n records.
+func (b *QueryBuilder) Offset(n int) *QueryBuilder {
+	if n < 0 {
+		b.fail(fmt.Errorf("force: invalid SOQL offset %v", n))
+	}
+	b.offset = n
+
+	return b
+}
+
+// ForView updates the last viewed date of the returned records.
+func (b *QueryBuilder) ForView() *QueryBuilder {
+	b.forClause = "FOR VIEW"
+	return b
+}
+
+// ForReference updates the last referenced date of the returned records.
+func (b *QueryBuilder) ForReference() *QueryBuilder {
+	b.forClause = "FOR REFERENCE"
+	return b
+}
+
+// ForUpdate locks the returned records for the duration of the transaction.
+func (b *QueryBuilder) ForUpdate() *QueryBuilder {
+	b.forClause = "FOR UPDATE"
+	return b
+}
+
+func (b *QueryBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Build returns the SOQL query, or the first error found while building it.
+func (b *QueryBuilder) Build() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	if len(b.fields) == 0 {
+		return "", errors.New("force: SOQL query without fields")
+	}
+	if b.from == "" {
+		return "", errors.New("force: SOQL query without FROM")
+	}
+
+	query := fmt.Sprintf(BaseQueryString, strings.Join(b.fields, ", "), b.from)
+
+	if b.where != nil {
+		where, err := b.where.soql()
+		if err != nil {
+			return "", err
+		}
+		query += " WHERE " + where
+	}
+	if len(b.groupBy) > 0 {
+		query += " GROUP BY " + strings.Join(b.groupBy, ", ")
+	}
+	if b.having != nil {
+		having, err := b.having.soql()
+		if err != nil {
+			return "", err
+		}
+		query += " HAVING " + having
+	}
+	if len(b.orderBy) > 0 {
+		query += " ORDER BY " + strings.Join(b.orderBy, ", ")
+	}
+	if b.limit > 0 {
+		query += " LIMIT " + strconv.Itoa(b.limit)
+	}
+	if b.offset > 0 {
+		query += " OFFSET " + strconv.Itoa(b.offset)
+	}
+	if b.forClause != "" {
+		query += " " + b.forClause
+	}
+
+	return query, nil
+}
@@ -0,0 +1,258 @@
+package force
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nimajalali/go-force/forcejson"
+)
+
+// SOQL follows at most five levels of parent relationships.
+const maxRelationshipDepth = 5
+
+var (
+	structFieldsCache sync.Map // reflect.Type -> []string
+
+	timeType               = reflect.TypeOf(time.Time{})
+	jsonUnmarshalerType    = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	forceUnmarshalerType   = reflect.TypeOf((*forcejson.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType    = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	errNotStructForSelect  = errors.New("force: StructFields needs a struct or a pointer to a struct")
+	errStructFieldsMissing = errors.New("force: struct has no fields to select")
+)
+
+// StructFields returns the SOQL names of the fields of v, a struct or pointer
+// to a struct, following the same force tags used to decode query results:
+//
+//   - a field is named by its force tag, or by its Go name if the tag has
+//     none, and skipped if the tag is "-" or it is unexported
+//   - the fields of embedded structs such as sobjects.BaseSObject are
+//     included as if they were declared in v, except for attributes
+//   - a field holding a struct or pointer to a struct is a parent
+//     relationship whose fields are selected with its name as prefix, e.g.
+//     Owner.Name
+//   - a field holding records of a child relationship, i.e. a struct with a
+//     slice tagged force:"records" such as sobjects.ChildRecords, is selected
+//     as a subquery from the relationship, e.g. (SELECT Id FROM Contacts)
+//
+// Structs that decode themselves, such as time.Time, are selected as single
+// fields. A field selected twice, e.g. because both v and an embedded struct
+// declare it, is listed once.
+func StructFields(v interface{}) ([]string, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errNotStructForSelect
+	}
+
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return append([]string(nil), fields.([]string)...), nil
+	}
+
+	w := newStructFieldsWalker(0)
+	if err := w.walk(t, "", 0); err != nil {
+		return nil, err
+	}
+	if len(w.fields) == 0 {
+		return nil, errStructFieldsMissing
+	}
+
+	structFieldsCache.Store(t, w.fields)
+	return append([]string(nil), w.fields...), nil
+}
+
+// SelectStruct starts a query selecting the fields of v as listed by
+// StructFields. If v is an SObject the query is made from its ApiName, so
+//
+//	soql, err := force.SelectStruct(&sobjects.Account{}).Where(...).Build()
+//
+// selects exactly what decoding into sobjects.Account can use.
+func SelectStruct(v interface{}) *QueryBuilder {
+	b := (&QueryBuilder{}).SelectStruct(v)
+	if sobject, ok := v.(SObject); ok {
+		b.From(sobject.ApiName())
+	}
+
+	return b
+}
+
+// SelectStruct adds the fields of v, as listed by StructFields, to the SELECT
+// list.
+func (b *QueryBuilder) SelectStruct(v interface{}) *QueryBuilder {
+	fields, err := StructFields(v)
+	if err != nil {
+		b.fail(err)
+		return b
+	}
+
+	// The walker checked the names already and subqueries would not pass
+	// the checks of Select.
+	b.fields = append(b.fields, fields...)
+	return b
+}
+
+type structFieldsWalker struct {
+	fields []string
+	seen   map[string]bool
+
+	// level counts the subqueries the walked struct is nested in.
+	level int
+
+	// The relationships of the walked struct itself, as opposed to those of
+	// its parents and children.
+	parentRelationships []string
+	childRelationships  []string
+}
+
+func newStructFieldsWalker(level int) *structFieldsWalker {
+	return &structFieldsWalker{seen: make(map[string]bool), level: level}
+}
+
+func (w *structFieldsWalker) walk(t reflect.Type, prefix string, depth int) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		tag := sf.Tag.Get("force")
+		if tag == "-" {
+			continue
+		}
+		name := strings.SplitN(tag, ",", 2)[0]
+		if name == "attributes" {
+			continue
+		}
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if sf.Anonymous && name == "" && isRelationshipType(ft) {
+			if err := w.walk(ft, prefix, depth); err != nil {
+				return err
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		if elem, ok := childRecordsType(ft); ok {
+			if prefix != "" {
+				return fmt.Errorf("force: child relationship %v%v is not selectable through a parent relationship", prefix, name)
+			}
+			if !soqlNamePattern.MatchString(name) {
+				return fmt.Errorf("force: invalid SOQL relationship %q", name)
+			}
+			if err := w.subquery(elem, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch {
+		case isRelationshipType(ft):
+			if !soqlNamePattern.MatchString(name) {
+				return fmt.Errorf("force: invalid SOQL relationship %q", name)
+			}
+			if prefix == "" {
+				w.parentRelationships = append(w.parentRelationships, name)
+			}
+			if depth+1 > maxRelationshipDepth {
+				return fmt.Errorf("force: relationship %v%v is nested deeper than %v levels", prefix, name, maxRelationshipDepth)
+			}
+			if err := w.walk(ft, prefix+name+".", depth+1); err != nil {
+				return err
+			}
+		case ft.Kind() == reflect.Slice || ft.Kind() == reflect.Map:
+			// Child relationships are not fields of the queried object.
+		default:
+			if err := checkSOQLField(name); err != nil {
+				return err
+			}
+			w.add(prefix + name)
+		}
+	}
+
+	return nil
+}
+
+// subquery selects the fields of elem from the child relationship name.
+func (w *structFieldsWalker) subquery(elem reflect.Type, name string) error {
+	if w.level+1 > maxRelationshipDepth {
+		return fmt.Errorf("force: subquery %v is nested deeper than %v levels", name, maxRelationshipDepth)
+	}
+
+	sub := newStructFieldsWalker(w.level + 1)
+	if err := sub.walk(elem, "", 0); err != nil {
+		return err
+	}
+	if len(sub.fields) == 0 {
+		return fmt.Errorf("force: child relationship %v has no fields to select", name)
+	}
+
+	w.childRelationships = append(w.childRelationships, name)
+	w.add("(SELECT " + strings.Join(sub.fields, ", ") + " FROM " + name + ")")
+	return nil
+}
+
+func (w *structFieldsWalker) add(field string) {
+	key := strings.ToLower(field)
+	if w.seen[key] {
+		return
+	}
+
+	w.seen[key] = true
+	w.fields = append(w.fields, field)
+}
+
+// childRecordsType reports whether t holds the records of a child
+// relationship and returns their type.
+func childRecordsType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if strings.SplitN(sf.Tag.Get("force"), ",", 2)[0] == "records" && sf.Type.Kind() == reflect.Slice {
+			elem := sf.Type.Elem()
+			if elem.Kind() == reflect.Ptr {
+				elem = elem.Elem()
+			}
+			return elem, elem.Kind() == reflect.Struct
+		}
+	}
+
+	return nil, false
+}
+
+// isRelationshipType reports whether fields of type t are walked into rather
+// than selected.
+func isRelationshipType(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+
+	pt := reflect.PointerTo(t)
+	for _, unmarshaler := range []reflect.Type{jsonUnmarshalerType, forceUnmarshalerType, textUnmarshalerType} {
+		if t.Implements(unmarshaler) || pt.Implements(unmarshaler) {
+			return false
+		}
+	}
+
+	return true
+}
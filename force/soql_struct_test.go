@@ -0,0 +1,89 @@
+package force
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+type testUserRef struct {
+	Name  string
+	Email string `force:",omitempty"`
+}
+
+type testAccountRef struct {
+	Name  string
+	Owner *testUserRef
+}
+
+type testContact struct {
+	sobjects.BaseSObject
+	Email       string    `force:"Email,omitempty"`
+	Active      bool      `force:"Active__c"`
+	Birthdate   time.Time `force:",omitempty"`
+	Account     testAccountRef
+	Score       sobjects.SFBool
+	Tags        []string
+	Ignored     string `force:"-"`
+	unexported  string
+	LastUpdated *time.Time
+}
+
+func (c *testContact) ApiName() string {
+	return "Contact"
+}
+
+func TestStructFields(t *testing.T) {
+	fields, err := StructFields(&testContact{})
+	if err != nil {
+		t.Fatalf("StructFields failed: %v", err)
+	}
+
+	expected := "Id,IsDeleted,Name,CreatedDate,CreatedById,LastModifiedDate,LastModifiedById,SystemModstamp," +
+		"Email,Active__c,Birthdate,Account.Name,Account.Owner.Name,Account.Owner.Email,Score,LastUpdated"
+	if strings.Join(fields, ",") != expected {
+		t.Fatalf("Expected:\n%v\ngot:\n%v", expected, strings.Join(fields, ","))
+	}
+
+	// Fields declared both by the struct and the embedded BaseSObject are
+	// selected once.
+	fields, err = StructFields(sobjects.Opportunity{})
+	if err != nil {
+		t.Fatalf("StructFields failed: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, field := range fields {
+		if seen[field] {
+			t.Fatalf("Field %v selected twice in %v", field, fields)
+		}
+		seen[field] = true
+	}
+
+	if _, err := StructFields("Account"); err == nil {
+		t.Fatal("Expected an error for a non-struct")
+	}
+}
+
+func TestSelectStruct(t *testing.T) {
+	query, err := SelectStruct(&testContact{}).Where(Eq("Email", "a@example.com")).Build()
+	if err != nil {
+		t.Fatalf("SelectStruct failed: %v", err)
+	}
+	if !strings.HasPrefix(query, "SELECT Id, IsDeleted, Name,") ||
+		!strings.HasSuffix(query, ", Score, LastUpdated FROM Contact WHERE Email = 'a@example.com'") {
+		t.Fatalf("Unexpected query %v", query)
+	}
+}
+
+type testDeepRef struct {
+	Name   string
+	Parent *testDeepRef
+}
+
+func TestStructFieldsDepthLimit(t *testing.T) {
+	if _, err := StructFields(testDeepRef{}); err == nil {
+		t.Fatal("Expected self referencing relationships to hit the depth limit")
+	}
+}
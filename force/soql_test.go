@@ -0,0 +1,71 @@
+package force
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	queries := map[string]*QueryBuilder{
+		"SELECT Id, Name FROM Account WHERE Name = 'O\\'Brien\\\\ \\n' LIMIT 10": Select("Id", "Name").From("Account").
+			Where(Eq("Name", "O'Brien\\ \n")).
+			Limit(10),
+
+		"SELECT Id FROM Account WHERE Industry IN ('Tech', 'Media') AND (Rating = null OR NumberOfEmployees >= 50) ORDER BY Name ASC, CreatedDate DESC LIMIT 5 OFFSET 10": Select("Id").From("Account").
+			Where(In("Industry", []string{"Tech", "Media"})).
+			Where(Or(Eq("Rating", nil), Ge("NumberOfEmployees", 50))).
+			OrderBy("Name").OrderByDesc("CreatedDate").
+			Limit(5).Offset(10),
+
+		"SELECT Id FROM Opportunity WHERE CreatedDate > 2024-03-01T08:30:00Z AND CloseDate < 2024-03-01 AND LastModifiedDate = LAST_N_DAYS:30 AND IsWon != true": Select("Id").From("Opportunity").
+			Where(And(
+				Gt("CreatedDate", created),
+				Lt("CloseDate", Date(created)),
+				Eq("LastModifiedDate", LastNDays(30)),
+				Ne("IsWon", true),
+			)),
+
+		"SELECT StageName, COUNT(Id) total FROM Opportunity GROUP BY StageName HAVING COUNT(Id) > 1": Select("StageName", "COUNT(Id) total").From("Opportunity").
+			GroupBy("StageName").
+			Having(Gt("COUNT(Id)", 1)),
+
+		"SELECT Id, (SELECT Id, Email FROM Contacts) FROM Account WHERE Id IN (SELECT AccountId FROM Opportunity) AND NOT (Name LIKE '\\%\\_off\\'%') FOR UPDATE": Select("Id").Subquery(Select("Id", "Email").From("Contacts")).From("Account").
+			Where(InQuery("Id", Select("AccountId").From("Opportunity"))).
+			Where(Not(Like("Name", EscapeLike("%_off'")+"%"))).
+			ForUpdate(),
+
+		"SELECT Id FROM Contact WHERE Languages__c INCLUDES ('en;fr', 'de') AND Owner.Name NOT IN ('x')": Select("Id").From("Contact").
+			Where(Includes("Languages__c", "en;fr", "de")).
+			Where(NotIn("Owner.Name", []string{"x"})),
+	}
+
+	for expected, builder := range queries {
+		query, err := builder.Build()
+		if err != nil {
+			t.Fatalf("Unable to build %q: %v", expected, err)
+		}
+		if query != expected {
+			t.Fatalf("Expected:\n%v\ngot:\n%v", expected, query)
+		}
+	}
+}
+
+func TestQueryBuilderRejectsInjection(t *testing.T) {
+	builders := []*QueryBuilder{
+		Select("Id FROM User --").From("Account"),
+		Select("Id").From("Account WHERE Name != null"),
+		Select("Id").From("Account").Where(Eq("Name = 'x' OR Name", "y")),
+		Select("Id").From("Account").OrderBy("Name; DELETE"),
+		Select("Id").From("Account").Where(In("Id", []string{})),
+		Select("Id").From("Account").Where(Eq("Name", struct{}{})),
+		Select("Id"),
+	}
+
+	for _, builder := range builders {
+		if query, err := builder.Build(); err == nil {
+			t.Fatalf("Expected an error, got %q", query)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package force
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sessionLifetime is how long an issued token is assumed to stay valid: the
+// default session timeout of an org. Tokens rejected before then are still
+// refreshed when a request fails.
+const sessionLifetime = 2 * time.Hour
+
+// Token is an OAuth access token along with what is needed to use and renew
+// it. Its shape follows golang.org/x/oauth2.Token so the two convert field by
+// field.
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry"`
+	InstanceUrl  string    `json:"instance_url"`
+	Id           string    `json:"id,omitempty"`
+	IssuedAt     string    `json:"issued_at,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+}
+
+// Valid reports whether t has an access token and instance url and, if its
+// expiry is known, has not expired yet.
+func (t *Token) Valid() bool {
+	if t == nil || t.AccessToken == "" || t.InstanceUrl == "" {
+		return false
+	}
+
+	return t.Expiry.IsZero() || time.Now().Before(t.Expiry)
+}
+
+// TokenSource supplies tokens, mirroring golang.org/x/oauth2.TokenSource.
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+// TokenSourceFunc adapts a function, e.g. one wrapping an oauth2.TokenSource,
+// to a TokenSource.
+type TokenSourceFunc func() (*Token, error)
+
+func (f TokenSourceFunc) Token() (*Token, error) {
+	return f()
+}
+
+// TokenStore persists tokens across process restarts. Load returns a nil
+// token and no error when nothing has been saved yet.
+type TokenStore interface {
+	Load() (*Token, error)
+	Save(token *Token) error
+}
+
+// WithTokenSource makes the ForceApi take its access tokens from source, both
+// when it is created and whenever the current token expires, instead of
+// running an OAuth flow itself.
+func WithTokenSource(source TokenSource) Option {
+	return func(forceApi *ForceApi) error {
+		if source == nil {
+			return errors.New("force: nil token source")
+		}
+
+		forceApi.OAuth.tokenSource = source
+		return nil
+	}
+}
+
+// WithTokenStore makes the ForceApi start from the token in store, if there
+// is a valid one, and save every token it is issued or refreshed into store.
+// The configured OAuth flow is only run when the store is empty or its token
+// has expired or has no expiry. Issued tokens are saved with an expiry two
+// hours after they were issued, the default session timeout.
+func WithTokenStore(store TokenStore) Option {
+	return func(forceApi *ForceApi) error {
+		if store == nil {
+			return errors.New("force: nil token store")
+		}
+
+		forceApi.OAuth.tokenStore = store
+		return nil
+	}
+}
+
+// TokenSource returns a TokenSource yielding the ForceApi's current token.
+func (forceApi *ForceApi) TokenSource() TokenSource {
+	return TokenSourceFunc(func() (*Token, error) {
+		if err := forceApi.OAuth.Validate(); err != nil {
+			return nil, err
+		}
+
+		return forceApi.OAuth.Token(), nil
+	})
+}
+
+// Token returns a copy of the current token.
+func (oauth *ForceOauth) Token() *Token {
+	oauth.mu.RLock()
+	defer oauth.mu.RUnlock()
+
+	return &Token{
+		AccessToken:  oauth.AccessToken,
+		TokenType:    oauth.TokenType,
+		RefreshToken: oauth.RefreshToken,
+		Expiry:       oauth.expiry,
+		InstanceUrl:  oauth.InstanceUrl,
+		Id:           oauth.Id,
+		IssuedAt:     oauth.IssuedAt,
+		Scope:        oauth.Scope,
+	}
+}
+
+func (oauth *ForceOauth) setToken(token *Token) {
+	oauth.mu.Lock()
+	defer oauth.mu.Unlock()
+
+	oauth.AccessToken = token.AccessToken
+	oauth.TokenType = token.TokenType
+	oauth.InstanceUrl = token.InstanceUrl
+	oauth.Id = token.Id
+	oauth.IssuedAt = token.IssuedAt
+	oauth.Scope = token.Scope
+	oauth.expiry = token.Expiry
+	if token.RefreshToken != "" {
+		oauth.RefreshToken = token.RefreshToken
+	}
+}
+
+// tokenIssued records when the current token expires and saves it to the
+// token store, if any. It is called after every successful grant.
+func (oauth *ForceOauth) tokenIssued() error {
+	oauth.mu.Lock()
+	oauth.expiry = tokenExpiry(oauth.IssuedAt, time.Now())
+	oauth.mu.Unlock()
+
+	return oauth.saveToken()
+}
+
+// tokenExpiry returns the expiry of a token issued at issuedAt, the
+// milliseconds since the epoch force.com reports, or at now if issuedAt is
+// missing.
+func tokenExpiry(issuedAt string, now time.Time) time.Time {
+	if ms, err := strconv.ParseInt(issuedAt, 10, 64); err == nil && ms > 0 {
+		now = time.UnixMilli(ms)
+	}
+
+	return now.Add(sessionLifetime)
+}
+
+// saveToken saves the current token to the token store, if any.
+func (oauth *ForceOauth) saveToken() error {
+	if oauth.tokenStore == nil {
+		return nil
+	}
+
+	if err := oauth.tokenStore.Save(oauth.Token()); err != nil {
+		return fmt.Errorf("force: unable to save token: %v", err)
+	}
+
+	return nil
+}
+
+// loadStoredToken adopts a valid token from the token store and reports
+// whether it found one. A token saved without an expiry cannot be told from
+// an expired one, so it is renewed instead.
+func (oauth *ForceOauth) loadStoredToken() (bool, error) {
+	if oauth.tokenStore == nil {
+		return false, nil
+	}
+
+	token, err := oauth.tokenStore.Load()
+	if err != nil {
+		return false, fmt.Errorf("force: unable to load token: %v", err)
+	}
+	if !token.Valid() || token.Expiry.IsZero() {
+		return false, nil
+	}
+
+	oauth.setToken(token)
+	return true, nil
+}
+
+// tokenFromSource replaces the current token with the next one from the
+// token source.
+func (oauth *ForceOauth) tokenFromSource() error {
+	token, err := oauth.tokenSource.Token()
+	if err != nil {
+		return fmt.Errorf("force: unable to get token from source: %v", err)
+	}
+	if !token.Valid() {
+		return errors.New("force: token source returned an invalid token")
+	}
+
+	oauth.setToken(token)
+	return oauth.saveToken()
+}
+
+// MemoryTokenStore keeps a token in memory. It is safe for concurrent use.
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token *Token
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{}
+}
+
+func (s *MemoryTokenStore) Load() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == nil {
+		return nil, nil
+	}
+
+	token := *s.token
+	return &token, nil
+}
+
+func (s *MemoryTokenStore) Save(token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *token
+	s.token = &saved
+	return nil
+}
+
+// FileTokenStore keeps a token as JSON in a file readable only by its owner.
+// Saves replace the file atomically. It is safe for concurrent use within a
+// process.
+type FileTokenStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{Path: path}
+}
+
+func (s *FileTokenStore) Load() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil, fmt.Errorf("malformed token file %v: %v", s.Path, err)
+	}
+
+	return token, nil
+}
+
+func (s *FileTokenStore) Save(token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.Path)
+}
@@ -0,0 +1,149 @@
+package force
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileTokenStore(t *testing.T) {
+	store := NewFileTokenStore(filepath.Join(t.TempDir(), "token.json"))
+
+	token, err := store.Load()
+	if err != nil || token != nil {
+		t.Fatalf("Expected no token from an empty store, got %v, %v", token, err)
+	}
+
+	saved := &Token{AccessToken: "token", InstanceUrl: "https://na1.salesforce.com", RefreshToken: "refresh"}
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("Unable to save token: %v", err)
+	}
+
+	token, err = store.Load()
+	if err != nil {
+		t.Fatalf("Unable to load token: %v", err)
+	}
+	if *token != *saved {
+		t.Fatalf("Expected %+v, got %+v", saved, token)
+	}
+}
+
+func TestTokenValid(t *testing.T) {
+	tokens := map[*Token]bool{
+		nil:                    false,
+		{AccessToken: "token"}: false,
+		{AccessToken: "token", InstanceUrl: "https://na1.salesforce.com"}:                                       true,
+		{AccessToken: "token", InstanceUrl: "https://na1.salesforce.com", Expiry: time.Now().Add(-time.Minute)}: false,
+	}
+	for token, valid := range tokens {
+		if token.Valid() != valid {
+			t.Fatalf("Expected Valid() of %+v to be %v", token, valid)
+		}
+	}
+}
+
+func TestTokenStoreSurvivesRestart(t *testing.T) {
+	org := newFakeOrg(t)
+	org.HandleJSON("/services/data/v36.0/query/", func(r *http.Request) interface{} {
+		if r.Header.Get("Authorization") != "Bearer token2" {
+			return ApiErrors{{ErrorCode: invalidSessionErrorCode}}
+		}
+		return map[string]interface{}{"totalSize": 0, "done": true}
+	})
+	store := NewMemoryTokenStore()
+
+	forceApi := org.New(t, WithTokenStore(store))
+	if token, _ := store.Load(); token == nil || token.AccessToken != "token1" || time.Until(token.Expiry) < time.Hour {
+		t.Fatalf("Expected the issued token to be saved with its expiry, got %+v", token)
+	}
+
+	// The second process starts from the saved token.
+	forceApi = org.New(t, WithTokenStore(store))
+	if org.tokenRequests != 1 {
+		t.Fatalf("Expected the stored token to be reused, got %v token requests", org.tokenRequests)
+	}
+
+	var out map[string]interface{}
+	if err := forceApi.Query("SELECT Id FROM Account", &out); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if token, _ := store.Load(); token.AccessToken != "token2" {
+		t.Fatalf("Expected the new token to be saved, got %+v", token)
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	org := newFakeOrg(t)
+
+	calls := 0
+	source := TokenSourceFunc(func() (*Token, error) {
+		calls++
+		return &Token{AccessToken: "external", InstanceUrl: org.URL}, nil
+	})
+
+	forceApi, err := New(WithTokenSource(source))
+	if err != nil {
+		t.Fatalf("Unable to create ForceApi from token source: %v", err)
+	}
+	if calls != 1 || org.tokenRequests != 0 {
+		t.Fatalf("Expected one token source call and no token requests, got %v and %v", calls, org.tokenRequests)
+	}
+
+	token, err := forceApi.TokenSource().Token()
+	if err != nil || token.AccessToken != "external" {
+		t.Fatalf("Expected the current token, got %+v, %v", token, err)
+	}
+}
+
+func TestStoredTokenWithoutExpiry(t *testing.T) {
+	org := newFakeOrg(t)
+	store := NewMemoryTokenStore()
+	store.Save(&Token{AccessToken: "stale", InstanceUrl: org.URL})
+
+	forceApi := org.New(t, WithTokenStore(store))
+	if org.tokenRequests != 1 || forceApi.GetAccessToken() != "token1" {
+		t.Fatalf("Expected a token without expiry to be renewed, got %v token requests", org.tokenRequests)
+	}
+	if token, _ := store.Load(); token.AccessToken != "token1" || token.Expiry.IsZero() {
+		t.Fatalf("Expected the renewed token to be saved, got %+v", token)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	now := time.Now()
+	issued := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	if expiry := tokenExpiry(fmt.Sprint(issued.UnixMilli()), now); !expiry.Equal(issued.Add(sessionLifetime)) {
+		t.Fatalf("Expected the expiry to follow issued_at, got %v", expiry)
+	}
+	if expiry := tokenExpiry("", now); !expiry.Equal(now.Add(sessionLifetime)) {
+		t.Fatalf("Expected the expiry to follow now without issued_at, got %v", expiry)
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	org := newFakeOrg(t)
+	var sent []string
+	org.OnToken = func(r *http.Request) *ApiError {
+		sent = append(sent, r.FormValue("refresh_token"))
+		return nil
+	}
+	store := NewMemoryTokenStore()
+
+	forceApi := org.New(t, WithRefreshToken("refresh0"), WithTokenStore(store))
+	if token, _ := store.Load(); token == nil || token.RefreshToken != "refresh1" {
+		t.Fatalf("Expected the rotated refresh token to be saved, got %+v", token)
+	}
+
+	if err := forceApi.OAuth.RefreshAccessToken(); err != nil {
+		t.Fatalf("RefreshAccessToken failed: %v", err)
+	}
+	if strings.Join(sent, ",") != "refresh0,refresh1" {
+		t.Fatalf("Expected each refresh to send the latest refresh token, got %v", sent)
+	}
+	if token, _ := store.Load(); token.RefreshToken != "refresh2" {
+		t.Fatalf("Expected the rotated refresh token to be saved, got %+v", token)
+	}
+}
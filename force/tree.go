@@ -0,0 +1,242 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Limits of the sobject tree resource.
+	maxTreeRecords = 200
+	maxTreeDepth   = 5
+)
+
+// SObjectTreeResponse is the outcome of inserting a tree of records. If
+// HasErrors is set nothing was inserted and the results list the errors.
+type SObjectTreeResponse struct {
+	HasErrors bool                 `json:"hasErrors"`
+	Results   []*SObjectTreeResult `json:"results"`
+}
+
+// SObjectTreeResult is the outcome of a record of a tree.
+type SObjectTreeResult struct {
+	ReferenceId string         `json:"referenceId"`
+	Id          string         `json:"id,omitempty"`
+	Errors      []SObjectError `json:"errors,omitempty"`
+}
+
+// InsertSObjectTree inserts the records in, which must be of the same
+// sobject, together with their children in a single call. The children of a
+// record are those in its fields holding records of a child relationship,
+// such as
+//
+//	Contacts sobjects.ChildRecords[*Contact] `force:"Contacts" json:"-"`
+//
+// and their own children in turn, up to five levels and 200 records in all.
+// Either every record is inserted and gets its id set with SetID, or none is
+// and HasErrors of the response is set. Records must be pointers to structs
+// whose SetID stores the id, such as those embedding sobjects.BaseSObject.
+func (forceApi *ForceApi) InsertSObjectTree(in []SObject) (*SObjectTreeResponse, error) {
+	return forceApi.InsertSObjectTreeContext(context.Background(), in)
+}
+
+// InsertSObjectTreeContext is like InsertSObjectTree but the request is bound
+// to ctx.
+func (forceApi *ForceApi) InsertSObjectTreeContext(ctx context.Context, in []SObject) (*SObjectTreeResponse, error) {
+	if len(in) == 0 {
+		return nil, errors.New("force: sobject tree without records")
+	}
+
+	name := in[0].ApiName()
+	tree := &sObjectTree{refs: make(map[string]SObject)}
+	payload := struct {
+		Records []json.RawMessage `json:"records"`
+	}{}
+	for _, record := range in {
+		if record.ApiName() != name {
+			return nil, fmt.Errorf("force: sobject tree records must all be %v", name)
+		}
+		data, err := tree.record(record, 1)
+		if err != nil {
+			return nil, err
+		}
+		payload.Records = append(payload.Records, data)
+	}
+
+	uri, err := forceApi.compositeUri("/tree/" + name)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &SObjectTreeResponse{}
+	if err := forceApi.PostContext(ctx, uri, nil, payload, resp); err != nil {
+		return nil, err
+	}
+
+	if !resp.HasErrors {
+		for _, result := range resp.Results {
+			if record, ok := tree.refs[result.ReferenceId]; ok && result.Id != "" {
+				record.SetID(result.Id)
+			}
+		}
+	}
+
+	return resp, nil
+}
+
+// sObjectTree encodes records with their children and keeps track of the
+// reference ids given to them.
+type sObjectTree struct {
+	refs map[string]SObject
+}
+
+// record returns the JSON of in with its attributes and children.
+func (tree *sObjectTree) record(in SObject, depth int) (json.RawMessage, error) {
+	if depth > maxTreeDepth {
+		return nil, fmt.Errorf("force: sobject tree is nested deeper than %v levels", maxTreeDepth)
+	}
+	if len(tree.refs) == maxTreeRecords {
+		return nil, fmt.Errorf("force: sobject tree has more than %v records", maxTreeRecords)
+	}
+	// SetID on a copy would lose the id.
+	v := reflect.ValueOf(in)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, fmt.Errorf("force: %v record %T is not a pointer to a struct", in.ApiName(), in)
+	}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshaling encoded payload: %v", err)
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("force: %v record is not a JSON object: %v", in.ApiName(), err)
+	}
+
+	ref := "ref" + strconv.Itoa(len(tree.refs)+1)
+	tree.refs[ref] = in
+	if fields["attributes"], err = json.Marshal(map[string]string{"type": in.ApiName(), "referenceId": ref}); err != nil {
+		return nil, err
+	}
+
+	if v = v.Elem(); v.Kind() == reflect.Struct {
+		if err := tree.children(v, fields, depth); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+// children adds the child records held by the fields of v, a struct, to
+// fields.
+func (tree *sObjectTree) children(v reflect.Value, fields map[string]json.RawMessage, depth int) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("force")
+		if tag == "-" || (sf.PkgPath != "" && !sf.Anonymous) {
+			continue
+		}
+		name := strings.SplitN(tag, ",", 2)[0]
+
+		fv := v.Field(i)
+		if sf.Anonymous && name == "" && isRelationshipType(indirectType(sf.Type)) {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if err := tree.children(fv, fields, depth); err != nil {
+				return err
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if _, ok := childRecordsType(indirectType(sf.Type)); !ok {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		// The field is only encoded if its json tag was forgotten.
+		delete(fields, sf.Name)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		records := childRecordsValue(fv)
+		if records.Len() == 0 {
+			continue
+		}
+
+		children := struct {
+			Records []json.RawMessage `json:"records"`
+		}{}
+		for j := 0; j < records.Len(); j++ {
+			child, ok := sObjectOf(records.Index(j))
+			if !ok {
+				return fmt.Errorf("force: child record %v of %v is not an SObject", j, name)
+			}
+			data, err := tree.record(child, depth+1)
+			if err != nil {
+				return err
+			}
+			children.Records = append(children.Records, data)
+		}
+
+		data, err := json.Marshal(children)
+		if err != nil {
+			return err
+		}
+		fields[name] = data
+	}
+
+	return nil
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+
+	return t
+}
+
+// childRecordsValue returns the slice of records held by v, a struct whose
+// type childRecordsType accepts.
+func childRecordsValue(v reflect.Value) reflect.Value {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.SplitN(t.Field(i).Tag.Get("force"), ",", 2)[0] == "records" {
+			return v.Field(i)
+		}
+	}
+
+	return reflect.Value{}
+}
+
+// sObjectOf returns the SObject held by an element of a slice of records,
+// taking the address of struct elements so that SetID reaches them.
+func sObjectOf(v reflect.Value) (SObject, bool) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, false
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		v = v.Addr()
+	}
+
+	sobject, ok := v.Interface().(SObject)
+	return sobject, ok
+}
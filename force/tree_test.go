@@ -0,0 +1,144 @@
+package force
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+type testTreeAccount struct {
+	sobjects.BaseSObject
+	Contacts      sobjects.ChildRecords[*testTreeContact]    `force:"Contacts" json:"-"`
+	Opportunities sobjects.ChildRecords[testTreeOpportunity] `force:"Opportunities" json:"-"`
+}
+
+func (testTreeAccount) ApiName() string {
+	return "Account"
+}
+
+type testTreeContact struct {
+	sobjects.BaseSObject
+	LastName string `force:",omitempty"`
+}
+
+func (testTreeContact) ApiName() string {
+	return "Contact"
+}
+
+type testTreeOpportunity struct {
+	sobjects.BaseSObject
+	StageName string `force:",omitempty"`
+}
+
+func (testTreeOpportunity) ApiName() string {
+	return "Opportunity"
+}
+
+func TestInsertSObjectTree(t *testing.T) {
+	org := newFakeOrg(t)
+	var req struct {
+		Records []map[string]interface{} `json:"records"`
+	}
+	org.HandleJSON("/services/data/v36.0/composite/tree/Account", func(r *http.Request) interface{} {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Unable to decode request: %v", err)
+		}
+		return &SObjectTreeResponse{Results: []*SObjectTreeResult{
+			{ReferenceId: "ref1", Id: "001A"},
+			{ReferenceId: "ref2", Id: "003A"},
+			{ReferenceId: "ref3", Id: "006A"},
+			{ReferenceId: "ref4", Id: "001B"},
+		}}
+	})
+	forceApi := org.New(t)
+
+	acme := &testTreeAccount{}
+	acme.Name = "Acme"
+	acme.Contacts.Records = []*testTreeContact{{LastName: "Smith"}}
+	acme.Opportunities.Records = []testTreeOpportunity{{StageName: "Prospecting"}}
+	globex := &testTreeAccount{}
+	globex.Name = "Globex"
+
+	resp, err := forceApi.InsertSObjectTree([]SObject{acme, globex})
+	if err != nil {
+		t.Fatalf("InsertSObjectTree failed: %v", err)
+	}
+	if resp.HasErrors {
+		t.Fatalf("Unexpected errors %+v", resp.Results)
+	}
+
+	if len(req.Records) != 2 {
+		t.Fatalf("Expected 2 top level records, got %+v", req.Records)
+	}
+	attributes := req.Records[0]["attributes"].(map[string]interface{})
+	if attributes["type"] != "Account" || attributes["referenceId"] != "ref1" || req.Records[0]["Name"] != "Acme" {
+		t.Fatalf("Unexpected record %+v", req.Records[0])
+	}
+	contacts := req.Records[0]["Contacts"].(map[string]interface{})["records"].([]interface{})
+	if contact := contacts[0].(map[string]interface{}); contact["LastName"] != "Smith" ||
+		contact["attributes"].(map[string]interface{})["referenceId"] != "ref2" {
+		t.Fatalf("Unexpected contact %+v", contact)
+	}
+	if _, ok := req.Records[1]["Contacts"]; ok {
+		t.Fatalf("Expected no empty child relationships, got %+v", req.Records[1])
+	}
+
+	if acme.Id != "001A" || acme.Contacts.Records[0].Id != "003A" || acme.Opportunities.Records[0].Id != "006A" || globex.Id != "001B" {
+		t.Fatalf("Expected ids to be written back, got %+v", acme)
+	}
+}
+
+func TestInsertSObjectTreeErrors(t *testing.T) {
+	org := newFakeOrg(t)
+	org.mux.HandleFunc("/services/data/v36.0/composite/tree/Account", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", jsonContentType)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&SObjectTreeResponse{HasErrors: true, Results: []*SObjectTreeResult{
+			{ReferenceId: "ref2", Errors: []SObjectError{{StatusCode: "REQUIRED_FIELD_MISSING", Fields: []string{"LastName"}}}},
+		}})
+	})
+	forceApi := org.New(t)
+
+	acme := &testTreeAccount{}
+	acme.Contacts.Records = []*testTreeContact{{}}
+	resp, err := forceApi.InsertSObjectTree([]SObject{acme})
+	if err != nil {
+		t.Fatalf("InsertSObjectTree failed: %v", err)
+	}
+	if !resp.HasErrors || resp.Results[0].Errors[0].StatusCode != "REQUIRED_FIELD_MISSING" || acme.Id != "" {
+		t.Fatalf("Expected the missing field to be reported, got %+v", resp)
+	}
+
+	var many []*testTreeContact
+	for i := 0; i < maxTreeRecords; i++ {
+		many = append(many, &testTreeContact{})
+	}
+	acme.Contacts.Records = many
+	if _, err := forceApi.InsertSObjectTree([]SObject{acme}); err == nil {
+		t.Fatal("Expected an error for more than 200 records")
+	}
+}
+
+func TestInsertSObjectTreeAccounts(t *testing.T) {
+	org := newFakeOrg(t)
+	requests := 0
+	org.HandleJSON("/services/data/v36.0/composite/tree/Account", func(r *http.Request) interface{} {
+		requests++
+		return &SObjectTreeResponse{Results: []*SObjectTreeResult{{ReferenceId: "ref1", Id: "001A"}}}
+	})
+	forceApi := org.New(t)
+
+	account := &testCompositeAccount{BillingCity: "Oslo"}
+	if _, err := forceApi.InsertSObjectTree([]SObject{account}); err != nil {
+		t.Fatalf("InsertSObjectTree failed: %v", err)
+	}
+	if account.Id != "001A" {
+		t.Fatalf("Expected the account to get its id, got %q", account.Id)
+	}
+
+	if _, err := forceApi.InsertSObjectTree([]SObject{sobjects.Account{}}); err == nil || requests != 1 {
+		t.Fatalf("Expected a record passed by value to be rejected before sending, got %v", err)
+	}
+}
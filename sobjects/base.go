@@ -44,6 +44,12 @@ func (b BaseSObject) ExternalIdApiName() string {
 	return ""
 }
 
+// SetID sets the record id, e.g. after the record was inserted. Along with ApiName it makes pointers to structs
+// embedding the BaseSObject satisfy force.SObject.
+func (b *BaseSObject) SetID(id string) {
+	b.Id = id
+}
+
 // Fields that are returned in every query response. Use this to build custom structs.
 // type MyCustomQueryResponse struct {
 // 	BaseQuery
@@ -55,6 +61,19 @@ type BaseQuery struct {
 	NextRecordsUri string  `json:"NextRecordsUrl" force:"nextRecordsUrl"`
 }
 
+// Records of a parent-to-child relationship subquery. Declare a field named after the relationship to select and
+// decode it along with the parent, e.g. for SELECT Id, (SELECT Id, Name FROM Opportunities) FROM Account:
+// type AccountWithOpportunities struct {
+// 	sobjects.Account
+// 	Opportunities sobjects.ChildRecords[sobjects.Opportunity] `force:"Opportunities" json:"-"`
+// }
+// The json tag keeps the records out of the payload when the parent is inserted or updated. Done is false when
+// force.com only returned the first page of the records; force.LoadChildren and force.Children fetch the rest.
+type ChildRecords[T any] struct {
+	BaseQuery
+	Records []T `json:"Records" force:"records"`
+}
+
 // ConvertFieldNames takes in any interface that inplements SObject and a comma seperated list of json field names.
 // It converts the json field names to the force struct tag stated equivalent.
 func ConvertFieldNames(obj interface{}, jsonFields string) string {